@@ -0,0 +1,84 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// Смежная пара тяжелых многоугольников в выходном JSON (индексы на сервере)
+type AdjacencyEdge struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+// Adjacency находит пары тяжелых многоугольников, границы которых касаются
+// или проходят ближе tolerance. Кандидаты отбираются по bbox, расширенным
+// на tolerance: многоугольники упорядочиваются по левой границе, и для каждого
+// просматриваются только те, что начинаются не правее его правой границы.
+// Точная проверка сравнивает расстояния между ребрами
+func Adjacency(heavies []*HeavyPolygon, tolerance float64) []AdjacencyEdge {
+	type item struct {
+		heavy *HeavyPolygon
+		ring  []Point
+		bbox  Bbox
+	}
+	items := make([]item, 0, len(heavies))
+	for _, heavy := range heavies {
+		ring := ringPoints(heavy.Points)
+		if len(ring) == 0 {
+			continue
+		}
+		items = append(items, item{heavy, ring, pointsBbox(heavy.Points)})
+	}
+	slices.SortFunc(items, func(a, b item) int { return cmp.Compare(a.bbox.X1, b.bbox.X1) })
+
+	var edges []AdjacencyEdge
+	for i := range items {
+		a := items[i]
+		for j := i + 1; j < len(items); j++ {
+			b := items[j]
+			if b.bbox.X1-a.bbox.X2 > tolerance {
+				break
+			}
+			if b.bbox.Y1-a.bbox.Y2 > tolerance || a.bbox.Y1-b.bbox.Y2 > tolerance {
+				continue
+			}
+			if ringDistance(a.ring, b.ring) <= tolerance {
+				edges = append(edges, AdjacencyEdge{min(a.heavy.Index, b.heavy.Index), max(a.heavy.Index, b.heavy.Index)})
+			}
+		}
+	}
+
+	// Порядок пар не зависит от порядка поступления многоугольников
+	slices.SortFunc(edges, func(x, y AdjacencyEdge) int {
+		return cmp.Or(cmp.Compare(x.A, y.A), cmp.Compare(x.B, y.B))
+	})
+	return edges
+}
+
+// ringDistance возвращает наименьшее расстояние между ребрами двух колец,
+// 0 - если ребра пересекаются или касаются
+func ringDistance(a, b []Point) float64 {
+	best := math.Inf(1)
+	for i := range a {
+		p, q := a[i], a[(i+1)%len(a)]
+		for j := range b {
+			r, s := b[j], b[(j+1)%len(b)]
+			if segmentsCross(p, q, r, s) {
+				return 0
+			}
+			d := min(
+				pointSegmentDistance(p.X, p.Y, r, s),
+				pointSegmentDistance(q.X, q.Y, r, s),
+				pointSegmentDistance(r.X, r.Y, p, q),
+				pointSegmentDistance(s.X, s.Y, p, q),
+			)
+			if d == 0 {
+				return 0
+			}
+			best = min(best, d)
+		}
+	}
+	return best
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAdjacency(t *testing.T) {
+	heavies := []*HeavyPolygon{
+		heavySquare(0, 1, 1, 100),
+		// Общая сторона с 0
+		heavySquare(1, 3, 1, 100),
+		// Общая сторона с 0 и общий угол с 1
+		heavySquare(2, 1, 3, 100),
+		// Зазор 0.5 до 1
+		heavySquare(3, 5.5, 1, 100),
+		heavySquare(4, 20, 20, 100),
+		// Bbox треугольников пересекаются, а гипотенузы параллельны
+		// и отстоят друг от друга на 1/sqrt(2)
+		{Polygon: polygonOf([2]float64{10, 0}, [2]float64{14, 0}, [2]float64{10, 4}), Index: 5},
+		{Polygon: polygonOf([2]float64{14, 4}, [2]float64{14, 1}, [2]float64{11, 4}), Index: 6},
+	}
+	tests := []struct {
+		name      string
+		tolerance float64
+		want      []AdjacencyEdge
+	}{
+		{"касание", 0, []AdjacencyEdge{{0, 1}, {0, 2}, {1, 2}}},
+		{"зазор в пределах допуска", 0.5, []AdjacencyEdge{{0, 1}, {0, 2}, {1, 2}, {1, 3}}},
+		{"пересечение bbox не делает смежными", 0.7, []AdjacencyEdge{{0, 1}, {0, 2}, {1, 2}, {1, 3}}},
+		{"параллельные гипотенузы", 0.8, []AdjacencyEdge{{0, 1}, {0, 2}, {1, 2}, {1, 3}, {5, 6}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Adjacency(heavies, tt.tolerance); !slices.Equal(got, tt.want) {
+				t.Errorf("Adjacency = %v, ожидалось %v", got, tt.want)
+			}
+			// Порядок поступления не влияет на результат
+			reversed := slices.Clone(heavies)
+			slices.Reverse(reversed)
+			if got := Adjacency(reversed, tt.tolerance); !slices.Equal(got, tt.want) {
+				t.Errorf("в обратном порядке Adjacency = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Переменные окружения с учетными данными. Значения флагов видны в списке
+// процессов, поэтому секреты лучше передавать через окружение
+const (
+	envAuthBearer = "POLYGON_AUTH_BEARER"
+	envAuthBasic  = "POLYGON_AUTH_BASIC"
+)
+
+// Заголовки из повторяемого флага -header key=value
+var extraHeaders = headerFlags{}
+
+// headerFlags накапливает значения повторяемого флага -header
+type headerFlags http.Header
+
+func (h headerFlags) String() string {
+	pairs := make([]string, 0, len(h))
+	for key, values := range h {
+		for _, value := range values {
+			pairs = append(pairs, key+"="+value)
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (h headerFlags) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("ожидается key=value, получено %q", s)
+	}
+	http.Header(h).Add(key, strings.TrimSpace(value))
+	return nil
+}
+
+// requestHeader собирает заголовки, добавляемые к каждому запросу к серверу:
+// -header и авторизацию. Пустые -auth_bearer и -auth_basic берутся из окружения
+func requestHeader(bearer, basic string, extra headerFlags) (http.Header, error) {
+	header := http.Header(extra).Clone()
+	if bearer == "" {
+		bearer = os.Getenv(envAuthBearer)
+	}
+	if basic == "" {
+		basic = os.Getenv(envAuthBasic)
+	}
+	if bearer != "" && basic != "" {
+		return nil, fmt.Errorf("нужно выбрать одно из -auth_bearer и -auth_basic")
+	}
+
+	switch {
+	case bearer != "":
+		header.Set("Authorization", "Bearer "+bearer)
+	case basic != "":
+		if !strings.Contains(basic, ":") {
+			return nil, fmt.Errorf("-auth_basic ожидается в виде user:pass")
+		}
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(basic)))
+	}
+	return header, nil
+}
+
+// statusError описывает неуспешный статус ответа. Отказ в доступе
+// объясняется отдельно: повтор с теми же учетными данными не поможет
+func statusError(code int) error {
+	if code == http.StatusUnauthorized || code == http.StatusForbidden {
+		return fmt.Errorf("сервер отклонил авторизацию (статус %d), проверьте -auth_bearer, -auth_basic и -header", code)
+	}
+	return fmt.Errorf("некорректный статус ответа: %d", code)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Лимит -bandwidth_limit общий для всех воркеров: два параллельных чтения
+// тела известного размера вместе занимают не меньше расчетного времени
+func TestBandwidthLimit(t *testing.T) {
+	body := largePolygonJSON(500)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentTypeJSON)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	const rate = 20000
+	f := newTestFetcher(t, server.URL, server.Client())
+	f.opts.bandwidth = newBandwidthLimiter(rate)
+	// Первые burst байт доступны сразу, остальное читается со скоростью rate
+	burst := f.opts.bandwidth.Burst()
+	want := time.Duration(float64(2*len(body)-burst) / rate * float64(time.Second))
+	if want < 200*time.Millisecond {
+		t.Fatalf("тело %d байт слишком мало для проверки", len(body))
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for idx := range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if r := f.fetchAndProcessPolygon(context.Background(), idx); r.err != nil || r.pointCount != 500 {
+				t.Errorf("многоугольник %d: ошибка %v, точек %d", idx, r.err, r.pointCount)
+			}
+		}()
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < want {
+		t.Errorf("чтение %d байт заняло %v, ожидалось не меньше %v", 2*len(body), elapsed, want)
+	}
+}
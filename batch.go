@@ -0,0 +1,39 @@
+package main
+
+import "github.com/kscvrmn/tev_test/polygon"
+
+// resultBatch - пачка результатов одного воркера с частичным агрегатом.
+// Общий bbox и максимальный вес воркер объединяет сам, поэтому сборщик
+// обновляет их один раз на пачку, а канал результатов получает одно
+// сообщение вместо batchSize
+type resultBatch struct {
+	bbox      Bbox
+	maxWeight float64
+	results   []PolygonResult
+	size      int
+}
+
+func newResultBatch(size int) *resultBatch {
+	return &resultBatch{
+		bbox:    polygon.EmptyBbox(),
+		results: make([]PolygonResult, 0, size),
+		size:    size,
+	}
+}
+
+// add добавляет результат в пачку. Ошибки в частичный агрегат не входят
+func (b *resultBatch) add(polygonResult PolygonResult, opts collectOptions) {
+	if polygonResult.err == nil {
+		b.bbox = b.bbox.Union(polygonResult.localBbox)
+		b.maxWeight = MaxFloat(b.maxWeight, opts.importance(polygonResult))
+	}
+	b.results = append(b.results, polygonResult)
+}
+
+func (b *resultBatch) full() bool {
+	return len(b.results) >= b.size
+}
+
+func (b *resultBatch) empty() bool {
+	return len(b.results) == 0
+}
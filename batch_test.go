@@ -0,0 +1,64 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/kscvrmn/tev_test/polygontest"
+)
+
+// С -batch_size пул дает тот же Result, что и без пачек, в том числе
+// когда число многоугольников не делится на размер пачки и среди них есть ошибки
+func TestBatchSizeMatchesUnbatched(t *testing.T) {
+	const total = 23
+	server := polygontest.NewServer(total, map[int]polygontest.Case{
+		3:  polygontest.Empty,
+		8:  polygontest.ServerError,
+		15: polygontest.Malformed,
+	})
+	defer server.Close()
+	f := newTestFetcher(t, server.PolygonURL(), server.Client())
+	opts := collectOptions{perPolygon: true, heavyThreshold: 100}
+	setFlag(t, "workers", "3")
+
+	run := func(batchSize string) []byte {
+		setFlag(t, "batch_size", batchSize)
+		resCh := make(chan Result, 1)
+		runPool(context.Background(), total, total, nil, resCh, f, opts, newFetchLimiter(0, 0, 0), nil, newPauseGate())
+		result := <-resCh
+		// Пул собирает тяжелые многоугольники в порядке готовности
+		slices.SortFunc(result.HeavyPolygons, func(x, y *HeavyPolygon) int { return cmp.Compare(x.Index, y.Index) })
+		data, err := json.Marshal(result)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	want := run("1")
+	for _, size := range []string{"4", "50"} {
+		if got := run(size); string(got) != string(want) {
+			t.Errorf("с -batch_size %s результат расходится:\nс пачками: %s\nбез пачек: %s", size, got, want)
+		}
+	}
+}
+
+// Частичный агрегат пачки не учитывает ошибки
+func TestResultBatch(t *testing.T) {
+	b := newResultBatch(3)
+	b.add(processed(t, 0, square(0, 0, 2, 40), processOptions{}), collectOptions{})
+	b.add(PolygonResult{index: 1, err: errBreakerOpen}, collectOptions{})
+	if b.full() || b.empty() {
+		t.Fatalf("пачка из 2 результатов из 3: full %v, empty %v", b.full(), b.empty())
+	}
+	b.add(processed(t, 2, square(5, 5, 2, 60), processOptions{}), collectOptions{})
+	if !b.full() {
+		t.Error("пачка из 3 результатов не заполнена")
+	}
+	if want := (Bbox{X1: 0, Y1: 0, X2: 7, Y2: 7}); b.bbox != want || b.maxWeight != 60 {
+		t.Errorf("bbox %+v, вес %g, ожидалось %+v и 60", b.bbox, b.maxWeight, want)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Ошибка, которую получают запросы, пока предохранитель разомкнут
+var errBreakerOpen = errors.New("сервер недоступен: предохранитель разомкнут")
+
+type breakerState int
+
+const (
+	breakerClosed   breakerState = iota // запросы проходят
+	breakerOpen                         // запросы сразу завершаются ошибкой
+	breakerHalfOpen                     // пропускается один пробный запрос
+)
+
+// circuitBreaker - общий для всех воркеров предохранитель. После threshold
+// подряд идущих сбоев он размыкается и на время cooldown отклоняет запросы,
+// не нагружая заведомо неработающий сервер. Затем пропускает один пробный
+// запрос: успех замыкает предохранитель, сбой размыкает его снова
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	probing   bool
+
+	now func() time.Time // источник времени, вынесен для возможности подмены
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown, now: time.Now}
+}
+
+// Allow проверяет, можно ли выполнить запрос. При разрешении вызывающий
+// обязан сообщить исход запроса через Record
+func (b *circuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return errBreakerOpen
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return nil
+	case breakerHalfOpen:
+		// Пока пробный запрос не завершился, остальные отклоняются
+		if b.probing {
+			return errBreakerOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Cancel снимает разрешение, выданное Allow, для запроса, отмененного
+// до получения ответа, не засчитывая ни успех, ни сбой
+func (b *circuitBreaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// Record учитывает исход разрешенного запроса
+func (b *circuitBreaker) Record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if success {
+		b.state = breakerClosed
+		b.failures = 0
+		b.probing = false
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = b.now()
+		b.probing = false
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/kscvrmn/tev_test/polygontest"
+)
+
+// Переходы предохранителя: размыкание после threshold сбоев подряд,
+// один пробный запрос после cooldown и замыкание при его успехе
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newCircuitBreaker(3, time.Minute)
+	b.now = func() time.Time { return now }
+
+	// Успех сбрасывает счетчик: сбои должны идти подряд
+	for _, success := range []bool{false, false, true, false, false} {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("запрос отклонен до срабатывания: %v", err)
+		}
+		b.Record(success)
+	}
+	b.Allow()
+	b.Record(false)
+	if err := b.Allow(); !errors.Is(err, errBreakerOpen) {
+		t.Fatalf("после трех сбоев подряд Allow = %v, ожидалось %v", err, errBreakerOpen)
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("пробный запрос после cooldown отклонен: %v", err)
+	}
+	if err := b.Allow(); !errors.Is(err, errBreakerOpen) {
+		t.Errorf("второй запрос во время пробного Allow = %v", err)
+	}
+	// Сбой пробного запроса размыкает предохранитель сразу, без threshold
+	b.Record(false)
+	if err := b.Allow(); !errors.Is(err, errBreakerOpen) {
+		t.Fatalf("после сбоя пробного запроса Allow = %v", err)
+	}
+
+	// Отмененный пробный запрос освобождает место для следующего
+	now = now.Add(time.Minute)
+	b.Allow()
+	b.Cancel()
+	if err := b.Allow(); err != nil {
+		t.Fatalf("пробный запрос после отмены предыдущего отклонен: %v", err)
+	}
+	b.Record(true)
+	for range 3 {
+		if err := b.Allow(); err != nil {
+			t.Fatalf("после успешного пробного запроса Allow = %v", err)
+		}
+		b.Record(true)
+	}
+}
+
+// Пока предохранитель разомкнут, загрузка завершается ошибкой без запроса к серверу
+func TestFetchBreakerFastFails(t *testing.T) {
+	server := polygontest.NewServer(4, map[int]polygontest.Case{
+		0: polygontest.ServerError,
+		1: polygontest.ServerError,
+		2: polygontest.ServerError,
+		3: polygontest.ServerError,
+	})
+	defer server.Close()
+	transport := &countingTransport{RoundTripper: server.Client().Transport}
+	f := newTestFetcher(t, server.PolygonURL(), &http.Client{Transport: transport})
+	f.opts.breaker = newCircuitBreaker(2, time.Hour)
+
+	for idx := range 4 {
+		r := f.fetchAndProcessPolygon(context.Background(), idx)
+		if wantOpen := idx >= 2; errors.Is(r.err, errBreakerOpen) != wantOpen {
+			t.Errorf("многоугольник %d: ошибка %v", idx, r.err)
+		}
+	}
+	if n := transport.requests.Load(); n != 2 {
+		t.Errorf("к серверу ушло %d запросов, ожидалось 2", n)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+// Перебор пар вершин растет как O(n^2) с проверкой O(n) на каждую пару,
+// поэтому для многоугольников с большим числом вершин хорда не считается
+const maxChordVertices = 1000
+
+// Хорда многоугольника в выходном JSON
+type Chord struct {
+	A      Point   `json:"a"`
+	B      Point   `json:"b"`
+	Length float64 `json:"length"`
+}
+
+// LongestInternalChord находит самый длинный отрезок между двумя вершинами,
+// целиком лежащий внутри многоугольника (включая границу). Пары вершин
+// проверяются в порядке убывания длины, поэтому поиск останавливается на первой
+// подходящей. Отрезок отбрасывается, если он пересекает какое-либо ребро или
+// его промежуточные точки лежат вне многоугольника.
+// Для многоугольников без подходящих пар возвращается нулевая длина
+func LongestInternalChord(p *Polygon) (Point, Point, float64) {
+	pts := ringPoints(p.Points)
+	n := len(pts)
+	if n < 2 || n > maxChordVertices {
+		return Point{}, Point{}, 0
+	}
+
+	type pair struct {
+		i, j int
+		d2   float64
+	}
+	pairs := make([]pair, 0, n*(n-1)/2)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			pairs = append(pairs, pair{i, j, dist2(pts[i], pts[j])})
+		}
+	}
+	slices.SortFunc(pairs, func(a, b pair) int { return cmp.Compare(b.d2, a.d2) })
+
+	for _, pr := range pairs {
+		if chordInside(pts, pr.i, pr.j) {
+			return pts[pr.i], pts[pr.j], math.Sqrt(pr.d2)
+		}
+	}
+	return Point{}, Point{}, 0
+}
+
+// chordInside проверяет, что отрезок между вершинами i и j лежит внутри многоугольника
+func chordInside(pts []Point, i, j int) bool {
+	a, b := pts[i], pts[j]
+	n := len(pts)
+	for k := 0; k < n; k++ {
+		c, d := pts[k], pts[(k+1)%n]
+		// Ребра, выходящие из концов хорды, касаются ее только в вершине
+		if k == i || k == j || (k+1)%n == i || (k+1)%n == j {
+			continue
+		}
+		if segmentsCross(a, b, c, d) {
+			return false
+		}
+	}
+
+	// Пересечений нет, но отрезок может целиком проходить снаружи
+	// (например, через вырез). Проверяем несколько внутренних точек
+	for _, t := range []float64{0.25, 0.5, 0.75} {
+		x := a.X + t*(b.X-a.X)
+		y := a.Y + t*(b.Y-a.Y)
+		if !pointInRing(x, y, pts) {
+			return false
+		}
+	}
+	return true
+}
+
+// segmentsCross сообщает о собственном пересечении отрезков ab и cd
+// (пересечение во внутренних точках обоих отрезков)
+func segmentsCross(a, b, c, d Point) bool {
+	d1, d2 := sign64(cross(a, b, c)), sign64(cross(a, b, d))
+	d3, d4 := sign64(cross(c, d, a)), sign64(cross(c, d, b))
+	return d1*d2 < 0 && d3*d4 < 0
+}
+
+// pointInRing проверяет попадание точки в многоугольник методом луча.
+// Точки на границе считаются внутренними
+func pointInRing(x, y float64, pts []Point) bool {
+	inside := false
+	n := len(pts)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		xi, yi := pts[i].X, pts[i].Y
+		xj, yj := pts[j].X, pts[j].Y
+
+		// Точка на ребре
+		if (x-xi)*(yj-yi) == (y-yi)*(xj-xi) &&
+			min(xi, xj) <= x && x <= max(xi, xj) && min(yi, yj) <= y && y <= max(yi, yj) {
+			return true
+		}
+
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
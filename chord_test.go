@@ -0,0 +1,41 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLongestInternalChord(t *testing.T) {
+	tests := []struct {
+		name string
+		p    *Polygon
+		want float64
+	}{
+		{"квадрат", polygonOf([2]float64{0, 0}, [2]float64{2, 0}, [2]float64{2, 2}, [2]float64{0, 2}), 2 * math.Sqrt2},
+		// Хорда может совпадать с ребром
+		{"треугольник", polygonOf([2]float64{0, 0}, [2]float64{4, 0}, [2]float64{0, 3}), 5},
+		// Диагонали и хорды длиной sqrt(13) проходят через вырез,
+		// внутри остаются только хорды вдоль полок длиной sqrt(10)
+		{"с вырезом", polygonOf([2]float64{0, 0}, [2]float64{3, 0}, [2]float64{3, 1}, [2]float64{1, 1},
+			[2]float64{1, 2}, [2]float64{3, 2}, [2]float64{3, 3}, [2]float64{0, 3}), math.Sqrt(10)},
+		{"одна точка", polygonOf([2]float64{1, 1}), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b, length := LongestInternalChord(tt.p)
+			if math.Abs(length-tt.want) > 1e-9 {
+				t.Errorf("длина %g, ожидалось %g", length, tt.want)
+			}
+			if d := math.Hypot(a.X-b.X, a.Y-b.Y); math.Abs(d-length) > 1e-9 {
+				t.Errorf("концы %+v и %+v не соответствуют длине %g", a, b, length)
+			}
+		})
+	}
+}
+
+// Для слишком больших многоугольников хорда не ищется
+func TestLongestInternalChordTooManyVertices(t *testing.T) {
+	if _, _, length := LongestInternalChord(regularPolygon(maxChordVertices+1, 10)); length != 0 {
+		t.Errorf("длина %g для %d вершин, ожидалось 0", length, maxChordVertices+1)
+	}
+}
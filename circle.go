@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// Окружность на плоскости
+type Circle struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	R float64 `json:"r"`
+}
+
+// Относительный допуск при проверке попадания точки в окружность
+const circleEps = 1e-9
+
+func (c Circle) contains(p Point) bool {
+	return math.Hypot(p.X-c.X, p.Y-c.Y) <= c.R*(1+circleEps)+circleEps
+}
+
+// MinEnclosingCircle возвращает наименьшую окружность, содержащую все вершины.
+// Используется итеративный алгоритм Уэлцла с ожидаемым временем O(n).
+// Окружность определяется только вершинами выпуклой оболочки, поэтому
+// алгоритм запускается по ним. Перемешивание с фиксированным зерном влияет
+// лишь на время работы, результат от порядка точек не зависит
+func MinEnclosingCircle(p *Polygon) Circle {
+	pts := ConvexHull(ringPoints(p.Points))
+	if len(pts) == 0 {
+		return Circle{}
+	}
+	rnd := rand.New(rand.NewPCG(1, 2))
+	rnd.Shuffle(len(pts), func(i, j int) { pts[i], pts[j] = pts[j], pts[i] })
+
+	c := Circle{X: pts[0].X, Y: pts[0].Y}
+	for i := 1; i < len(pts); i++ {
+		if c.contains(pts[i]) {
+			continue
+		}
+		c = Circle{X: pts[i].X, Y: pts[i].Y}
+		for j := 0; j < i; j++ {
+			if c.contains(pts[j]) {
+				continue
+			}
+			c = circleFrom2(pts[i], pts[j])
+			for k := 0; k < j; k++ {
+				if !c.contains(pts[k]) {
+					c = circleFrom3(pts[i], pts[j], pts[k])
+				}
+			}
+		}
+	}
+	return c
+}
+
+// circleFrom2 - окружность с диаметром ab
+func circleFrom2(a, b Point) Circle {
+	x := (a.X + b.X) / 2
+	y := (a.Y + b.Y) / 2
+	return Circle{X: x, Y: y, R: math.Hypot(a.X-x, a.Y-y)}
+}
+
+// circleFrom3 - описанная окружность треугольника abc. Для коллинеарных
+// точек описанной окружности нет, берем окружность по самой дальней паре
+func circleFrom3(a, b, c Point) Circle {
+	bx, by := b.X-a.X, b.Y-a.Y
+	cx, cy := c.X-a.X, c.Y-a.Y
+	d := 2 * (bx*cy - by*cx)
+	if d == 0 {
+		best := circleFrom2(a, b)
+		for _, cand := range []Circle{circleFrom2(a, c), circleFrom2(b, c)} {
+			if cand.R > best.R {
+				best = cand
+			}
+		}
+		return best
+	}
+
+	b2, c2 := bx*bx+by*by, cx*cx+cy*cy
+	ux := (cy*b2 - by*c2) / d
+	uy := (bx*c2 - cx*b2) / d
+	return Circle{X: a.X + ux, Y: a.Y + uy, R: math.Hypot(ux, uy)}
+}
+
+// CircleBoxRatio - отношение площади минимальной охватывающей окружности
+// к площади bbox, эвристика "округлости" формы. Для квадрата равно π/2.
+// При нулевой площади bbox (отрезок или точка) возвращается 0
+func CircleBoxRatio(p *Polygon, bbox Bbox) float64 {
+	area := (bbox.X2 - bbox.X1) * (bbox.Y2 - bbox.Y1)
+	if area == 0 {
+		return 0
+	}
+	c := MinEnclosingCircle(p)
+	return math.Pi * c.R * c.R / area
+}
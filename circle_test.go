@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+)
+
+func TestMinEnclosingCircle(t *testing.T) {
+	tests := []struct {
+		name string
+		p    *Polygon
+		want Circle
+	}{
+		{"пустой", &Polygon{}, Circle{}},
+		{"квадрат", square(0, 0, 2, 4), Circle{X: 1, Y: 1, R: math.Sqrt2}},
+		// Тупоугольный треугольник: окружность строится на длинной стороне
+		{"тупоугольный треугольник", polygonOf([2]float64{0, 0}, [2]float64{10, 0}, [2]float64{5, 1}), Circle{X: 5, Y: 0, R: 5}},
+		{"правильный шестиугольник", regularPolygon(6, 3), Circle{X: 0, Y: 0, R: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := MinEnclosingCircle(tt.p)
+			if math.Abs(c.X-tt.want.X) > 1e-9 || math.Abs(c.Y-tt.want.Y) > 1e-9 || math.Abs(c.R-tt.want.R) > 1e-9 {
+				t.Errorf("окружность %+v, ожидалась %+v", c, tt.want)
+			}
+		})
+	}
+}
+
+// Окружность содержит все вершины, и на ее границе лежат хотя бы две из них:
+// иначе ее можно было бы уменьшить
+func TestMinEnclosingCircleRandom(t *testing.T) {
+	rng := rand.New(rand.NewPCG(5, 6))
+	for range 20 {
+		p := randomPolygon(rng, 100)
+		c := MinEnclosingCircle(p)
+		onBoundary := 0
+		for _, pt := range p.Points {
+			if !c.contains(pt.Point) {
+				t.Fatalf("точка %+v вне окружности %+v", pt.Point, c)
+			}
+			if math.Abs(math.Hypot(pt.X-c.X, pt.Y-c.Y)-c.R) < 1e-6 {
+				onBoundary++
+			}
+		}
+		if onBoundary < 2 {
+			t.Errorf("на границе окружности %+v только %d точек", c, onBoundary)
+		}
+	}
+}
+
+func TestCircleBoxRatio(t *testing.T) {
+	// Описанная окружность квадрата относится к его bbox как π/2
+	sq := square(0, 0, 4, 4)
+	if got := CircleBoxRatio(sq, Bbox{X1: 0, Y1: 0, X2: 4, Y2: 4}); math.Abs(got-math.Pi/2) > 1e-9 {
+		t.Errorf("квадрат: %g, ожидалось π/2", got)
+	}
+	// Нулевая площадь bbox не дает деления на ноль
+	segment := polygonOf([2]float64{0, 0}, [2]float64{4, 0})
+	if got := CircleBoxRatio(segment, Bbox{X1: 0, Y1: 0, X2: 4, Y2: 0}); got != 0 {
+		t.Errorf("отрезок: %g, ожидалось 0", got)
+	}
+}
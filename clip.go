@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ClipPolygonToBbox обрезает многоугольник по прямоугольнику алгоритмом
+// Сазерленда-Ходжмана. Вершины, появившиеся на пересечениях со сторонами,
+// получают вес, линейно интерполированный вдоль исходного ребра.
+// Исходный многоугольник не изменяется
+func ClipPolygonToBbox(p *Polygon, b Bbox) *Polygon {
+	points := p.Points
+	// Последовательно отсекаем полуплоскости за каждой из четырех сторон
+	edges := []struct {
+		inside    func(pt WeightedPoint) bool
+		intersect func(a, c WeightedPoint) WeightedPoint
+	}{
+		{func(pt WeightedPoint) bool { return pt.X >= b.X1 }, func(a, c WeightedPoint) WeightedPoint { return intersectX(a, c, b.X1) }},
+		{func(pt WeightedPoint) bool { return pt.X <= b.X2 }, func(a, c WeightedPoint) WeightedPoint { return intersectX(a, c, b.X2) }},
+		{func(pt WeightedPoint) bool { return pt.Y >= b.Y1 }, func(a, c WeightedPoint) WeightedPoint { return intersectY(a, c, b.Y1) }},
+		{func(pt WeightedPoint) bool { return pt.Y <= b.Y2 }, func(a, c WeightedPoint) WeightedPoint { return intersectY(a, c, b.Y2) }},
+	}
+
+	for _, edge := range edges {
+		if len(points) == 0 {
+			break
+		}
+		clipped := make([]WeightedPoint, 0, len(points))
+		prev := points[len(points)-1]
+		for _, cur := range points {
+			switch {
+			case edge.inside(cur) && !edge.inside(prev):
+				clipped = append(clipped, edge.intersect(prev, cur), cur)
+			case edge.inside(cur):
+				clipped = append(clipped, cur)
+			case edge.inside(prev):
+				clipped = append(clipped, edge.intersect(prev, cur))
+			}
+			prev = cur
+		}
+		points = clipped
+	}
+	return &Polygon{Points: points}
+}
+
+// intersectX находит точку пересечения отрезка a-c с вертикалью x
+func intersectX(a, c WeightedPoint, x float64) WeightedPoint {
+	t := (x - a.X) / (c.X - a.X)
+	return WeightedPoint{
+		Point:  Point{X: x, Y: a.Y + t*(c.Y-a.Y)},
+		Weight: a.Weight + t*(c.Weight-a.Weight),
+	}
+}
+
+// intersectY находит точку пересечения отрезка a-c с горизонталью y
+func intersectY(a, c WeightedPoint, y float64) WeightedPoint {
+	t := (y - a.Y) / (c.Y - a.Y)
+	return WeightedPoint{
+		Point:  Point{X: a.X + t*(c.X-a.X), Y: y},
+		Weight: a.Weight + t*(c.Weight-a.Weight),
+	}
+}
+
+// parseBbox разбирает прямоугольник в виде "x1,y1,x2,y2"
+func parseBbox(s string) (Bbox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return Bbox{}, fmt.Errorf("ожидается x1,y1,x2,y2, получено %q", s)
+	}
+
+	var v [4]float64
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return Bbox{}, fmt.Errorf("некорректная координата %q: %v", part, err)
+		}
+		v[i] = n
+	}
+	if v[0] > v[2] || v[1] > v[3] {
+		return Bbox{}, fmt.Errorf("x1 и y1 не должны превышать x2 и y2: %q", s)
+	}
+	return Bbox{X1: v[0], Y1: v[1], X2: v[2], Y2: v[3]}, nil
+}
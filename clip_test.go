@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/kscvrmn/tev_test/polygon"
+)
+
+// bboxOf возвращает bbox точек многоугольника
+func bboxOf(p *Polygon) Bbox {
+	stats, _ := polygon.ProcessPolygon(context.Background(), p)
+	return stats.Bbox
+}
+
+func TestClipPolygonToBbox(t *testing.T) {
+	rect := Bbox{X1: 0, Y1: 0, X2: 10, Y2: 10}
+	tests := []struct {
+		name string
+		p    *Polygon
+		bbox Bbox
+		area float64
+	}{
+		{"внутри", polygonOf([2]float64{1, 1}, [2]float64{9, 1}, [2]float64{9, 9}), Bbox{X1: 1, Y1: 1, X2: 9, Y2: 9}, 32},
+		// Треугольник выходит за левую и правую стороны: отрезаются два угла по 12.5
+		{"выходит за стороны", polygonOf([2]float64{-5, 0}, [2]float64{15, 0}, [2]float64{5, 10}), Bbox{X1: 0, Y1: 0, X2: 10, Y2: 10}, 75},
+		{"накрывает прямоугольник", polygonOf([2]float64{-10, -10}, [2]float64{20, -10}, [2]float64{20, 20}, [2]float64{-10, 20}), rect, 100},
+		{"снаружи", polygonOf([2]float64{20, 20}, [2]float64{30, 20}, [2]float64{30, 30}), Bbox{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := len(tt.p.Points)
+			clipped := ClipPolygonToBbox(tt.p, rect)
+			if len(tt.p.Points) != original {
+				t.Error("исходный многоугольник изменен")
+			}
+			if area := clipped.Area(); math.Abs(area-tt.area) > 1e-9 {
+				t.Errorf("площадь %g, ожидалась %g", area, tt.area)
+			}
+			if len(clipped.Points) == 0 {
+				if tt.area != 0 {
+					t.Error("многоугольник обрезан целиком")
+				}
+				return
+			}
+			if bbox := bboxOf(clipped); bbox != tt.bbox {
+				t.Errorf("bbox %+v, ожидался %+v", bbox, tt.bbox)
+			}
+		})
+	}
+}
+
+// Вес вершины на пересечении интерполируется вдоль исходного ребра
+func TestClipPolygonInterpolatesWeight(t *testing.T) {
+	p := &Polygon{Points: []WeightedPoint{wp(0, 0, 0), wp(10, 0, 10), wp(10, 10, 10), wp(0, 10, 0)}}
+	clipped := ClipPolygonToBbox(p, Bbox{X1: 0, Y1: 0, X2: 5, Y2: 10})
+	var cut int
+	for _, pt := range clipped.Points {
+		if pt.X == 5 {
+			cut++
+			if pt.Weight != 5 {
+				t.Errorf("вес точки %+v, ожидался 5", pt)
+			}
+		}
+	}
+	if cut != 2 {
+		t.Errorf("точек на линии отреза %d, ожидалось 2: %v", cut, clipped.Points)
+	}
+}
+
+// С -clip тяжелые многоугольники обрезаются вторым проходом после агрегации,
+// а bbox и площадь агрегата считаются по исходным точкам
+func TestCollectClip(t *testing.T) {
+	rect := Bbox{X1: 0, Y1: 0, X2: 5, Y2: 5}
+	result := collect(t, collectOptions{clip: true, clipRect: &rect},
+		processed(t, 0, square(0, 0, 10, 200), processOptions{}),
+	)
+	if len(result.HeavyPolygons) != 1 {
+		t.Fatalf("тяжелых %d, ожидался 1", len(result.HeavyPolygons))
+	}
+	if bbox := bboxOf(result.HeavyPolygons[0].Polygon); bbox != rect {
+		t.Errorf("bbox обрезанного %+v, ожидался %+v", bbox, rect)
+	}
+	if want := (Bbox{X1: 0, Y1: 0, X2: 10, Y2: 10}); result.Bbox != want {
+		t.Errorf("общий bbox %+v, ожидался %+v", result.Bbox, want)
+	}
+}
+
+func TestParseBbox(t *testing.T) {
+	if b, err := parseBbox(" 0, -1.5, 10, 2"); err != nil || b != (Bbox{X1: 0, Y1: -1.5, X2: 10, Y2: 2}) {
+		t.Errorf("bbox %+v, ошибка %v", b, err)
+	}
+	for _, s := range []string{"", "0,0,1", "0,0,1,x", "5,0,1,1", "0,5,1,1"} {
+		if _, err := parseBbox(s); err == nil {
+			t.Errorf("%q разобрано без ошибки", s)
+		}
+	}
+}
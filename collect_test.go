@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Пустой набор (-polygons_num 0 или все индексы уже есть в -append_output):
+// канал результатов закрывается сразу, а сборщик все равно должен прислать
+// итог, иначе main ждет его бесконечно
+func TestCollectResultsNothingPending(t *testing.T) {
+	results := make(chan PolygonResult)
+	close(results)
+	resCh := make(chan Result, 1)
+
+	go collectResults(context.Background(), results, 0, resCh, collectOptions{})
+
+	select {
+	case result := <-resCh:
+		if result.Partial || result.Processed != 0 || !result.Empty {
+			t.Errorf("результат %+v, ожидался полный пустой результат", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("сборщик не прислал результат для пустого набора")
+	}
+}
+
+// В режиме -count_only считаются только счетчики: тяжелые многоугольники
+// не накапливаются, а вывод - одна строка со сводкой
+func TestCollectCountOnly(t *testing.T) {
+	setFlag(t, "count_only", "true")
+	result := collect(t, collectOptions{countOnly: true},
+		processed(t, 0, square(0, 0, 10, 150), processOptions{}),
+		processed(t, 1, square(20, 0, 10, 8), processOptions{}),
+		PolygonResult{index: 2, err: errors.New("ошибка загрузки")},
+		processed(t, 3, square(40, 0, 10, 240), processOptions{}),
+	)
+
+	if result.Processed != 3 || result.ErrorCount != 1 || result.MaxWeight != 240 {
+		t.Errorf("обработано %d, ошибок %d, max_weight %g, ожидалось 3, 1, 240", result.Processed, result.ErrorCount, result.MaxWeight)
+	}
+	if len(result.HeavyPolygons) != 0 {
+		t.Errorf("сохранено %d тяжелых многоугольников", len(result.HeavyPolygons))
+	}
+
+	var out bytes.Buffer
+	if err := encodeResult(&out, result); err != nil {
+		t.Fatal(err)
+	}
+	if want := "processed=3 errors=1 max_weight=240\n"; out.String() != want {
+		t.Errorf("сводка %q, ожидалась %q", out.String(), want)
+	}
+}
+
+// Самый тяжелый по сумме весов и самый тяжелый по весу, умноженному
+// на число точек, - разные многоугольники, и каждая метрика выбирает свой
+func TestCollectImportanceMetric(t *testing.T) {
+	// Вес 90 на 4 точках: 360 по weight_x_points
+	few := square(0, 0, 10, 90)
+	// Вес 60 на 10 точках: 600 по weight_x_points
+	many := &Polygon{}
+	for i := range 10 {
+		many.Points = append(many.Points, wp(float64(i), float64(i%2), 6))
+	}
+
+	tests := []struct {
+		metric string
+		want   float64
+	}{
+		{metricWeight, 90},
+		{metricWeightXPoints, 600},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			result := collect(t, collectOptions{importanceMetric: tt.metric},
+				processed(t, 0, few, processOptions{}),
+				processed(t, 1, many, processOptions{}),
+			)
+			if result.MaxWeight != tt.want {
+				t.Errorf("max_weight %g, ожидалось %g", result.MaxWeight, tt.want)
+			}
+		})
+	}
+}
+
+// С -dedup_by_bbox результат с тем же bbox и весом, что у уже учтенного,
+// считается обработанным, но в агрегат не попадает
+func TestCollectDedupByBbox(t *testing.T) {
+	// Те же bbox и вес, но другие точки: дубликат по дешевому признаку
+	sameBbox := &Polygon{Points: []WeightedPoint{wp(0, 0, 100), wp(10, 10, 50)}}
+	result := collect(t, collectOptions{dedupByBbox: true},
+		processed(t, 0, square(0, 0, 10, 150), processOptions{}),
+		processed(t, 1, sameBbox, processOptions{}),
+		processed(t, 2, square(0, 0, 10, 160), processOptions{}),
+		processed(t, 3, square(5, 0, 10, 150), processOptions{}),
+		processed(t, 4, square(0, 0, 10, 150), processOptions{}),
+	)
+
+	if result.Processed != 5 {
+		t.Errorf("обработано %d, ожидалось 5", result.Processed)
+	}
+	var indices []int
+	for _, heavy := range result.HeavyPolygons {
+		indices = append(indices, heavy.Index)
+	}
+	if !slices.Equal(indices, []int{0, 2, 3}) {
+		t.Errorf("тяжелые %v, ожидались [0 2 3]", indices)
+	}
+}
+
+// -area_budget оставляет крупнейшие тяжелые многоугольники по убыванию
+// площади и обрывает список на первом не поместившемся
+func TestCollectAreaBudget(t *testing.T) {
+	sides := []float64{3, 5, 4, 1}
+	var results []PolygonResult
+	for i, side := range sides {
+		results = append(results, processed(t, i, square(float64(i*10), 0, side, 150), processOptions{}))
+	}
+	tests := []struct {
+		name   string
+		budget float64
+		want   []int
+	}{
+		{"без ограничения", 0, []int{0, 1, 2, 3}},
+		// 25 + 16 = 41, следующий (9) уже не помещается, а за ним и 1
+		{"обрыв на первом лишнем", 45, []int{1, 2}},
+		{"ровно по бюджету", 50, []int{1, 2, 0}},
+		{"все помещаются", 51, []int{1, 2, 0, 3}},
+		{"даже крупнейший не помещается", 10, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := collect(t, collectOptions{areaBudget: tt.budget}, results...)
+			var indices []int
+			for _, heavy := range result.HeavyPolygons {
+				indices = append(indices, heavy.Index)
+			}
+			if tt.budget == 0 {
+				slices.Sort(indices)
+			}
+			if !slices.Equal(indices, tt.want) {
+				t.Errorf("тяжелые %v, ожидались %v", indices, tt.want)
+			}
+		})
+	}
+}
+
+// С -snapshot_interval сборщик выводит промежуточные агрегаты с пометкой
+// snapshot, пока ждет результаты, а итог приходит без пометки
+func TestCollectSnapshots(t *testing.T) {
+	results := make(chan PolygonResult)
+	resCh := make(chan Result, 1)
+	var out bytes.Buffer
+	opts := collectOptions{snapshotInterval: 5 * time.Millisecond, snapshotOut: &out, heavyThreshold: 100}
+	go collectResults(context.Background(), results, 2, resCh, opts)
+
+	results <- processed(t, 0, square(0, 0, 10, 150), processOptions{})
+	time.Sleep(50 * time.Millisecond)
+	results <- processed(t, 1, square(20, 0, 10, 8), processOptions{})
+	final := <-resCh
+
+	if final.Snapshot || final.Processed != 2 || final.TotalHeavyArea != 100 {
+		t.Errorf("итог: snapshot %v, обработано %d, площадь тяжелых %g", final.Snapshot, final.Processed, final.TotalHeavyArea)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) < 2 {
+		t.Fatalf("за 50 мс выведено %d снимков при периоде 5 мс", len(lines))
+	}
+	for _, line := range lines {
+		var snapshot Result
+		if err := json.Unmarshal([]byte(line), &snapshot); err != nil {
+			t.Fatalf("снимок %q: %v", line, err)
+		}
+		// Снимок показывает уже учтенное, не дожидаясь полного набора
+		if !snapshot.Snapshot || snapshot.Processed != 1 || snapshot.Total != 2 || snapshot.TotalHeavyArea != 100 || snapshot.HeavyThreshold != 100 {
+			t.Errorf("снимок %s", line)
+		}
+	}
+}
+
+func TestCheckBboxLimits(t *testing.T) {
+	opts := collectOptions{maxBboxWidth: 100, maxBboxHeight: 50}
+	tests := []struct {
+		name string
+		bbox Bbox
+		ok   bool
+	}{
+		{"в пределах", Bbox{X1: -50, Y1: 0, X2: 50, Y2: 50}, true},
+		{"слишком широкий", Bbox{X1: 0, Y1: 0, X2: 100.5, Y2: 10}, false},
+		{"слишком высокий", Bbox{X1: 0, Y1: -30, X2: 10, Y2: 30}, false},
+		// Пустой агрегат без единого bbox не проверяется
+		{"пустой", Bbox{X1: 1, Y1: 1, X2: 0, Y2: 0}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := opts.checkBboxLimits(tt.bbox); (err == nil) != tt.ok {
+				t.Errorf("ошибка %v, ожидался успех %v", err, tt.ok)
+			}
+		})
+	}
+	if err := (collectOptions{}).checkBboxLimits(Bbox{X1: -1e9, Y1: -1e9, X2: 1e9, Y2: 1e9}); err != nil {
+		t.Errorf("без ограничений: %v", err)
+	}
+}
+
+// Слишком большой общий bbox завершает запуск с ненулевым кодом,
+// а допустимый проходит. Завершение проверяется в отдельном процессе
+func TestCollectBboxLimitsExit(t *testing.T) {
+	if os.Getenv("TEV_TEST_BBOX_LIMITS") == "1" {
+		opts := collectOptions{maxBboxWidth: 15}
+		collect(t, opts, processed(t, 0, square(0, 0, 10, 1), processOptions{}))
+		collect(t, opts, processed(t, 0, square(0, 0, 10, 1), processOptions{}), processed(t, 1, square(10, 0, 10, 1), processOptions{}))
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCollectBboxLimitsExit$")
+	cmd.Env = append(os.Environ(), "TEV_TEST_BBOX_LIMITS=1")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		t.Fatalf("ожидался код выхода 1, получено %v\n%s", err, out)
+	}
+	if !bytes.Contains(out, []byte("ширина общего bbox 20 превышает допустимую 15")) {
+		t.Errorf("нет описания ошибки в выводе:\n%s", out)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import "math"
+
+// Веса факторов оценки сложности, в сумме 1
+const (
+	complexityPointsWeight    = 0.3
+	complexityIntersectWeight = 0.4
+	complexityConcavityWeight = 0.3
+)
+
+// Число точек, при котором фактор числа точек достигает 1 (лимит ТЗ - 1М)
+const complexityMaxPoints = 1_000_000
+
+// Для колец длиннее этого проверка самопересечения выполняется по каждой
+// k-й вершине, чтобы не перебирать O(n^2) пар ребер
+const complexityIntersectLimit = 2048
+
+// ComplexityScore возвращает оценку сложности многоугольника от 0 до 1:
+//
+//	0.3 * log10(n) / log10(1М)  - число вершин n в логарифмической шкале
+//	+ 0.4 * [есть самопересечение]
+//	+ 0.3 * (1 - площадь / площадь выпуклой оболочки) - невыпуклость
+//
+// Квадрат получает около 0.03, невыпуклая самопересекающаяся фигура
+// со многими точками - больше 0.5. Результат детерминирован: для длинных
+// колец самопересечение ищется на прореженном кольце с фиксированным шагом
+func ComplexityScore(p *Polygon) float64 {
+	pts := ringPoints(p.Points)
+	n := len(pts)
+	if n < 3 {
+		return 0
+	}
+
+	points := min(math.Log10(float64(n))/math.Log10(complexityMaxPoints), 1)
+
+	var intersect float64
+	if ringSelfIntersects(decimateRing(pts, complexityIntersectLimit)) {
+		intersect = 1
+	}
+
+	// Для самопересекающихся колец формула шнурования занижает площадь,
+	// поэтому невыпуклость ограничена сверху единицей
+	var concavity float64
+	if hullArea := ringArea(ConvexHull(pts)); hullArea > 0 {
+		concavity = min(max(1-p.Area()/hullArea, 0), 1)
+	}
+
+	return complexityPointsWeight*points +
+		complexityIntersectWeight*intersect +
+		complexityConcavityWeight*concavity
+}
+
+// ringSelfIntersects проверяет, пересекаются ли несоседние ребра кольца
+func ringSelfIntersects(pts []Point) bool {
+	n := len(pts)
+	for i := 0; i < n; i++ {
+		a, b := pts[i], pts[(i+1)%n]
+		// Ребро j начинается не раньше чем через одно после i; последнее
+		// ребро соседствует с первым, поэтому для i == 0 оно пропускается
+		for j := i + 2; j < n; j++ {
+			if i == 0 && j == n-1 {
+				continue
+			}
+			if segmentsCross(a, b, pts[j], pts[(j+1)%n]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decimateRing оставляет не больше limit вершин, беря каждую k-ю
+func decimateRing(pts []Point, limit int) []Point {
+	if len(pts) <= limit {
+		return pts
+	}
+	step := (len(pts) + limit - 1) / limit
+	decimated := make([]Point, 0, limit)
+	for i := 0; i < len(pts); i += step {
+		decimated = append(decimated, pts[i])
+	}
+	return decimated
+}
+
+// ringArea возвращает площадь кольца по формуле шнурования
+func ringArea(pts []Point) float64 {
+	n := len(pts)
+	var sum float64
+	for i := 0; i < n; i++ {
+		a, b := pts[i], pts[(i+1)%n]
+		sum += a.X*b.Y - b.X*a.Y
+	}
+	return math.Abs(sum) / 2
+}
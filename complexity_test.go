@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestComplexityScore(t *testing.T) {
+	// Вклад числа вершин: 0.3 * log10(n) / 6
+	pointsTerm := func(n float64) float64 { return complexityPointsWeight * math.Log10(n) / 6 }
+	tests := []struct {
+		name string
+		p    *Polygon
+		want float64
+	}{
+		{"квадрат", polygonOf([2]float64{0, 0}, [2]float64{2, 0}, [2]float64{2, 2}, [2]float64{0, 2}), pointsTerm(4)},
+		// Площадь 7 при площади оболочки 11.5
+		{"L-образный", polygonOf([2]float64{0, 0}, [2]float64{4, 0}, [2]float64{4, 1}, [2]float64{1, 1}, [2]float64{1, 4}, [2]float64{0, 4}),
+			pointsTerm(6) + complexityConcavityWeight*(1-7/11.5)},
+		// Формула шнурования дает бабочке нулевую площадь: невыпуклость максимальна
+		{"бабочка", polygonOf([2]float64{0, 0}, [2]float64{2, 2}, [2]float64{2, 0}, [2]float64{0, 2}),
+			pointsTerm(4) + complexityIntersectWeight + complexityConcavityWeight},
+		{"отрезок", polygonOf([2]float64{0, 0}, [2]float64{1, 1}), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComplexityScore(tt.p); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("ComplexityScore = %g, ожидалось %g", got, tt.want)
+			}
+		})
+	}
+
+	// Для длинных колец оценка тоже в пределах [0, 1]
+	if got := ComplexityScore(regularPolygon(10_000, 100)); got < 0 || got > 1 {
+		t.Errorf("оценка %g вне [0, 1]", got)
+	}
+}
+
+func TestRingSelfIntersects(t *testing.T) {
+	tests := []struct {
+		name string
+		p    *Polygon
+		want bool
+	}{
+		{"квадрат", polygonOf([2]float64{0, 0}, [2]float64{2, 0}, [2]float64{2, 2}, [2]float64{0, 2}), false},
+		{"бабочка", polygonOf([2]float64{0, 0}, [2]float64{2, 2}, [2]float64{2, 0}, [2]float64{0, 2}), true},
+		// Соседние ребра, включая последнее и первое, делят вершину и пересечением не считаются
+		{"треугольник", polygonOf([2]float64{0, 0}, [2]float64{4, 0}, [2]float64{0, 3}), false},
+		{"звезда", polygonOf([2]float64{0, 3}, [2]float64{2, -3}, [2]float64{-3, 1}, [2]float64{3, 1}, [2]float64{-2, -3}), true},
+	}
+	for _, tt := range tests {
+		if got := ringSelfIntersects(ringPoints(tt.p.Points)); got != tt.want {
+			t.Errorf("%s: самопересечение %v, ожидалось %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecimateRing(t *testing.T) {
+	pts := ringPoints(regularPolygon(10, 1).Points)
+	got := decimateRing(pts, 4)
+	if want := []Point{pts[0], pts[3], pts[6], pts[9]}; !slices.Equal(got, want) {
+		t.Errorf("decimateRing = %v, ожидалось %v", got, want)
+	}
+	if got := decimateRing(pts, 10); len(got) != 10 {
+		t.Errorf("кольцо в пределах лимита прорежено до %d вершин", len(got))
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ContainingPolygons возвращает индексы тяжелых многоугольников, содержащих
+// точку p, по возрастанию. Проверяются выводимые точки, то есть уже после
+// обрезки и упрощения. Точка на границе считается содержащейся, ломаные
+// не проверяются
+func ContainingPolygons(heavies []*HeavyPolygon, p Point) []int {
+	var indices []int
+	for _, heavy := range heavies {
+		if heavy.Type == shapeTypePolyline {
+			continue
+		}
+		ring := ringPoints(heavy.Points)
+		if len(ring) > 0 && pointInRing(p.X, p.Y, ring) {
+			indices = append(indices, heavy.Index)
+		}
+	}
+	slices.Sort(indices)
+	return indices
+}
+
+// parsePoint разбирает точку в виде "x,y"
+func parsePoint(s string) (Point, error) {
+	xs, ys, ok := strings.Cut(s, ",")
+	if !ok {
+		return Point{}, fmt.Errorf("ожидается x,y, получено %q", s)
+	}
+	x, err := strconv.ParseFloat(strings.TrimSpace(xs), 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("некорректная координата %q: %v", xs, err)
+	}
+	y, err := strconv.ParseFloat(strings.TrimSpace(ys), 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("некорректная координата %q: %v", ys, err)
+	}
+	return Point{X: x, Y: y}, nil
+}
@@ -0,0 +1,8 @@
+package main
+
+// Допустимые значения -coord_output. Сам формат координат в JSON
+// задается через polygon.FloatCoords
+const (
+	coordOutputInt   = "int"
+	coordOutputFloat = "float"
+)
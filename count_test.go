@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kscvrmn/tev_test/polygontest"
+)
+
+// Число многоугольников с -count_url задает, сколько их будет загружено
+func TestCountURLDrivesRun(t *testing.T) {
+	const count = 7
+	server := polygontest.NewServer(count, nil)
+	defer server.Close()
+	transport := &countingTransport{RoundTripper: server.Client().Transport}
+	client := &http.Client{Transport: transport}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	total, err := fetchPolygonCount(ctx, client, server.URL+"/count", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != count {
+		t.Fatalf("число многоугольников %d, ожидалось %d", total, count)
+	}
+
+	resCh := make(chan Result, 1)
+	f := newTestFetcher(t, server.PolygonURL(), client)
+	runPool(ctx, total, total, nil, resCh, f, collectOptions{}, newFetchLimiter(0, 0, 0), nil, newPauseGate())
+	result := <-resCh
+	if result.Partial || result.Processed != count || result.Total != count {
+		t.Errorf("partial %v, обработано %d из %d, ожидалось %d", result.Partial, result.Processed, result.Total, count)
+	}
+	// Запрос числа и по одному на каждый многоугольник
+	if got := transport.requests.Load(); got != count+1 {
+		t.Errorf("%d запросов, ожидалось %d", got, count+1)
+	}
+}
+
+func TestFetchPolygonCountInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"ноль", http.StatusOK, "0"},
+		{"отрицательное", http.StatusOK, "-3"},
+		{"не число", http.StatusOK, "seven"},
+		{"дробное", http.StatusOK, "7.5"},
+		{"ошибка сервера", http.StatusInternalServerError, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			if n, err := fetchPolygonCount(context.Background(), server.Client(), server.URL, nil); err == nil {
+				t.Errorf("ответ %q принят как %d", tt.body, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import "math"
+
+// Максимальное разрешение сетки покрытия: сетка хранится целиком в памяти
+const maxCoverageGrid = 4096
+
+// bboxCoverage растеризует локальные bbox на сетку n x n поверх общего bbox
+// и возвращает долю покрытых ячеек. Ячейка считается покрытой, если ее центр
+// попадает хотя бы в один локальный bbox (границы включительно)
+func bboxCoverage(global Bbox, boxes []Bbox, n int) float64 {
+	if n <= 0 || len(boxes) == 0 || global.X1 > global.X2 || global.Y1 > global.Y2 {
+		return 0
+	}
+
+	covered := make([]bool, n*n)
+	count := 0
+	for _, b := range boxes {
+		i1, i2 := cellRange(b.X1, b.X2, global.X1, global.X2, n)
+		j1, j2 := cellRange(b.Y1, b.Y2, global.Y1, global.Y2, n)
+		for j := j1; j <= j2; j++ {
+			for i := i1; i <= i2; i++ {
+				if !covered[j*n+i] {
+					covered[j*n+i] = true
+					count++
+				}
+			}
+		}
+	}
+	return float64(count) / float64(n*n)
+}
+
+// cellRange возвращает диапазон индексов ячеек вдоль одной оси, центры которых
+// лежат в отрезке [lo, hi]. Если диапазон пуст, first > last
+func cellRange(lo, hi, min, max float64, n int) (first, last int) {
+	size := (max - min) / float64(n)
+	if size == 0 {
+		// Вырожденный общий bbox: все центры совпадают с min
+		if lo <= min && min <= hi {
+			return 0, n - 1
+		}
+		return 0, -1
+	}
+
+	// Центр ячейки i: min + (i + 0.5) * size
+	first = int(math.Ceil((lo-min)/size - 0.5))
+	last = int(math.Floor((hi-min)/size - 0.5))
+	if first < 0 {
+		first = 0
+	}
+	if last > n-1 {
+		last = n - 1
+	}
+	return first, last
+}
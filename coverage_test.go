@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBboxCoverage(t *testing.T) {
+	global := Bbox{X1: 0, Y1: 0, X2: 10, Y2: 10}
+	tests := []struct {
+		name  string
+		boxes []Bbox
+		grid  int
+		want  float64
+	}{
+		// Две противоположные четверти покрывают половину общего bbox
+		{"две четверти", []Bbox{{X1: 0, Y1: 0, X2: 5, Y2: 5}, {X1: 5, Y1: 5, X2: 10, Y2: 10}}, 10, 0.5},
+		{"две четверти, грубая сетка", []Bbox{{X1: 0, Y1: 0, X2: 5, Y2: 5}, {X1: 5, Y1: 5, X2: 10, Y2: 10}}, 2, 0.5},
+		{"весь bbox", []Bbox{global}, 16, 1},
+		// Пересечение считается один раз
+		{"перекрытие", []Bbox{{X1: 0, Y1: 0, X2: 10, Y2: 5}, {X1: 0, Y1: 0, X2: 10, Y2: 5}}, 10, 0.5},
+		{"нет bbox", nil, 10, 0},
+		{"без сетки", []Bbox{global}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bboxCoverage(global, tt.boxes, tt.grid); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("покрытие %g, ожидалось %g", got, tt.want)
+			}
+		})
+	}
+}
+
+// Покрытие считается после агрегации по локальным bbox всех многоугольников
+func TestCollectGlobalCoverage(t *testing.T) {
+	result := collect(t, collectOptions{coverageGrid: 20},
+		processed(t, 0, square(0, 0, 5, 1), processOptions{}),
+		processed(t, 1, square(5, 5, 5, 1), processOptions{}),
+	)
+	if result.GlobalCoverage == nil || math.Abs(*result.GlobalCoverage-0.5) > 1e-9 {
+		t.Errorf("покрытие %v, ожидалось 0.5", result.GlobalCoverage)
+	}
+	if result := collect(t, collectOptions{}, processed(t, 0, square(0, 0, 5, 1), processOptions{})); result.GlobalCoverage != nil {
+		t.Errorf("покрытие посчитано без -coverage_grid: %g", *result.GlobalCoverage)
+	}
+}
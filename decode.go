@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Типы содержимого, которые мы готовы принимать от сервера.
+// JSON остается форматом по умолчанию, MessagePack используется, если сервер его поддерживает
+const (
+	contentTypeJSON    = "application/json"
+	contentTypeMsgpack = "application/msgpack"
+	acceptHeader       = contentTypeMsgpack + ", " + contentTypeJSON + ";q=0.9"
+)
+
+// Буферы большего размера в пул не возвращаем, чтобы один огромный ответ
+// не удерживал память до конца работы скрипта
+const maxPooledBufferSize = 64 << 20
+
+// Пул буферов для чтения тел ответов. При большом числе загрузок
+// io.ReadAll на каждый запрос создает заметную нагрузку на GC
+var bodyBufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// Срезы большего размера в пул не возвращаем по той же причине, что и буферы
+const maxPooledPoints = maxPooledBufferSize / 16
+
+// Пул срезов точек для разбора ответов. Каждая загрузка иначе выделяет
+// новый []WeightedPoint, что при больших многоугольниках нагружает GC.
+// Срезы в пуле всегда обнулены на всю емкость
+var pointsPool = sync.Pool{
+	New: func() any { return new([]WeightedPoint) },
+}
+
+// getPoints возвращает пустой срез точек из пула
+func getPoints() []WeightedPoint {
+	return (*pointsPool.Get().(*[]WeightedPoint))[:0]
+}
+
+// releasePoints возвращает срез в пул. Вызывающий гарантирует, что на точки
+// больше никто не ссылается: срез тяжелого многоугольника возвращать нельзя
+func releasePoints(points []WeightedPoint) {
+	if cap(points) == 0 || cap(points) > maxPooledPoints {
+		return
+	}
+	points = points[:cap(points)]
+	clear(points)
+	points = points[:0]
+	pointsPool.Put(&points)
+}
+
+// readBody читает тело ответа в буфер из пула. Вызывающий обязан вернуть
+// буфер через releaseBody, в том числе при ошибке чтения
+func readBody(r io.Reader) (*bytes.Buffer, error) {
+	buf := bodyBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	_, err := buf.ReadFrom(r)
+	return buf, err
+}
+
+func releaseBody(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bodyBufferPool.Put(buf)
+}
+
+// decodeShape разбирает тело ответа в зависимости от Content-Type.
+// Неизвестный или отсутствующий тип считается JSON для совместимости со старыми серверами.
+// Вид фигуры (многоугольник или ломаная) определяется полем "type".
+// Декодеры копируют данные, поэтому после возврата body можно переиспользовать.
+// strict включает строгий разбор JSON, см. decodeShapeStream
+func decodeShape(contentType string, body []byte, strict bool) (Shape, error) {
+	if isMsgpack(contentType) {
+		return decodeMsgpackShape(body)
+	}
+	return decodeShapeStream(bytes.NewReader(body), nil, strict)
+}
+
+// decodeShapeStream разбирает JSON-тело прямо из потока, не буферизуя его
+// целиком: для многоугольников до 1М точек это вдвое снижает пиковую память.
+// Точки разбираются в points, если его емкости хватает.
+// По умолчанию разбор мягкий: неизвестные поля и данные после JSON-значения
+// игнорируются. В строгом режиме (-strict_json) и то и другое - ошибка:
+// сервер, дописавший лишние байты, скорее всего прислал поврежденный ответ
+func decodeShapeStream(r io.Reader, points []WeightedPoint, strict bool) (Shape, error) {
+	var raw struct {
+		Type   string          `json:"type"`
+		Points []WeightedPoint `json:"points"`
+	}
+	raw.Points = points[:0]
+	dec := json.NewDecoder(r)
+	if strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("ошибка разбора JSON: %v", err)
+	}
+	if strict {
+		if _, err := dec.Token(); err != io.EOF {
+			return nil, fmt.Errorf("ошибка разбора JSON: лишние данные после значения")
+		}
+	}
+	return newShape(raw.Type, raw.Points)
+}
+
+// readErrRecorder запоминает ошибку чтения, чтобы отличить обрыв соединения,
+// после которого запрос можно повторить, от некорректного JSON
+type readErrRecorder struct {
+	r   io.Reader
+	err error
+}
+
+func (rr *readErrRecorder) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	if err != nil && err != io.EOF {
+		rr.err = err
+	}
+	return n, err
+}
+
+// limitBody ограничивает чтение тела ответа. Читается на байт больше предела,
+// чтобы отличить ответ ровно предельного размера от слишком большого:
+// после чтения N <= 0 означает, что предел превышен
+func limitBody(r io.Reader, limit int64) *io.LimitedReader {
+	return &io.LimitedReader{R: r, N: limit + 1}
+}
+
+// isGzip сообщает, сжато ли тело ответа gzip. Сервер, не поддерживающий
+// сжатие, присылает тело без Content-Encoding, и оно читается как есть
+func isGzip(contentEncoding string) bool {
+	return strings.EqualFold(strings.TrimSpace(contentEncoding), "gzip")
+}
+
+func isMsgpack(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == contentTypeMsgpack || mediaType == "application/x-msgpack"
+}
+
+// Промежуточное представление фигуры для MessagePack: теги полей Point
+// и WeightedPoint описаны только для JSON
+type msgpackShape struct {
+	Type   string `msgpack:"type"`
+	Points []struct {
+		X      float64 `msgpack:"x"`
+		Y      float64 `msgpack:"y"`
+		Weight float64 `msgpack:"weight"`
+	} `msgpack:"points"`
+}
+
+func decodeMsgpackShape(body []byte) (Shape, error) {
+	var raw msgpackShape
+	if err := msgpack.NewDecoder(bytes.NewReader(body)).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("ошибка разбора MessagePack: %v", err)
+	}
+
+	points := make([]WeightedPoint, len(raw.Points))
+	for i, p := range raw.Points {
+		points[i] = WeightedPoint{
+			Point:  Point{X: p.X, Y: p.Y},
+			Weight: p.Weight,
+		}
+	}
+	return newShape(raw.Type, points)
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// decodeShapeCoords разбирает JSON фигуры, читая у точек только x и y.
+// Остальные поля точек (в том числе вес) пропускаются без разбора значений,
+// что заметно дешевле encoding/json на телах из миллионов точек.
+// Веса возвращенных точек равны нулю, поэтому путь годится лишь для -bbox_only.
+// Результат и ошибки совпадают с мягким разбором decodeShapeStream: ключи
+// сравниваются без учета регистра, повторный ключ перекрывает прежний,
+// null оставляет поле нулевым, а данные после объекта игнорируются
+func decodeShapeCoords(body []byte) (Shape, error) {
+	s := &coordScanner{data: body}
+	var shapeType string
+	var points []WeightedPoint
+
+	var err error
+	if !s.null() {
+		err = s.object(func(key string) error {
+			switch {
+			case strings.EqualFold(key, "type"):
+				if s.null() {
+					return nil
+				}
+				v, err := s.str()
+				shapeType = v
+				return err
+			case strings.EqualFold(key, "points"):
+				if s.null() {
+					points = nil
+					return nil
+				}
+				// Как и encoding/json, повторный ключ points разбирается
+				// поверх точек из предыдущего
+				points = points[:0]
+				return s.array(func() error {
+					if len(points) < cap(points) {
+						points = points[:len(points)+1]
+					} else {
+						points = append(points, WeightedPoint{})
+					}
+					return s.point(&points[len(points)-1])
+				})
+			}
+			return s.skip()
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ошибка разбора JSON: %v", err)
+	}
+	return newShape(shapeType, points)
+}
+
+// point читает координаты точки. Вес не разбирается, но, как и в
+// encoding/json, должен быть числом в пределах float64 или null
+func (s *coordScanner) point(p *WeightedPoint) error {
+	if s.null() {
+		return nil
+	}
+	return s.object(func(key string) error {
+		var err error
+		switch {
+		case s.null():
+		case strings.EqualFold(key, "x"):
+			p.X, err = s.number()
+		case strings.EqualFold(key, "y"):
+			p.Y, err = s.number()
+		case strings.EqualFold(key, "weight"):
+			var span []byte
+			if span, err = s.numberSpan(); err == nil && (len(span) > 300 || bytes.ContainsAny(span, "eE")) {
+				// Без экспоненты и сотен цифр число не выходит за пределы float64
+				_, err = s.parseFloat(span)
+			}
+		default:
+			err = s.skip()
+		}
+		return err
+	})
+}
+
+// coordScanner - минимальный потоковый разборщик JSON поверх среза байт
+type coordScanner struct {
+	data []byte
+	pos  int
+}
+
+func (s *coordScanner) errorf(format string, args ...any) error {
+	return fmt.Errorf("позиция %d: "+format, append([]any{s.pos}, args...)...)
+}
+
+func (s *coordScanner) ws() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\n', '\r':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (s *coordScanner) expect(c byte) error {
+	s.ws()
+	if s.pos >= len(s.data) || s.data[s.pos] != c {
+		return s.errorf("ожидался символ %q", c)
+	}
+	s.pos++
+	return nil
+}
+
+// peek возвращает следующий значимый символ, 0 - конец данных
+func (s *coordScanner) peek() byte {
+	s.ws()
+	if s.pos >= len(s.data) {
+		return 0
+	}
+	return s.data[s.pos]
+}
+
+// object вызывает field для каждого ключа; field обязан прочитать значение
+func (s *coordScanner) object(field func(key string) error) error {
+	if err := s.expect('{'); err != nil {
+		return err
+	}
+	if s.peek() == '}' {
+		s.pos++
+		return nil
+	}
+	for {
+		key, err := s.str()
+		if err != nil {
+			return err
+		}
+		if err := s.expect(':'); err != nil {
+			return err
+		}
+		if err := field(key); err != nil {
+			return err
+		}
+		switch s.peek() {
+		case ',':
+			s.pos++
+		case '}':
+			s.pos++
+			return nil
+		default:
+			return s.errorf("ожидалась ',' или '}'")
+		}
+	}
+}
+
+// array вызывает elem для каждого элемента; elem обязан прочитать значение
+func (s *coordScanner) array(elem func() error) error {
+	if err := s.expect('['); err != nil {
+		return err
+	}
+	if s.peek() == ']' {
+		s.pos++
+		return nil
+	}
+	for {
+		if err := elem(); err != nil {
+			return err
+		}
+		switch s.peek() {
+		case ',':
+			s.pos++
+		case ']':
+			s.pos++
+			return nil
+		default:
+			return s.errorf("ожидалась ',' или ']'")
+		}
+	}
+}
+
+// str читает строку. Ключи и тип фигуры обычно не содержат экранирования
+// и не-ASCII символов, остальные строки разбираются через encoding/json
+func (s *coordScanner) str() (string, error) {
+	if err := s.expect('"'); err != nil {
+		return "", err
+	}
+	start := s.pos
+	slow := false
+	for ; s.pos < len(s.data); s.pos++ {
+		switch c := s.data[s.pos]; {
+		case c == '\\':
+			slow = true
+			s.pos++
+		case c == '"':
+			s.pos++
+			if !slow {
+				return string(s.data[start : s.pos-1]), nil
+			}
+			var v string
+			if err := json.Unmarshal(s.data[start-1:s.pos], &v); err != nil {
+				return "", s.errorf("некорректная строка")
+			}
+			return v, nil
+		case c < 0x20:
+			return "", s.errorf("управляющий символ в строке")
+		case c >= utf8.RuneSelf:
+			slow = true
+		}
+	}
+	return "", s.errorf("незакрытая строка")
+}
+
+// number читает число и проверяет, что оно помещается в float64
+func (s *coordScanner) number() (float64, error) {
+	span, err := s.numberSpan()
+	if err != nil {
+		return 0, err
+	}
+	return s.parseFloat(span)
+}
+
+func (s *coordScanner) parseFloat(span []byte) (float64, error) {
+	v, err := strconv.ParseFloat(string(span), 64)
+	if err != nil {
+		return 0, s.errorf("число %s вне диапазона float64", span)
+	}
+	return v, nil
+}
+
+// numberSpan читает число по грамматике JSON, не вычисляя его значение:
+// -?(0|[1-9][0-9]*)(.[0-9]+)?([eE][+-]?[0-9]+)?
+func (s *coordScanner) numberSpan() ([]byte, error) {
+	s.ws()
+	start := s.pos
+	s.consume('-')
+	if !s.consume('0') && s.digits() == 0 {
+		return nil, s.errorf("ожидалось число")
+	}
+	if s.consume('.') && s.digits() == 0 {
+		return nil, s.errorf("ожидались цифры после точки")
+	}
+	if s.consume('e') || s.consume('E') {
+		if !s.consume('+') {
+			s.consume('-')
+		}
+		if s.digits() == 0 {
+			return nil, s.errorf("ожидались цифры экспоненты")
+		}
+	}
+	return s.data[start:s.pos], nil
+}
+
+// consume пропускает символ c, если он следующий
+func (s *coordScanner) consume(c byte) bool {
+	if s.pos < len(s.data) && s.data[s.pos] == c {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+// digits пропускает десятичные цифры и возвращает их число
+func (s *coordScanner) digits() int {
+	start := s.pos
+	for s.pos < len(s.data) && s.data[s.pos] >= '0' && s.data[s.pos] <= '9' {
+		s.pos++
+	}
+	return s.pos - start
+}
+
+// literal пропускает слово true, false или null, если оно следующее
+func (s *coordScanner) literal(word string) bool {
+	s.ws()
+	if !bytes.HasPrefix(s.data[s.pos:], []byte(word)) {
+		return false
+	}
+	s.pos += len(word)
+	return true
+}
+
+// null пропускает null, если это следующее значение
+func (s *coordScanner) null() bool {
+	return s.literal("null")
+}
+
+// skip пропускает произвольное значение, не разбирая его
+func (s *coordScanner) skip() error {
+	c := s.peek()
+	switch c {
+	case '{':
+		return s.object(func(string) error { return s.skip() })
+	case '[':
+		return s.array(s.skip)
+	case '"':
+		_, err := s.str()
+		return err
+	case 0:
+		return s.errorf("неожиданный конец данных")
+	case 't':
+		if s.literal("true") {
+			return nil
+		}
+	case 'f':
+		if s.literal("false") {
+			return nil
+		}
+	case 'n':
+		if s.null() {
+			return nil
+		}
+	default:
+		_, err := s.numberSpan()
+		return err
+	}
+	return s.errorf("неожиданный символ %q", c)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// decodeShapeCoords должен вести себя как мягкий разбор через encoding/json:
+// тот же вид фигуры и координаты либо ошибка в обоих случаях
+func TestDecodeShapeCoordsMatchesJSON(t *testing.T) {
+	inputs := []string{
+		`{"points":[{"x":1,"y":2,"weight":3},{"x":-4.5,"y":6e2,"weight":0.5}]}`,
+		`{"type":"polyline","points":[{"x":1,"y":2},{"x":3,"y":4}]}`,
+		`{"type":"polygon","points":[]}`,
+		`{}`,
+		` {"points":[{"x":1,"y":2}]} `,
+		// Мягкий разбор игнорирует данные после объекта
+		`{"points":[{"x":1,"y":2}]} {"points":[]}`,
+		`{"points":[{"x":1,"y":2}]}garbage`,
+		// null оставляет поле нулевым
+		`null`,
+		`{"points":null}`,
+		`{"type":null,"points":[{"x":1,"y":2}]}`,
+		`{"points":[null,{"x":null,"y":2,"weight":null}]}`,
+		// Ключи сравниваются без учета регистра
+		`{"Points":[{"X":1,"Y":2,"Weight":3}]}`,
+		`{"TYPE":"polyline","POINTS":[{"x":1,"y":2}]}`,
+		`{"pointſ":[{"x":1,"y":2}]}`,
+		// Повторный ключ перекрывает прежний
+		`{"points":[{"x":1,"y":2,"x":5}]}`,
+		`{"points":[{"x":1,"y":2},{"x":3,"y":4}],"points":[{"x":7}]}`,
+		`{"points":[{"x":1,"y":2}],"points":null}`,
+		`{"type":"polyline","type":"polygon","points":[]}`,
+		// Экранирование и не-ASCII в ключах и значениях
+		`{"type":"poly\u006cine","\u0070oints":[{"x":1,"y":2}]}`,
+		`{"type":"poly\nline","points":[]}`,
+		`{"метка":"значение","points":[{"x":1,"y":2,"имя":"точка"}]}`,
+		// Посторонние поля любых типов
+		`{"id":7,"tags":["a",{"b":[1,2,{}]}],"ok":true,"no":false,"nil":null,"points":[{"x":1,"y":2,"extra":{"z":[1e5,-0.0]}}]}`,
+		// Ошибки
+		``,
+		`[]`,
+		`"points"`,
+		`{"points":{}}`,
+		`{"points":[1]}`,
+		`{"points":[{"x":"1","y":2}]}`,
+		`{"points":[{"x":true}]}`,
+		`{"points":[{"x":1,"y":2,"weight":"3"}]}`,
+		`{"points":[{"x":1,"y":2,"weight":1e400}]}`,
+		`{"points":[{"x":1e400,"y":2}]}`,
+		`{"points":[{"x":01}]}`,
+		`{"points":[{"x":1.}]}`,
+		`{"points":[{"x":.5}]}`,
+		`{"points":[{"x":+1}]}`,
+		`{"points":[{"x":1e}]}`,
+		`{"points":[{"x":1,"extra":tru}]}`,
+		`{"points":[{"x":1,"extra":nul}]}`,
+		`{"points":[{"x":1,"extra":-}]}`,
+		`{"type":5,"points":[]}`,
+		`{"type":"circle","points":[]}`,
+		`{"type":"a` + "\x01" + `b","points":[]}`,
+		`{"points":[{"x":1,"y":2}]`,
+		`{"points":[{"x":1,"y":2},]}`,
+		`{"points":[{"x":1 "y":2}]}`,
+		`{"points":[{"x":1,"y":2}],}`,
+		`{points:[]}`,
+	}
+	for _, input := range inputs {
+		want, wantErr := decodeShapeStream(bytes.NewReader([]byte(input)), nil, false)
+		got, gotErr := decodeShapeCoords([]byte(input))
+		if (gotErr != nil) != (wantErr != nil) {
+			t.Errorf("%s: ошибка %v, encoding/json: %v", input, gotErr, wantErr)
+			continue
+		}
+		if wantErr != nil {
+			continue
+		}
+		if fmt.Sprintf("%T", got) != fmt.Sprintf("%T", want) {
+			t.Errorf("%s: фигура %T, encoding/json: %T", input, got, want)
+		}
+		gotPoints, wantPoints := got.Vertices(), want.Vertices()
+		if len(gotPoints) != len(wantPoints) {
+			t.Errorf("%s: точек %d, encoding/json: %d", input, len(gotPoints), len(wantPoints))
+			continue
+		}
+		for i := range gotPoints {
+			if gotPoints[i].Point != wantPoints[i].Point {
+				t.Errorf("%s: точка %d %+v, encoding/json: %+v", input, i, gotPoints[i].Point, wantPoints[i].Point)
+			}
+		}
+	}
+}
+
+// BenchmarkDecodeShape сравнивает разбор только координат с encoding/json
+// на многоугольнике из 10000 точек
+func BenchmarkDecodeShape(b *testing.B) {
+	body := largePolygonJSON(10000)
+	b.Run("coords", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for range b.N {
+			if _, err := decodeShapeCoords(body); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("json", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for range b.N {
+			if _, err := decodeShape(contentTypeJSON, body, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
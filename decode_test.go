@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/kscvrmn/tev_test/polygon"
+)
+
+// testPolygon - многоугольник с дробными координатами и весами, чтобы
+// расхождение форматов не пряталось за целыми числами
+var testPolygon = map[string]any{
+	"points": []map[string]any{
+		{"x": 0.5, "y": 0, "weight": 60.25},
+		{"x": 10, "y": 0.5, "weight": 20},
+		{"x": 10, "y": 10, "weight": 20.5},
+		{"x": 0, "y": 10.25, "weight": 1},
+	},
+}
+
+func TestMsgpackDecodesLikeJSON(t *testing.T) {
+	jsonBody, err := json.Marshal(testPolygon)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgpackBody, err := msgpack.Marshal(testPolygon)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Сервер отвечает MessagePack, только если клиент его принимает
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("json") || !strings.Contains(r.Header.Get("Accept"), contentTypeMsgpack) {
+			w.Header().Set("Content-Type", contentTypeJSON)
+			w.Write(jsonBody)
+			return
+		}
+		w.Header().Set("Content-Type", contentTypeMsgpack)
+		w.Write(msgpackBody)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	fromJSON := newTestFetcher(t, server.URL+"?json", server.Client()).fetchAndProcessPolygon(ctx, 0)
+	fromMsgpack := newTestFetcher(t, server.URL, server.Client()).fetchAndProcessPolygon(ctx, 0)
+	for _, r := range []PolygonResult{fromJSON, fromMsgpack} {
+		if r.err != nil {
+			t.Fatalf("ошибка загрузки: %v", r.err)
+		}
+	}
+
+	if fromMsgpack.weight != fromJSON.weight {
+		t.Errorf("вес: msgpack %g, json %g", fromMsgpack.weight, fromJSON.weight)
+	}
+	if fromMsgpack.localBbox != fromJSON.localBbox {
+		t.Errorf("bbox: msgpack %+v, json %+v", fromMsgpack.localBbox, fromJSON.localBbox)
+	}
+	if fromMsgpack.area != fromJSON.area {
+		t.Errorf("площадь: msgpack %g, json %g", fromMsgpack.area, fromJSON.area)
+	}
+	if fromMsgpack.isHeavy != fromJSON.isHeavy || !fromMsgpack.isHeavy {
+		t.Errorf("тяжелый: msgpack %v, json %v, ожидался true", fromMsgpack.isHeavy, fromJSON.isHeavy)
+	}
+	got, want := fromMsgpack.polygon.Points, fromJSON.polygon.Points
+	if len(got) != len(want) {
+		t.Fatalf("число точек: msgpack %d, json %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("точка %d: msgpack %+v, json %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestIsMsgpack(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"application/msgpack", true},
+		{"application/x-msgpack", true},
+		{"application/msgpack; charset=binary", true},
+		{"application/json", false},
+		{"", false},
+		{"not a media type;;", false},
+	}
+	for _, tt := range tests {
+		if got := isMsgpack(tt.contentType); got != tt.want {
+			t.Errorf("isMsgpack(%q) = %v, ожидалось %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+// largePolygonJSON возвращает JSON многоугольника из n точек
+func largePolygonJSON(n int) []byte {
+	var b bytes.Buffer
+	b.WriteString(`{"points":[`)
+	for i := range n {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"x":%d,"y":%d,"weight":0.5}`, i%1000, i/1000)
+	}
+	b.WriteString(`]}`)
+	return b.Bytes()
+}
+
+// BenchmarkProcessPolygon измеряет загрузку и обработку легкого многоугольника
+// из 10000 точек: потоковый разбор JSON в срез из пула и разбор из буфера
+// из пула (-bbox_only). Выделения памяти на загрузку показывает -benchmem
+func BenchmarkProcessPolygon(b *testing.B) {
+	body := largePolygonJSON(10000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentTypeJSON)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	for _, bm := range []struct {
+		name     string
+		bboxOnly bool
+	}{
+		{"stream", false},
+		{"buffer", true},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			f := newFetcher(
+				processOptions{urls: []string{server.URL}, poolPoints: true, bboxOnly: bm.bboxOnly},
+				polygon.WithHTTPClient(server.Client()),
+				polygon.WithRetries(0),
+				polygon.WithHeavyThreshold(1e9),
+			)
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.SetBytes(int64(len(body)))
+			for range b.N {
+				if r := f.fetchAndProcessPolygon(ctx, 0); r.err != nil {
+					b.Fatal(r.err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDecodeResponse сравнивает выделения памяти при разборе большого
+// ответа из потока с ограничением -max_response_bytes и при чтении тела
+// целиком с json.Unmarshal
+func BenchmarkDecodeResponse(b *testing.B) {
+	body := largePolygonJSON(100000)
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for range b.N {
+			if _, err := decodeShapeStream(limitBody(bytes.NewReader(body), 1<<30), nil, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("readall", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for range b.N {
+			data, err := io.ReadAll(bytes.NewReader(body))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var p Polygon
+			if err := json.Unmarshal(data, &p); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkPointsPool измеряет загрузку легкого многоугольника со срезами
+// точек из пула и без него: с пулом срез не выделяется на каждую загрузку
+func BenchmarkPointsPool(b *testing.B) {
+	body := largePolygonJSON(10000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentTypeJSON)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	for _, bm := range []struct {
+		name string
+		pool bool
+	}{
+		{"pool", true},
+		{"nopool", false},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			f := newTestFetcher(b, server.URL, server.Client(), polygon.WithHeavyThreshold(1e9))
+			f.opts.poolPoints = bm.pool
+			ctx := context.Background()
+			b.ReportAllocs()
+			b.SetBytes(int64(len(body)))
+			for range b.N {
+				if r := f.fetchAndProcessPolygon(ctx, 0); r.err != nil {
+					b.Fatal(r.err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkReadBody сравнивает чтение тела в буфер из пула с io.ReadAll
+func BenchmarkReadBody(b *testing.B) {
+	body := largePolygonJSON(10000)
+	b.Run("pool", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			buf, err := readBody(bytes.NewReader(body))
+			if err != nil {
+				b.Fatal(err)
+			}
+			releaseBody(buf)
+		}
+	})
+	b.Run("readall", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			if _, err := io.ReadAll(bytes.NewReader(body)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// Буферы и срезы из пулов переиспользуются между разборами, поэтому данные
+// прошлого, более длинного ответа не должны попадать в следующий
+func TestPooledDecodeReuse(t *testing.T) {
+	bodies := [][]byte{
+		largePolygonJSON(50),
+		[]byte(`{"points":[{"x":1,"y":2,"weight":3}]}`),
+		largePolygonJSON(20),
+		[]byte(`{"points":[]}`),
+	}
+	for round := range 3 {
+		for i, body := range bodies {
+			want, err := decodeShape(contentTypeJSON, body, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			buf, err := readBody(bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), body) {
+				t.Fatalf("раунд %d, тело %d: буфер из пула содержит лишние данные", round, i)
+			}
+			fromBuffer, err := decodeShape(contentTypeJSON, buf.Bytes(), false)
+			releaseBody(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fromStream, err := decodeShapeStream(bytes.NewReader(body), getPoints(), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, got := range []Shape{fromBuffer, fromStream} {
+				if !slices.Equal(got.Vertices(), want.Vertices()) {
+					t.Fatalf("раунд %d, тело %d: точки %v, ожидались %v", round, i, got.Vertices(), want.Vertices())
+				}
+			}
+			releasePoints(fromStream.Vertices())
+		}
+	}
+}
+
+// Строгий разбор отклоняет неизвестные поля и данные после JSON-значения,
+// мягкий их пропускает. Поврежденный JSON отклоняется в обоих режимах
+func TestDecodeStrictJSON(t *testing.T) {
+	const points = `"points":[{"x":0,"y":0,"weight":1},{"x":1,"y":0,"weight":1},{"x":0,"y":1,"weight":1}]`
+	tests := []struct {
+		name      string
+		body      string
+		strictErr string
+		lenientOK bool
+	}{
+		{"корректный", `{` + points + `}`, "", true},
+		{"неизвестное поле", `{"id":7,` + points + `}`, `unknown field "id"`, true},
+		{"лишние данные", `{` + points + `}garbage`, "лишние данные", true},
+		{"два значения", `{` + points + `}{}`, "лишние данные", true},
+		{"пробелы в конце", `{` + points + "}\n\t ", "", true},
+		{"обрезанный", `{` + points[:20], "unexpected EOF", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeShape(contentTypeJSON, []byte(tt.body), true)
+			if tt.strictErr == "" && err != nil || tt.strictErr != "" && (err == nil || !strings.Contains(err.Error(), tt.strictErr)) {
+				t.Errorf("строгий разбор: ошибка %v, ожидалась %q", err, tt.strictErr)
+			}
+			shape, err := decodeShape(contentTypeJSON, []byte(tt.body), false)
+			if (err == nil) != tt.lenientOK {
+				t.Errorf("мягкий разбор: ошибка %v", err)
+			}
+			if err == nil && len(shape.Vertices()) != 3 {
+				t.Errorf("мягкий разбор: %d вершин, ожидалось 3", len(shape.Vertices()))
+			}
+		})
+	}
+}
+
+// -strict_json действует и на загрузку с сервера
+func TestFetchStrictJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"points":[{"x":0,"y":0,"weight":1}]}` + "\n" + `{"points":[]}`))
+	}))
+	defer server.Close()
+
+	f := newTestFetcher(t, server.URL, server.Client())
+	if r := f.fetchAndProcessPolygon(context.Background(), 0); r.err != nil {
+		t.Errorf("без -strict_json: %v", r.err)
+	}
+	f.opts.strictJSON = true
+	if r := f.fetchAndProcessPolygon(context.Background(), 0); r.err == nil || !strings.Contains(r.err.Error(), "лишние данные") {
+		t.Errorf("с -strict_json: ошибка %v, ожидались лишние данные", r.err)
+	}
+}
+
+// Веса разбираются в float64 без потери точности и в JSON, и в MessagePack
+func TestDecodeWeightFloat64(t *testing.T) {
+	const weight = 0.1 + 1<<30
+	jsonBody := []byte(fmt.Sprintf(`{"points":[{"x":0,"y":0,"weight":%v}]}`, weight))
+	msgpackBody, err := msgpack.Marshal(map[string]any{"points": []map[string]any{{"x": 0, "y": 0, "weight": weight}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		contentType string
+		body        []byte
+	}{{contentTypeJSON, jsonBody}, {contentTypeMsgpack, msgpackBody}} {
+		shape, err := decodeShape(tt.contentType, tt.body, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := shape.Vertices()[0].Weight; got != weight {
+			t.Errorf("%s: вес %v, ожидалось %v", tt.contentType, got, weight)
+		}
+	}
+}
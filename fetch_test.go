@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kscvrmn/tev_test/polygon"
+	"github.com/kscvrmn/tev_test/polygontest"
+)
+
+// Результат fetchAndProcessPolygon для каждого вида ответа тестового сервера
+func TestFetchAndProcessPolygon(t *testing.T) {
+	server := polygontest.NewServer(6, map[int]polygontest.Case{
+		2: polygontest.Empty,
+		3: polygontest.Slow,
+		4: polygontest.ServerError,
+		5: polygontest.Malformed,
+	})
+	defer server.Close()
+	f := newTestFetcher(t, server.PolygonURL(), server.Client(), polygon.WithTimeout(100*time.Millisecond))
+	ctx := context.Background()
+
+	for _, idx := range []int{0, 1} {
+		t.Run("многоугольник", func(t *testing.T) {
+			r := f.fetchAndProcessPolygon(ctx, idx)
+			if r.err != nil {
+				t.Fatal(r.err)
+			}
+			x := float64(10 * idx)
+			wantWeight, wantHeavy := 9.0, false
+			if idx%2 == 0 {
+				wantWeight, wantHeavy = 109, true
+			}
+			if r.index != idx || r.weight != wantWeight || r.isHeavy != wantHeavy || r.area != 100 || r.pointCount != 4 {
+				t.Errorf("индекс %d, вес %g, тяжелый %v, площадь %g, точек %d", r.index, r.weight, r.isHeavy, r.area, r.pointCount)
+			}
+			if want := (Bbox{X1: x, Y1: 0, X2: x + 10, Y2: 10}); r.localBbox != want {
+				t.Errorf("bbox %+v, ожидался %+v", r.localBbox, want)
+			}
+			if wantHeavy && (r.heavy == nil || r.heavy.Index != idx) {
+				t.Errorf("тяжелый многоугольник не заполнен: %+v", r.heavy)
+			}
+		})
+	}
+
+	t.Run("пустой", func(t *testing.T) {
+		r := f.fetchAndProcessPolygon(ctx, 2)
+		if r.err != nil || r.weight != 0 || r.isHeavy || r.pointCount != 0 {
+			t.Errorf("ошибка %v, вес %g, тяжелый %v, точек %d", r.err, r.weight, r.isHeavy, r.pointCount)
+		}
+	})
+	t.Run("зависший ответ", func(t *testing.T) {
+		start := time.Now()
+		r := f.fetchAndProcessPolygon(ctx, 3)
+		if r.err == nil {
+			t.Errorf("ошибка %v, ожидалось превышение таймаута запроса", r.err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("запрос прерван только через %v", elapsed)
+		}
+	})
+	t.Run("ответ 500", func(t *testing.T) {
+		if r := f.fetchAndProcessPolygon(ctx, 4); r.err == nil || r.statusCode != http.StatusInternalServerError || r.index != 4 {
+			t.Errorf("ошибка %v, статус %d, индекс %d", r.err, r.statusCode, r.index)
+		}
+	})
+	t.Run("обрезанный JSON", func(t *testing.T) {
+		if r := f.fetchAndProcessPolygon(ctx, 5); r.err == nil || r.statusCode != 0 {
+			t.Errorf("ошибка %v, статус %d", r.err, r.statusCode)
+		}
+	})
+}
+
+// Ответы 5xx повторяются, пока не исчерпаны повторы, а 4xx не повторяются
+func TestFetchAndProcessPolygonRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		failures int32
+		retries  int
+		requests int32
+		ok       bool
+	}{
+		{"сбой до успеха", http.StatusBadGateway, 2, 2, 3, true},
+		{"повторы исчерпаны", http.StatusServiceUnavailable, 3, 2, 3, false},
+		{"без повторов", http.StatusInternalServerError, 1, 0, 1, false},
+		{"4xx не повторяется", http.StatusNotFound, 1, 3, 1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) <= tt.failures {
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.Write([]byte(`{"points":[{"x":0,"y":0,"weight":1},{"x":1,"y":0,"weight":1},{"x":0,"y":1,"weight":1}]}`))
+			}))
+			defer server.Close()
+
+			f := newTestFetcher(t, server.URL, server.Client(), polygon.WithRetries(tt.retries))
+			r := f.fetchAndProcessPolygon(context.Background(), 0)
+			if tt.ok && (r.err != nil || r.weight != 3) {
+				t.Errorf("ошибка %v, вес %g", r.err, r.weight)
+			}
+			if !tt.ok && (r.err == nil || r.statusCode != tt.status) {
+				t.Errorf("ошибка %v, статус %d, ожидался %d", r.err, r.statusCode, tt.status)
+			}
+			if got := requests.Load(); got != tt.requests {
+				t.Errorf("%d запросов, ожидалось %d", got, tt.requests)
+			}
+		})
+	}
+}
+
+// По таймауту запуска результат содержит все многоугольники, загруженные
+// до него, и помечается как частичный
+// Обрыв ответа после отправки заголовков повторяется только для
+// идемпотентного метода, а отказ в соединении - для любого
+func TestFetchRetriesByMethod(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		refuse   bool
+		attempts int32
+	}{
+		{"GET, обрыв ответа", http.MethodGet, false, 3},
+		{"POST, обрыв ответа", http.MethodPost, false, 1},
+		{"GET, отказ соединения", http.MethodGet, true, 3},
+		{"POST, отказ соединения", http.MethodPost, true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests, dials atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				// Заголовки и начало тела отправлены, затем соединение рвется
+				w.Header().Set("Content-Length", "1000")
+				w.Write([]byte(`{"points":[`))
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			}))
+			defer server.Close()
+
+			transport := server.Client().Transport.(*http.Transport).Clone()
+			dial := transport.DialContext
+			transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dials.Add(1)
+				if tt.refuse {
+					return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("соединение отклонено")}
+				}
+				return dial(ctx, network, addr)
+			}
+			f := newTestFetcher(t, server.URL, &http.Client{Transport: transport},
+				polygon.WithMethod(tt.method), polygon.WithRetries(2))
+			if r := f.fetchAndProcessPolygon(context.Background(), 0); r.err == nil {
+				t.Fatal("оборванный ответ принят")
+			}
+
+			got := requests.Load()
+			if tt.refuse {
+				got = dials.Load()
+			}
+			if got != tt.attempts {
+				t.Errorf("%d попыток, ожидалось %d", got, tt.attempts)
+			}
+		})
+	}
+}
+
+func TestPartialResultOnTimeout(t *testing.T) {
+	const total, slow = 10, 5
+	server := polygontest.NewServer(total, map[int]polygontest.Case{slow: polygontest.Slow})
+	defer server.Close()
+	f := newTestFetcher(t, server.PolygonURL(), server.Client())
+	opts := collectOptions{perPolygon: true, heavyThreshold: 100}
+
+	t.Run("последовательно", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+		result := runSequential(ctx, total, total, nil, f, opts, newFetchLimiter(0, 0, 0))
+		// Индексы после зависшего уже не загружаются
+		checkPartial(t, result, total, slow, slow)
+	})
+	t.Run("пул", func(t *testing.T) {
+		defer func(workers int) { *numWorkers = workers }(*numWorkers)
+		*numWorkers = 4
+		ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+		defer cancel()
+		resCh := make(chan Result, 1)
+		runPool(ctx, total, total, nil, resCh, f, opts, newFetchLimiter(0, 0, 0), nil, newPauseGate())
+		// Остальные воркеры успевают загрузить все, кроме зависшего
+		checkPartial(t, <-resCh, total, slow, total-1)
+	})
+}
+
+// checkPartial проверяет частичный результат, в который не попал зависший
+// многоугольник slow. Многоугольники polygontest стоят в ряд по X
+func checkPartial(t *testing.T, result Result, total, slow, processed int) {
+	t.Helper()
+	if !result.Partial || result.Processed != processed || result.Total != total {
+		t.Errorf("partial %v, обработано %d из %d, ожидалось %d", result.Partial, result.Processed, result.Total, processed)
+	}
+	last := slow
+	if processed > slow {
+		last = total
+	}
+	if want := (Bbox{X1: 0, Y1: 0, X2: float64(10 * last), Y2: 10}); result.Bbox != want {
+		t.Errorf("bbox %+v, ожидался %+v", result.Bbox, want)
+	}
+	for _, p := range result.Polygons {
+		if p.Index == slow {
+			t.Error("зависший многоугольник попал в результат")
+		}
+	}
+	// Четные индексы тяжелые, зависший - нечетный
+	if want := (processed + 1) / 2; len(result.HeavyPolygons) != want {
+		t.Errorf("тяжелых %d, ожидалось %d", len(result.HeavyPolygons), want)
+	}
+}
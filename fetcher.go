@@ -0,0 +1,20 @@
+package main
+
+import "github.com/kscvrmn/tev_test/polygon"
+
+// cliFetcher дополняет polygon.Fetcher возможностями утилиты: несколькими
+// адресами и хеджированием, предохранителем, лимитами, MessagePack,
+// ломаными и параметрами обработки из флагов
+type cliFetcher struct {
+	*polygon.Fetcher
+	// Параметры обработки загруженного многоугольника
+	opts processOptions
+}
+
+// newFetcher создает cliFetcher с параметрами обработки из флагов CLI
+// и HTTP-поведением, заданным опциями polygon.NewFetcher
+func newFetcher(opts processOptions, options ...polygon.Option) *cliFetcher {
+	f := &cliFetcher{Fetcher: polygon.NewFetcher(options...), opts: opts}
+	f.opts.heavy = f.Heavy()
+	return f
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kscvrmn/tev_test/polygon"
+)
+
+// Параметры обработки получают критерий тяжелого многоугольника
+// из опций polygon.Fetcher, а не из processOptions
+func TestNewFetcherHeavy(t *testing.T) {
+	never := func(*Polygon, float64, float64) bool { return false }
+	square := polygonOf([2]float64{0, 0}, [2]float64{1, 0}, [2]float64{1, 1}, [2]float64{0, 1})
+
+	f := newFetcher(processOptions{heavy: never}, polygon.WithHeavyThreshold(50))
+	if !f.opts.heavy(square, 50, 1) {
+		t.Error("порог WithHeavyThreshold не применен к обработке")
+	}
+	if f = newFetcher(processOptions{}); f.opts.heavy == nil || !f.opts.heavy(square, 100, 1) {
+		t.Error("без опций должен действовать порог по умолчанию")
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Переменные, доступные в выражении -filter_expr
+type filterVars struct {
+	weight float64
+	area   float64
+	points float64
+}
+
+// FilterExpr - разобранное выражение фильтра тяжелых многоугольников.
+// Поддерживаются числа, переменные weight, area, points, арифметика (+ - * /),
+// сравнения (< <= > >= == !=), логика (&& || !) и скобки.
+// Логические значения представлены числами: 0 - ложь, иначе истина
+type FilterExpr struct {
+	root filterNode
+}
+
+// Match вычисляет выражение для многоугольника
+func (f *FilterExpr) Match(weight float64, area float64, points int) bool {
+	return f.root(filterVars{weight: weight, area: area, points: float64(points)}) != 0
+}
+
+type filterNode func(v filterVars) float64
+
+// ParseFilterExpr разбирает выражение вида "weight > 50 && points < 1000"
+func ParseFilterExpr(src string) (*FilterExpr, error) {
+	tokens, err := tokenizeFilter(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("лишний токен %q", p.tokens[p.pos])
+	}
+	return &FilterExpr{root: root}, nil
+}
+
+// Операторы из двух символов проверяются раньше односимвольных
+var filterOperators = []string{"&&", "||", "<=", ">=", "==", "!=", "<", ">", "!", "+", "-", "*", "/", "(", ")"}
+
+func tokenizeFilter(src string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) || src[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		default:
+			op := ""
+			for _, candidate := range filterOperators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("неожиданный символ %q в позиции %d", c, i)
+			}
+			tokens = append(tokens, op)
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+// Рекурсивный спуск по уровням приоритета: || < && < сравнения < +- < */ < унарные
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(v filterVars) float64 { return boolNum(l(v) != 0 || right(v) != 0) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parseCompare()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		right, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(v filterVars) float64 { return boolNum(l(v) != 0 && right(v) != 0) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseCompare() (filterNode, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	var cmp func(a, b float64) bool
+	switch op {
+	case "<":
+		cmp = func(a, b float64) bool { return a < b }
+	case "<=":
+		cmp = func(a, b float64) bool { return a <= b }
+	case ">":
+		cmp = func(a, b float64) bool { return a > b }
+	case ">=":
+		cmp = func(a, b float64) bool { return a >= b }
+	case "==":
+		cmp = func(a, b float64) bool { return a == b }
+	case "!=":
+		cmp = func(a, b float64) bool { return a != b }
+	default:
+		return left, nil
+	}
+	p.pos++
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	return func(v filterVars) float64 { return boolNum(cmp(left(v), right(v))) }, nil
+}
+
+func (p *filterParser) parseSum() (filterNode, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == "+" || op == "-"; op = p.peek() {
+		p.pos++
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "+" {
+			left = func(v filterVars) float64 { return l(v) + right(v) }
+		} else {
+			left = func(v filterVars) float64 { return l(v) - right(v) }
+		}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseProduct() (filterNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == "*" || op == "/"; op = p.peek() {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "*" {
+			left = func(v filterVars) float64 { return l(v) * right(v) }
+		} else {
+			left = func(v filterVars) float64 { return l(v) / right(v) }
+		}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	switch p.peek() {
+	case "!":
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(v filterVars) float64 { return boolNum(operand(v) == 0) }, nil
+	case "-":
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(v filterVars) float64 { return -operand(v) }, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (filterNode, error) {
+	tok := p.peek()
+	if tok == "" {
+		return nil, fmt.Errorf("неожиданный конец выражения")
+	}
+	p.pos++
+	switch tok {
+	case "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("ожидалась закрывающая скобка")
+		}
+		p.pos++
+		return inner, nil
+	case "weight":
+		return func(v filterVars) float64 { return v.weight }, nil
+	case "area":
+		return func(v filterVars) float64 { return v.area }, nil
+	case "points":
+		return func(v filterVars) float64 { return v.points }, nil
+	}
+	num, err := strconv.ParseFloat(tok, 64)
+	if err != nil {
+		return nil, fmt.Errorf("неизвестный идентификатор %q", tok)
+	}
+	return func(filterVars) float64 { return num }, nil
+}
+
+func boolNum(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
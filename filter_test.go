@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterExprPrecedence(t *testing.T) {
+	tests := []struct {
+		expr   string
+		weight float64
+		want   bool
+	}{
+		{"weight == 2 + 3 * 4", 14, true},
+		{"weight == 2 + 3 * 4", 20, false},
+		{"weight == (2 + 3) * 4", 20, true},
+		// Вычитание и деление левоассоциативны
+		{"10 - 4 - 3 == weight", 3, true},
+		{"24 / 4 / 2 == weight", 3, true},
+		// && связывает сильнее ||: иначе (true || false) && false дало бы ложь
+		{"weight > 1 || weight > 5 && points < 0", 2, true},
+		{"(weight > 1 || weight > 5) && points < 0", 2, false},
+		// Унарные операторы связывают сильнее сравнений: (!5) > 1
+		{"!weight > 1", 5, false},
+		{"!(weight > 1)", 5, false},
+		{"!!(weight > 1)", 5, true},
+		{"-weight < 0", 5, true},
+		{"weight - -1 == 6", 5, true},
+		// Без сравнения истинно любое ненулевое значение
+		{"weight", 0.5, true},
+		{"weight * 0", 5, false},
+		{"area == 100 && points == 4", 0, true},
+		{"weight>=5&&weight<=5", 5, true},
+		{"weight != 5", 5, false},
+		{"weight == .5", 0.5, true},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilterExpr(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilterExpr(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := f.Match(tt.weight, 100, 4); got != tt.want {
+			t.Errorf("%q при weight=%g: %v, ожидалось %v", tt.expr, tt.weight, got, tt.want)
+		}
+	}
+}
+
+func TestFilterExprErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"", "неожиданный конец"},
+		{"weight >", "неожиданный конец"},
+		{"(weight > 1", "закрывающая скобка"},
+		{"weight > 1)", "лишний токен \")\""},
+		{"weight 5", "лишний токен \"5\""},
+		// Сравнения не объединяются в цепочки
+		{"1 < weight < 3", "лишний токен \"<\""},
+		{"height > 1", "неизвестный идентификатор \"height\""},
+		{"weight > 1.2.3", "неизвестный идентификатор \"1.2.3\""},
+		{"weight # 1", "неожиданный символ '#' в позиции 7"},
+		{"weight = 1", "неожиданный символ '='"},
+	}
+	for _, tt := range tests {
+		_, err := ParseFilterExpr(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ParseFilterExpr(%q): ошибка %v, ожидалась %q", tt.expr, err, tt.err)
+		}
+	}
+}
+
+// Выражение заменяет критерий тяжести: легкий, но крупный многоугольник
+// становится тяжелым
+func TestHeavyByFilter(t *testing.T) {
+	f, err := ParseFilterExpr("area >= 100 && weight < 50")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := processOptions{heavy: heavyByFilter(f)}
+	if r := processed(t, 0, square(0, 0, 10, 20), opts); !r.isHeavy {
+		t.Error("квадрат площадью 100 и весом 20 не отмечен тяжелым")
+	}
+	if r := processed(t, 1, square(0, 0, 10, 150), opts); r.isHeavy {
+		t.Error("квадрат весом 150 отмечен тяжелым")
+	}
+}
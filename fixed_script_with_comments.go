@@ -1,124 +1,1056 @@
 package main
 
 import (
-	"context"  // нет смысов назвать context2, context удобнее
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context" // нет смысов назвать context2, context удобнее
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"log"  // добавлен более удобный пакет логирования ошибок вместо простых fmt.Printf
-	"math"
+	"log/slog"
 	"net/http"
 	"os"
-	"runtime"  // добавлен для определения оптимального количества горутин
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"syscall"
+	"text/template"
 	"time"
+
+	"github.com/kscvrmn/tev_test/polygon"
+	"golang.org/x/time/rate"
 )
 
-func MinInt(a, b int) int {
+func MinFloat(a, b float64) float64 {
 	if a < b {
 		return a
 	}
 	return b
 }
 
-func MaxInt(a, b int) int {
+func MaxFloat(a, b float64) float64 {
 	if a > b {
 		return a
 	}
 	return b
 }
 
-func MaxFloat32(a, b float32) float32 {
-	if a > b {
-		return a
-	}
-	return b
-}
+// Базовые типы и расчеты вынесены в пакет polygon, чтобы их можно было
+// использовать вне утилиты. Псевдонимы сохраняют прежние имена в CLI
+type (
+	Point         = polygon.Point
+	WeightedPoint = polygon.WeightedPoint
+	Polygon       = polygon.Polygon
+)
 
-type Point struct {
-	X int `json:"x"`
-	Y int `json:"y"`
-}
+// поля переименованы с большой буквы для экспорта в json
+// изначально x1, y1, x2, y2 не экспортировались, что приводило к пустым значениям в jsone
+type Bbox = polygon.Bbox
 
-type WeightedPoint struct {
-	Point
-	Weight float32 `json:"weight"`
-}
+type Result struct {
+	Bbox Bbox `json:"bbox"`
+	// Размеры и площадь общего bbox, чтобы потребителям не пересчитывать их
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Area   float64 `json:"area"`
+	// Ни один многоугольник не дал bbox, размеры и сам bbox нулевые
+	Empty     bool    `json:"empty,omitempty"`
+	MaxWeight float64 `json:"max_weight"`
+	// Суммарная площадь выведенных тяжелых многоугольников
+	TotalHeavyArea float64 `json:"total_heavy_area"`
+	// Порог веса, по которому отбирались тяжелые многоугольники
+	HeavyThreshold float64         `json:"heavy_threshold"`
+	HeavyPolygons  []*HeavyPolygon `json:"heavy_polygons"`
+	// Доля общего bbox, покрытая локальными bbox (только с -coverage_grid)
+	GlobalCoverage *float64 `json:"global_coverage,omitempty"`
+	// Приближенные перцентили весов (только с -weight_percentiles)
+	WeightPercentiles *WeightPercentiles `json:"weight_percentiles,omitempty"`
+	// Группы близких тяжелых многоугольников (только с -merge_radius)
+	MergedPolygons []*MergedPolygon `json:"merged_polygons,omitempty"`
+	// Сумма весов точек по ячейкам общего bbox (только с -heatmap_grid)
+	Heatmap *Heatmap `json:"heatmap,omitempty"`
+	// Пары касающихся или близких тяжелых многоугольников (только с -adjacency)
+	Adjacency []AdjacencyEdge `json:"adjacency,omitempty"`
+	// Пары тяжелых многоугольников с пересекающимися bbox (только с -overlaps)
+	Overlaps []BboxOverlap `json:"overlaps,omitempty"`
+	// Индексы тяжелых многоугольников, содержащих точку -contains_point
+	Containing []int `json:"containing,omitempty"`
+	// Результаты всех многоугольников в порядке индексов (только с -per_polygon)
+	Polygons []PolygonSummary `json:"polygons,omitempty"`
+	// Промежуточный результат с -snapshot_interval, итоговый без пометки
+	Snapshot bool `json:"snapshot,omitempty"`
+	// Сведения о запуске (только с -include_meta)
+	Meta *Meta `json:"meta,omitempty"`
+	// Скорость запуска и задержки загрузки (только с -stats)
+	Stats *RunStats `json:"stats,omitempty"`
+	// Результат неполный: время выполнения истекло раньше, чем пришли все многоугольники
+	Partial bool `json:"partial,omitempty"`
+	// Сколько многоугольников учтено в агрегате и сколько ожидалось
+	Processed int `json:"processed"`
+	Total     int `json:"total"`
+	// Ошибки отдельных многоугольников, успешные входят в агрегат как обычно
+	ErrorCount int            `json:"error_count,omitempty"`
+	Errors     []PolygonError `json:"errors,omitempty"`
 
-type Polygon struct {
-	Points []WeightedPoint `json:"points"`
+	// Все ошибки, объединенные errors.Join, для -fail_on_error
+	err error
 }
 
-"""поля переименованы с большой буквы для экспорта в json"""
-"""изначально x1, y1, x2, y2 не экспортировались, что приводило к пустым значениям в jsone"""
-type Bbox struct {
-	X1 int `json:"x1"`
-	Y1 int `json:"y1"`
-	X2 int `json:"x2"`
-	Y2 int `json:"y2"`
+// Ошибка загрузки или обработки одного многоугольника
+type PolygonError struct {
+	Index int `json:"index"`
+	// HTTP статус ответа, 0 если ответ не получен
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error"`
 }
 
-type Result struct {
-	Bbox          Bbox       `json:"bbox"`
-	MaxWeight     float32    `json:"max_weight"`
-	HeavyPolygons []*Polygon `json:"heavy_polygons"`
+// Тяжелый многоугольник вместе с вычисленными для него характеристиками.
+// Polygon встроен, чтобы поле "points" в JSON осталось на прежнем месте
+type HeavyPolygon struct {
+	*Polygon
+	// Индекс многоугольника на сервере
+	Index int `json:"index"`
+	// Площадь по формуле шнурования (Гаусса)
+	Area float64 `json:"area"`
+	// Вид фигуры, заполняется только для ломаных, чтобы не менять вывод многоугольников
+	Type   string `json:"type,omitempty"`
+	Convex bool   `json:"convex"`
+	// Направление обхода выведенных точек: cw, ccw или degenerate для нулевой
+	// площади. С -normalize_winding всегда ccw, кроме вырожденных; у ломаных не заполняется
+	Orientation string `json:"orientation,omitempty"`
+	// Диаметр (наибольшее расстояние между вершинами), только с -diameter
+	Diameter *float64 `json:"diameter,omitempty"`
+	// Ширина (наименьшее расстояние между параллельными опорными прямыми), только с -min_width
+	MinWidth *float64 `json:"min_width,omitempty"`
+	// Оценка сложности от 0 до 1, только с -complexity
+	Complexity *float64 `json:"complexity,omitempty"`
+	// Отношение площади охватывающей окружности к площади bbox, только с -circle_box_ratio
+	CircleBoxRatio *float64 `json:"circle_box_ratio,omitempty"`
+	// Углы поворота в вершинах в градусах, только с -turning_signature
+	TurningAngles []float64 `json:"turning_angles,omitempty"`
+	// Изменение веса на единицу длины по ребрам, только с -weight_gradient
+	WeightGradient []float64 `json:"weight_gradient,omitempty"`
+	// Самая длинная внутренняя хорда между вершинами, только с -longest_chord
+	LongestChord *Chord `json:"longest_chord,omitempty"`
+	// Приближение срединной оси, только с -skeleton
+	Skeleton []SkeletonEdge `json:"skeleton,omitempty"`
+	// Веса точек по сетке поверх собственного bbox, только с -local_heatmap
+	LocalHeatmap [][]float64 `json:"local_heatmap,omitempty"`
+	// Класс размера по площади: small, medium или large
+	SizeClass string `json:"size_class,omitempty"`
+	// IoU bbox с многоугольником того же индекса из -prev_result, 0 - нет пары
+	BboxIoU *float64 `json:"bbox_iou,omitempty"`
+
+	// Вес многоугольника, нужен при постобработке списка тяжелых
+	weight float64
+	// Локальный bbox по исходным точкам: выводимые точки могут быть упрощены или обрезаны
+	bbox Bbox
 }
 
 // Добавлен новый тип для результатов обработки отдельных полигонов
 // Это предотвращает гонки данных, так как каждый воркер работает с локальной копией
 type PolygonResult struct {
-	localBbox Bbox      // Локальный bbox для безопасной конкурентной обработки
-	weight    float32   // Вес полигона
-	isHeavy   bool      // Флаг "тяжелого" полигона для оптимизации добавления в результат
-	polygon   *Polygon  // Указатель на сам полигон для экономии памяти
-	err       error     // Ошибка для корректной обработки сбоев
+	localBbox  Bbox          // Локальный bbox для безопасной конкурентной обработки
+	weight     float64       // Вес полигона
+	isHeavy    bool          // Флаг "тяжелого" полигона для оптимизации добавления в результат
+	polygon    *Polygon      // Указатель на сам полигон для экономии памяти
+	heavy      *HeavyPolygon // Тяжелый полигон с характеристиками, заполняется только для тяжелых
+	pointCount int           // Число точек, нужно для метрики weight_x_points
+	area       float64       // Площадь многоугольника, 0 для менее чем трех точек
+	centroid   Point         // Центр масс по площади, для вырожденных - среднее точек
+	err        error         // Ошибка для корректной обработки сбоев
+	index      int           // Индекс многоугольника во входной последовательности
+	rawBody    []byte        // Тело ответа, которое не удалось разобрать (только с -errors_file)
+	statusCode int           // HTTP статус ответа, 0 если ответ не получен
+	batch      *resultBatch  // Пачка результатов воркера с -batch_size, остальные поля тогда пусты
 }
 
+// PostProcess - необязательный хук, вызываемый в воркере для каждого результата
+// после обработки многоугольника и до передачи в агрегацию. Позволяет дополнить
+// или изменить результат без правки конвейера. Вызывается одновременно из
+// нескольких воркеров, поэтому функция должна быть безопасной для конкурентного
+// использования. Устанавливается до запуска обработки, по умолчанию nil
+var PostProcess func(*PolygonResult)
+
 // Добавлены новые параметры командной строки для большей гибкости:
 // - serverURL позволяет указать адрес сервера вместо жестко закодированного
 // - numWorkers позволяет контролировать параллелизм вместо фиксированных 10 горутин
 var (
-	timeout     = flag.Int("timeout", 60, "максимальное время обработки в секундах")
-	polygonsNum = flag.Int("polygons_num", 3, "количество многоугольников для обработки")
-	serverURL   = flag.String("url", "http://localhost:8080/polygon", "URL для получения многоугольников")
-	numWorkers  = flag.Int("workers", runtime.NumCPU(), "количество рабочих горутин")
+	metricsAddr      = flag.String("metrics_addr", "", "адрес HTTP-сервера с метриками Prometheus на /metrics, например :9090 (пусто - не запускать)")
+	timeout          = flag.Int("timeout", 60, "максимальное время обработки в секундах")
+	polygonsNum      = flag.Int("polygons_num", 3, "количество многоугольников для обработки")
+	serverURL        = flag.String("url", "http://localhost:8080/polygon", "URL для получения многоугольников, {index} или {{.Index}} заменяется индексом многоугольника")
+	authBearer       = flag.String("auth_bearer", "", "токен для заголовка Authorization: Bearer (пусто - из переменной окружения "+envAuthBearer+")")
+	authBasic        = flag.String("auth_basic", "", "учетные данные user:pass для Basic-авторизации (пусто - из переменной окружения "+envAuthBasic+")")
+	httpMethod       = flag.String("http_method", http.MethodGet, "метод HTTP-запроса многоугольника, например POST")
+	bodyTemplate     = flag.String("request_body_template", "", "шаблон тела запроса (text/template), например {\"id\": {{.Index}}} (пусто - запрос без тела)")
+	endpointURLs     = flag.String("urls", "", "адреса серверов через запятую вместо -url: индексы распределяются по ним по кругу")
+	strictJSON       = flag.Bool("strict_json", false, "строгий разбор JSON-ответов: неизвестные поля и данные после JSON-значения считаются ошибкой")
+	maxResponseBytes = flag.Int64("max_response_bytes", 256<<20, "предельный размер тела ответа сервера в байтах (0 - без ограничения)")
+	hedge            = flag.Bool("hedge", false, "запрашивать каждый индекс со всех адресов -urls одновременно и брать первый успешный ответ")
+	countURL         = flag.String("count_url", "", "URL, возвращающий число многоугольников (заменяет -polygons_num)")
+	numWorkers       = flag.Int("workers", defaultWorkers(), "количество рабочих горутин (по умолчанию с учетом GOMAXPROCS и квоты CPU контейнера)")
+	rateLimit        = flag.Float64("rate_limit", 0, "общий лимит запросов в секунду для всех воркеров (0 - без ограничения)")
+	bandwidthLimit   = flag.Int("bandwidth_limit", 0, "общий лимит скорости чтения ответов в байтах в секунду (0 - без ограничения)")
+	throttle5xx      = flag.Float64("throttle_5xx", 0, "доля ответов 5xx, при которой число активных воркеров уменьшается вдвое (0 - не регулировать)")
+	adaptiveWorkers  = flag.Bool("adaptive_workers", false, "начинать с -min_workers активных воркеров и менять их число до -workers по задержке и доле 5xx ответов (запускаются все -workers горутин, неактивные ждут, не забирая индексов)")
+	minWorkers       = flag.Int("min_workers", 1, "наименьшее число активных воркеров для -adaptive_workers")
+	breakerThreshold = flag.Int("breaker_threshold", 0, "число подряд идущих сбоев, после которого запросы временно прекращаются (0 - без предохранителя)")
+	breakerCooldown  = flag.Duration("breaker_cooldown", 10*time.Second, "время, на которое прекращаются запросы после срабатывания предохранителя")
+	perWorkerRate    = flag.Float64("per_worker_rate", 0, "лимит запросов в секунду для каждого воркера (0 - без ограничения)")
+	failOnError      = flag.Bool("fail_on_error", false, "завершаться с кодом 1, если хотя бы один многоугольник не обработан (результат все равно выводится)")
+	countOnly        = flag.Bool("count_only", false, "только подсчитать результаты без списка тяжелых многоугольников (для нагрузочного тестирования)")
+	dedupByBbox      = flag.Bool("dedup_by_bbox", false, "отбрасывать многоугольники, bbox и вес которых совпадают с уже обработанными")
+	coverageGrid     = flag.Int("coverage_grid", 0, "разрешение сетки для расчета покрытия общего bbox (0 - не считать)")
+	diameter         = flag.Bool("diameter", false, "вычислять диаметр тяжелых многоугольников")
+	complexity       = flag.Bool("complexity", false, "выводить оценку сложности тяжелых многоугольников от 0 до 1 (число точек, самопересечение, невыпуклость)")
+	minWidth         = flag.Bool("min_width", false, "вычислять ширину тяжелых многоугольников (наименьшее расстояние между параллельными опорными прямыми)")
+	localHeatmap     = flag.Int("local_heatmap", 0, "тепловая карта весов NxN поверх bbox каждого тяжелого многоугольника (0 - не строить)")
+	skeleton         = flag.Bool("skeleton", false, "строить приближенную срединную ось (скелет) тяжелых многоугольников")
+	longestChord     = flag.Bool("longest_chord", false, "вычислять самую длинную внутреннюю хорду тяжелых многоугольников (до 1000 вершин)")
+	turningSignature = flag.Bool("turning_signature", false, "выводить углы поворота в вершинах тяжелых многоугольников")
+	weightGradient   = flag.Bool("weight_gradient", false, "выводить изменение веса на единицу длины по ребрам тяжелых многоугольников")
+	circleBoxRatio   = flag.Bool("circle_box_ratio", false, "вычислять отношение площади охватывающей окружности к площади bbox для тяжелых многоугольников")
+	splitOutputDir   = flag.String("split_output_dir", "", "каталог для записи каждого тяжелого многоугольника в отдельный файл")
+	reproject        = flag.String("reproject", "", "имя преобразования координат точек (identity, affine)")
+	closure          = flag.String("closure", "", "замыкание колец: implicit (без повтора первой точки) | explicit (с повтором); по умолчанию как прислал сервер")
+	normalizeWinding = flag.Bool("normalize_winding", false, "приводить обход вершин тяжелых многоугольников к направлению против часовой стрелки (в копии точек)")
+	simplifyEpsilon  = flag.Float64("simplify_epsilon", 0, "допуск упрощения точек тяжелых многоугольников алгоритмом Рамера-Дугласа-Пекера (0 - без упрощения)")
+	dedupPoints      = flag.Bool("dedup_points", false, "схлопывать подряд идущие точки с одинаковыми координатами до расчета веса и bbox")
+	dedupWeight      = flag.String("dedup_weight", dedupWeightFirst, "вес схлопнутой точки для -dedup_points: first (вес первой в серии) | sum (сумма серии)")
+	snapGrid         = flag.Int("snap_grid", 0, "шаг сетки для привязки точек с объединением совпавших (0 - без привязки)")
+	confidenceBbox   = flag.Bool("confidence_bbox", false, "строить bbox многоугольника только по точкам с весом в полосе перцентилей -confidence_low..-confidence_high")
+	confidenceLow    = flag.Float64("confidence_low", 5, "нижний перцентиль весов для -confidence_bbox")
+	confidenceHigh   = flag.Float64("confidence_high", 95, "верхний перцентиль весов для -confidence_bbox")
+	affineParams     = flag.String("affine_params", "", "коэффициенты аффинного преобразования a,b,c,d,e,f для -reproject affine")
+	maxBboxWidth     = flag.Float64("max_bbox_width", 0, "максимально допустимая ширина общего bbox (0 - без ограничения)")
+	maxBboxHeight    = flag.Float64("max_bbox_height", 0, "максимально допустимая высота общего bbox (0 - без ограничения)")
+	weightPercents   = flag.Bool("weight_percentiles", false, "выводить приближенные p50/p90/p99 весов многоугольников")
+	clipToBbox       = flag.Bool("clip_to_bbox", false, "обрезать тяжелые многоугольники по общему bbox (второй проход после агрегации)")
+	clipBbox         = flag.String("clip_bbox", "", "явный прямоугольник x1,y1,x2,y2 для -clip_to_bbox вместо общего bbox (для нарезки на тайлы)")
+	mergeRadius      = flag.Float64("merge_radius", 0, "объединять тяжелые многоугольники с центроидами ближе этого расстояния (0 - не объединять)")
+	prevResult       = flag.String("prev_result", "", "результат предыдущего запуска для расчета IoU bbox тяжелых многоугольников по индексам")
+	sequential       = flag.Bool("sequential", false, "загружать и обрабатывать многоугольники по порядку в одной горутине (для тестов)")
+	maxRetries       = flag.Int("max_retries", polygon.DefaultMaxRetries, "число повторов загрузки при сетевых ошибках и ответах 5xx")
+	connectRetries   = flag.Int("connect_retries", -1, "число повторов, когда соединение не установлено и запрос не дошел до сервера (-1 - как -max_retries)")
+	requestRetries   = flag.Int("request_retries", -1, "число повторов запроса, который сервер мог частично выполнить; только для GET (-1 - как -max_retries)")
+	priorityList     = flag.String("priority_indices", "", "приоритетные индексы через запятую: загружаются первыми, с большим числом повторов, без лимита воркера и с резервом в четверть -rate_limit")
+	debugLog         = flag.Bool("debug", false, "синоним -log_level debug")
+	logLevel         = flag.String("log_level", "info", "уровень логирования: debug | info | warn | error")
+	logFormat        = flag.String("log_format", logFormatText, "формат строк лога: text | json")
+	validateOutput   = flag.Bool("validate_output", false, "перед выводом проверять результат по встроенной JSON-схеме и завершаться с ошибкой при несоответствии")
+	inputFile        = flag.String("input_file", "", "читать многоугольники из файла JSON Lines (строка N - многоугольник с индексом N) вместо сервера")
+	bboxOnly         = flag.Bool("bbox_only", false, "считать только общий bbox, не разбирая веса точек (max_weight и тяжелые многоугольники не выводятся)")
+	heavyThreshold   = flag.Float64("heavy_threshold", polygon.DefaultHeavyThreshold, "порог тяжелого многоугольника для величины -heavy_by")
+	heavyByName      = flag.String("heavy_by", polygon.HeavyByWeight, "величина, с которой сравнивается -heavy_threshold: weight (суммарный вес) | area | points")
+	filterExpr       = flag.String("filter_expr", "", "выражение над weight, area, points, определяющее тяжелые многоугольники (например, \"weight > 50 && points < 1000\")")
+	streamOutput     = flag.String("stream_output", "", "файл для потоковой записи результата каждого многоугольника в формате JSON Lines")
+	stdioStream      = flag.Bool("stdio_stream", false, "выводить результат каждого многоугольника строкой JSON в stdout, а ошибки - строками JSON в stderr; итоговая сводка - последней строкой stdout, лог - в формате json")
+	streamStdout     = flag.Bool("stream", false, "выводить результат каждого многоугольника строкой JSON в stdout по мере поступления; итоговая сводка - последней строкой stdout")
+	appendOutput     = flag.Bool("append_output", false, "дописывать -stream_output, пропуская уже записанные индексы (продолжение прерванного запуска)")
+	errorsFile       = flag.String("errors_file", "", "файл для записи ошибок обработки в формате JSON Lines")
+	verify           = flag.Bool("verify", false, "сравнить два сохраненных результата: -verify a.json b.json")
+	verifyEpsilon    = flag.Float64("verify_epsilon", 1e-6, "допустимое расхождение вещественных значений для -verify")
+	adjacency        = flag.Bool("adjacency", false, "вывести пары тяжелых многоугольников с общей границей")
+	adjacencyTol     = flag.Float64("adjacency_tolerance", 0, "наибольшее расстояние между границами, при котором многоугольники считаются смежными")
+	overlaps         = flag.Bool("overlaps", false, "вывести пары тяжелых многоугольников, bbox которых пересекаются")
+	containsPoint    = flag.String("contains_point", "", "точка x,y: вывести индексы тяжелых многоугольников, которые ее содержат")
+	perPolygon       = flag.Bool("per_polygon", false, "добавить в вывод список polygons с индексом, bbox, весом и признаком тяжести каждого многоугольника")
+	snapshotInterval = flag.Duration("snapshot_interval", 0, "период вывода промежуточного результата в stderr (0 - не выводить)")
+	includeMeta      = flag.Bool("include_meta", false, "добавить в вывод сведения о запуске: версию, флаги, время, имя хоста")
+	includeStats     = flag.Bool("stats", false, "добавить в вывод статистику скорости: общее время, многоугольников в секунду, p50/p95/p99 длительности загрузки")
+	heatmapGrid      = flag.Int("heatmap_grid", 0, "построить тепловую карту весов всех точек на сетке NxN поверх общего bbox (0 - не строить)")
+	cpuProfile       = flag.String("cpuprofile", "", "записать профиль CPU в файл (формат pprof)")
+	memProfile       = flag.String("memprofile", "", "записать профиль памяти в файл по завершении (формат pprof)")
+	batchSize        = flag.Int("batch_size", 1, "сколько результатов воркер объединяет перед отправкой сборщику (1 - без объединения)")
+	sampleK          = flag.Int("sample_k", 0, "оставить k тяжелых многоугольников, выбранных случайно с вероятностью по весу (0 - все)")
+	seed             = flag.Uint64("seed", 1, "зерно генератора случайных чисел для воспроизводимой выборки")
+	areaBudget       = flag.Float64("area_budget", 0, "оставить крупнейшие тяжелые многоугольники, пока их суммарная площадь не превысит бюджет (0 - без ограничения)")
+	topN             = flag.Int("top_n", 0, "оставить N самых тяжелых многоугольников по убыванию веса (0 - все)")
+	coordOutput      = flag.String("coord_output", coordOutputInt, "формат координат в JSON: int | float (1.0)")
+	outputFormat     = flag.String("output_format", outputFormatJSON, "формат вывода: json | svg | geojson")
+	outputFile       = flag.String("output_file", "", "файл для итогового результата вместо stdout, записывается атомарно")
+	outputGzip       = flag.Bool("output_gzip", false, "сжимать -output_file в gzip")
+	svgWeightColors  = flag.Bool("svg_weight_colors", false, "окрашивать многоугольники в SVG в зависимости от веса")
+	sizeClasses      = flag.String("size_class_thresholds", defaultSizeClassThresholds, "границы площади между классами small/medium/large через запятую")
+	importanceMetric = flag.String("importance_metric", metricWeight, "метрика для выбора самого тяжелого многоугольника: weight | weight_x_points")
+)
+
+// Допустимые значения -importance_metric
+const (
+	metricWeight        = "weight"          // суммарный вес точек
+	metricWeightXPoints = "weight_x_points" // вес, умноженный на число точек
+)
+
+func init() {
+	// -no_output оставлен синонимом -count_only для удобства
+	flag.BoolVar(countOnly, "no_output", false, "синоним -count_only")
+	flag.Var(extraHeaders, "header", "дополнительный заголовок запросов к серверу в виде key=value, можно повторять")
+
+	// Служебные флаги не показываются в справке
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of %s:\n", os.Args[0])
+		visible := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+		visible.SetOutput(flag.CommandLine.Output())
+		flag.VisitAll(func(f *flag.Flag) {
+			if !hiddenFlags[f.Name] {
+				visible.Var(f.Value, f.Name, f.Usage)
+			}
+		})
+		visible.PrintDefaults()
+	}
+}
+
+// Флаги для тестов и отладки, не предназначенные для пользователей
+var hiddenFlags = map[string]bool{"sequential": true}
+
+// Параметры обработки отдельного многоугольника в воркере
+type processOptions struct {
+	// Считать диаметр тяжелых многоугольников
+	diameter bool
+	// Считать ширину тяжелых многоугольников
+	minWidth bool
+	// Считать оценку сложности
+	complexity bool
+	// Считать отношение площади охватывающей окружности к площади bbox
+	circleBoxRatio bool
+	// Считать углы поворота в вершинах
+	turningSignature bool
+	// Считать градиент веса вдоль границы
+	weightGradient bool
+	// Искать самую длинную внутреннюю хорду
+	longestChord bool
+	// Строить приближенную срединную ось
+	skeleton bool
+	// Размер локальной тепловой карты, 0 - не строить
+	localHeatmap int
+	// Преобразование координат, применяемое ко всем точкам, nil - без преобразования
+	transformer Transformer
+	// Шаг сетки для привязки точек, 0 - без привязки
+	snapGrid int
+	// Схлопывать подряд идущие дубликаты точек, вес по правилу dedupWeight
+	dedupPoints bool
+	dedupWeight string
+	// Полоса перцентилей весов для bbox, nil - bbox по всем точкам
+	confidenceBand *[2]float64
+	// Общий для всех воркеров лимит скорости чтения тел ответов, nil - без ограничения
+	bandwidth *rate.Limiter
+	// Сохранять тела ответов, которые не удалось разобрать
+	keepErrorBodies bool
+	// Общий предохранитель от запросов к неработающему серверу, nil - не используется
+	breaker *circuitBreaker
+	// Способ замыкания кольца: implicit, explicit или пусто - как прислал сервер
+	closure string
+	// Приводить обход тяжелых многоугольников к направлению против часовой стрелки
+	normalizeWinding bool
+	// Допуск упрощения точек тяжелых многоугольников, 0 - без упрощения
+	simplifyEpsilon float64
+	// Индексы с увеличенным числом повторов и долей лимита запросов
+	priority priorityIndices
+	// Файл многоугольников вместо сервера, nil - загрузка по HTTP
+	input *polygonFile
+	// Считать только bbox: веса не разбираются, тяжелые не отбираются
+	bboxOnly bool
+	// Критерий тяжелого многоугольника, выставляется newFetcher из polygon.Fetcher
+	heavy HeavyFunc
+	// Адреса серверов из -urls, пусто - только -url
+	urls []string
+	// Разобранные шаблоны адресов с подстановкой индекса, по исходному адресу
+	urlTemplates map[string]*template.Template
+	// Запрашивать каждый индекс со всех адресов сразу
+	hedge bool
+	// Предельный размер тела ответа в байтах, 0 - без ограничения
+	maxResponseBytes int64
+	// Возвращать точки нетяжелых многоугольников в пул после обработки
+	poolPoints bool
+	// Строгий разбор JSON: неизвестные поля и лишние данные - ошибка
+	strictJSON bool
+}
+
+// Параметры агрегации результатов, передаются в collectResults явно
+type collectOptions struct {
+	// Считать только счетчики, не накапливая тяжелые многоугольники
+	countOnly bool
+	// Метрика, по которой выбирается MaxWeight
+	importanceMetric string
+	// Отбрасывать результаты с уже встречавшимися bbox и весом
+	dedupByBbox bool
+	// Разрешение сетки для GlobalCoverage, 0 - не считать
+	coverageGrid int
+	// Предельные размеры общего bbox, 0 - без ограничения
+	maxBboxWidth  float64
+	maxBboxHeight float64
+	// Считать потоковые перцентили весов
+	weightPercentiles bool
+	// Обрезать тяжелые многоугольники после агрегации.
+	// clipRect задает прямоугольник явно, иначе используется общий bbox
+	clip     bool
+	clipRect *Bbox
+	// Радиус объединения тяжелых многоугольников, 0 - не объединять
+	mergeRadius float64
+	// Куда записывать ошибки обработки, nil - только в лог
+	errors *errorsWriter
+	// Ошибки пишутся строками JSON в stderr и не дублируются в лог
+	errorsOnStderr bool
+	// Куда записывать результат каждого многоугольника, nil - не записывать
+	stream *streamWriter
+	// Bbox тяжелых многоугольников предыдущего запуска по индексам, nil - не сравнивать
+	prevBboxes map[int]Bbox
+	// Бюджет суммарной площади тяжелых многоугольников, 0 - без ограничения
+	areaBudget float64
+	// Сколько самых тяжелых многоугольников оставить, 0 - все
+	topN int
+	// Размер взвешенной случайной выборки тяжелых многоугольников, 0 - все
+	sampleK int
+	// Зерно генератора для выборки
+	seed uint64
+	// Сохранять результат каждого многоугольника, а не только агрегат
+	perPolygon bool
+	// Порог веса тяжелого многоугольника, выводится в результате
+	heavyThreshold float64
+	// Период вывода промежуточных результатов, 0 - не выводить
+	snapshotInterval time.Duration
+	// Куда выводить промежуточные результаты
+	snapshotOut io.Writer
+	// Размер сетки тепловой карты весов, 0 - не строить
+	heatmapGrid int
+	// Строить пары смежных тяжелых многоугольников
+	adjacency bool
+	// Наибольшее расстояние между границами смежных многоугольников
+	adjacencyTol float64
+	// Строить пары тяжелых многоугольников с пересекающимися bbox
+	overlaps bool
+	// Точка для поиска содержащих ее тяжелых многоугольников, nil - не искать
+	containsPoint *Point
+	// Границы классов размера тяжелых многоугольников
+	sizeClasses sizeClassThresholds
+}
+
+// checkBboxLimits проверяет, что общий bbox не превышает допустимых размеров.
+// Слишком большой bbox обычно означает ошибочные данные на сервере
+func (o collectOptions) checkBboxLimits(b Bbox) error {
+	// Пустой агрегат (ни одного успешного многоугольника) проверять нечего
+	if b.X1 > b.X2 || b.Y1 > b.Y2 {
+		return nil
+	}
+	if width := b.X2 - b.X1; o.maxBboxWidth > 0 && width > o.maxBboxWidth {
+		return fmt.Errorf("ширина общего bbox %g превышает допустимую %g (bbox %+v)", width, o.maxBboxWidth, b)
+	}
+	if height := b.Y2 - b.Y1; o.maxBboxHeight > 0 && height > o.maxBboxHeight {
+		return fmt.Errorf("высота общего bbox %g превышает допустимую %g (bbox %+v)", height, o.maxBboxHeight, b)
+	}
+	return nil
+}
+
+// importance возвращает значение, по которому сравниваются многоугольники
+// при поиске самого тяжелого
+func (o collectOptions) importance(pr PolygonResult) float64 {
+	if o.importanceMetric == metricWeightXPoints {
+		return pr.weight * float64(pr.pointCount)
+	}
+	return pr.weight
+}
+
+// Коды выхода при частичном результате
+const (
+	exitTimeout     = 2
+	exitInterrupted = 130 // 128 + SIGINT, как у оболочки при Ctrl-C
 )
 
 func main() {
+	start := time.Now()
 	flag.Parse()
 
+	if *debugLog {
+		*logLevel = "debug"
+	}
+	// С -stdio_stream stderr разбирается построчно как JSON, поэтому
+	// строки лога там тоже должны быть JSON
+	if *stdioStream {
+		*logFormat = logFormatJSON
+	}
+	if err := setupLogger(*logLevel, *logFormat); err != nil {
+		fmt.Fprintf(os.Stderr, "Некорректные настройки лога: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Режим сравнения результатов не обращается к серверу
+	if *verify {
+		os.Exit(runVerify(flag.Args(), *verifyEpsilon, os.Stdout))
+	}
+
+	if *importanceMetric != metricWeight && *importanceMetric != metricWeightXPoints {
+		fatalf(context.Background(), "Неизвестная метрика -importance_metric: %q", *importanceMetric)
+	}
+	if *outputFormat != outputFormatJSON && *outputFormat != outputFormatSVG && *outputFormat != outputFormatGeoJSON {
+		fatalf(context.Background(), "Неизвестный формат вывода -output_format: %q", *outputFormat)
+	}
+	if *outputGzip && *outputFile == "" {
+		fatalf(context.Background(), "-output_gzip работает только вместе с -output_file")
+	}
+	if *outputFile != "" && *splitOutputDir != "" {
+		fatalf(context.Background(), "нужно выбрать одно из -output_file и -split_output_dir")
+	}
+	sizeClassBounds, err := parseSizeClassThresholds(*sizeClasses)
+	if err != nil {
+		fatalf(context.Background(), "Некорректный -size_class_thresholds: %v", err)
+	}
+	if *coverageGrid < 0 || *coverageGrid > maxCoverageGrid {
+		fatalf(context.Background(), "-coverage_grid должен быть от 0 до %d", maxCoverageGrid)
+	}
+	if *maxResponseBytes < 0 {
+		fatalf(context.Background(), "-max_response_bytes не может быть отрицательным: %d", *maxResponseBytes)
+	}
+	if *maxRetries < 0 {
+		fatalf(context.Background(), "-max_retries не может быть отрицательным: %d", *maxRetries)
+	}
+	if *httpMethod == "" || strings.ContainsAny(*httpMethod, " \t\r\n") {
+		fatalf(context.Background(), "Некорректный метод -http_method: %q", *httpMethod)
+	}
+	if *coordOutput != coordOutputInt && *coordOutput != coordOutputFloat {
+		fatalf(context.Background(), "Неизвестный формат координат -coord_output: %q", *coordOutput)
+	}
+	polygon.FloatCoords = *coordOutput == coordOutputFloat
+	if *closure != "" && *closure != closureImplicit && *closure != closureExplicit {
+		fatalf(context.Background(), "Неизвестный способ замыкания -closure: %q", *closure)
+	}
+	if *dedupWeight != dedupWeightFirst && *dedupWeight != dedupWeightSum {
+		fatalf(context.Background(), "Неизвестный вес схлопнутой точки -dedup_weight: %q", *dedupWeight)
+	}
+	if *localHeatmap < 0 || *localHeatmap > maxLocalHeatmapGrid {
+		fatalf(context.Background(), "-local_heatmap должен быть от 0 до %d", maxLocalHeatmapGrid)
+	}
+	if *heavyThreshold < 0 {
+		fatalf(context.Background(), "-heavy_threshold не может быть отрицательным: %g", *heavyThreshold)
+	}
+	if *simplifyEpsilon < 0 {
+		fatalf(context.Background(), "-simplify_epsilon не может быть отрицательным: %g", *simplifyEpsilon)
+	}
+	if *heatmapGrid < 0 || *heatmapGrid > maxHeatmapGrid {
+		fatalf(context.Background(), "-heatmap_grid должен быть от 0 до %d", maxHeatmapGrid)
+	}
+
+	// Профили охватывают весь запуск, включая завершение по таймауту
+	if err := startProfiling(*cpuProfile, *memProfile); err != nil {
+		fatalf(context.Background(), "Не удалось включить профилирование: %v", err)
+	}
+	defer stopProfiling()
+
 	// Исправлено: используем стандартный импорт context вместо context2
 	// Контекст с таймаутом для правильного прерывания всех операций
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(*timeout))
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), time.Second*time.Duration(*timeout))
 	defer cancel()
+	// SIGINT и SIGTERM отменяют тот же контекст, что и таймаут: незавершенные
+	// запросы прерываются, а сборщик выводит частичный результат
+	ctx, stop := signal.NotifyContext(timeoutCtx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Идентификатор запуска передается через контекст во все логи и запросы
+	ctx = withRunID(ctx, newRunID())
+
+	// Метрики отдаются, пока работает основной контекст
+	if *metricsAddr != "" {
+		go serveMetrics(ctx, *metricsAddr)
+	}
+
+	// Один клиент на все запросы, чтобы соединения переиспользовались
+	client := polygon.NewHTTPClient(*numWorkers)
+	// Заголовки и авторизация одинаковы для всех запросов к серверу
+	header, err := requestHeader(*authBearer, *authBasic, extraHeaders)
+	if err != nil {
+		fatalf(ctx, "Некорректные параметры авторизации: %v", err)
+	}
 
+	// Число многоугольников берется из -polygons_num, либо запрашивается у сервера
+	total := *polygonsNum
+	if *countURL != "" {
+		n, err := fetchPolygonCount(ctx, client, *countURL, header)
+		if err != nil {
+			fatalf(ctx, "Не удалось получить число многоугольников: %v", err)
+		}
+		total = n
+	}
+
+	// Потоковый вывод открывается до подачи индексов: при дозаписи
+	// уже записанные индексы не нужно загружать повторно
+	var streamOut *streamWriter
+	var skip map[int]bool
+	if *streamOutput != "" {
+		f, done, err := openStreamOutput(*streamOutput, *appendOutput)
+		if err != nil {
+			fatalf(ctx, "Не удалось открыть файл потокового вывода: %v", err)
+		}
+		defer f.Close()
+		streamOut = newStreamWriter(f)
+		skip = done
+	}
+	// Результаты и ошибки по отдельным потокам для передачи по конвейеру:
+	// строки stdout и stderr разбираются независимо друг от друга
+	if *stdioStream {
+		if *streamOutput != "" || *errorsFile != "" {
+			fatalf(ctx, "-stdio_stream несовместим с -stream_output и -errors_file")
+		}
+		streamOut = newStreamWriter(os.Stdout)
+	}
+	// Для живой панели нужен только stdout: ошибки остаются в логе
+	if *streamStdout {
+		if *streamOutput != "" {
+			fatalf(ctx, "-stream несовместим с -stream_output")
+		}
+		streamOut = newStreamWriter(os.Stdout)
+	}
+
+	// Пропущенные индексы не попадут в агрегат, поэтому итоговый результат
+	// продолжения описывает только вновь обработанные многоугольники
+	pending := total
+	for i := range skip {
+		if i >= 0 && i < total {
+			pending--
+		}
+	}
+
+	// Прямоугольник обрезки разбираем заранее, чтобы ошибка в нем
+	// обнаружилась до начала загрузки
+	var clipRect *Bbox
+	if *clipBbox != "" {
+		rect, err := parseBbox(*clipBbox)
+		if err != nil {
+			fatalf(ctx, "Некорректный -clip_bbox: %v", err)
+		}
+		clipRect = &rect
+	}
+	var queryPoint *Point
+	if *containsPoint != "" {
+		p, err := parsePoint(*containsPoint)
+		if err != nil {
+			fatalf(ctx, "Некорректный -contains_point: %v", err)
+		}
+		queryPoint = &p
+	}
+
+	// Файл ошибок открываем до запуска, чтобы не потерять ни одной записи
+	var errorsOut *errorsWriter
+	if *errorsFile != "" {
+		f, err := os.Create(*errorsFile)
+		if err != nil {
+			fatalf(ctx, "Не удалось создать файл ошибок: %v", err)
+		}
+		defer f.Close()
+		errorsOut = newErrorsWriter(f)
+	}
+	if *stdioStream {
+		errorsOut = newErrorsWriter(os.Stderr)
+	}
+
+	// Пауза загрузки по сигналам SIGUSR1/SIGUSR2
+	pause := newPauseGate()
+	go watchPauseSignals(ctx, pause)
+
+	processOpts := processOptions{
+		diameter:         *diameter,
+		minWidth:         *minWidth,
+		complexity:       *complexity,
+		circleBoxRatio:   *circleBoxRatio,
+		turningSignature: *turningSignature,
+		weightGradient:   *weightGradient,
+		longestChord:     *longestChord,
+		skeleton:         *skeleton,
+		localHeatmap:     *localHeatmap,
+		bandwidth:        newBandwidthLimiter(*bandwidthLimit),
+		keepErrorBodies:  *errorsFile != "",
+		snapGrid:         *snapGrid,
+		dedupPoints:      *dedupPoints,
+		dedupWeight:      *dedupWeight,
+		bboxOnly:         *bboxOnly,
+		closure:          *closure,
+		normalizeWinding: *normalizeWinding,
+		simplifyEpsilon:  *simplifyEpsilon,
+		urls:             parseURLs(*endpointURLs),
+		hedge:            *hedge,
+		maxResponseBytes: *maxResponseBytes,
+		poolPoints:       *heatmapGrid == 0,
+		strictJSON:       *strictJSON,
+	}
+	if processOpts.hedge && len(processOpts.urls) < 2 {
+		fatalf(ctx, "-hedge требует не менее двух адресов в -urls")
+	}
+	urlTemplates, err := parseURLTemplates(append([]string{*serverURL}, processOpts.urls...)...)
+	if err != nil {
+		fatalf(ctx, "Некорректный шаблон адреса: %v", err)
+	}
+	processOpts.urlTemplates = urlTemplates
+	if *confidenceBbox {
+		if *confidenceLow < 0 || *confidenceLow > *confidenceHigh || *confidenceHigh > 100 {
+			fatalf(ctx, "Перцентили должны удовлетворять 0 <= -confidence_low <= -confidence_high <= 100")
+		}
+		processOpts.confidenceBand = &[2]float64{*confidenceLow, *confidenceHigh}
+	}
+	// Выражение -filter_expr заменяет критерий -heavy_by целиком
+	var heavy HeavyFunc
+	if *filterExpr != "" {
+		filter, err := ParseFilterExpr(*filterExpr)
+		if err != nil {
+			fatalf(ctx, "Некорректный -filter_expr: %v", err)
+		}
+		heavy = heavyByFilter(filter)
+	} else {
+		heavy, err = polygon.HeavyBy(*heavyByName, *heavyThreshold)
+		if err != nil {
+			fatalf(ctx, "Некорректный -heavy_by: %v", err)
+		}
+	}
+	priority, err := parsePriorityIndices(*priorityList)
+	if err != nil {
+		fatalf(ctx, "Некорректный -priority_indices: %v", err)
+	}
+	processOpts.priority = priority
+	if *inputFile != "" {
+		input, err := openPolygonFile(*inputFile)
+		if err != nil {
+			fatalf(ctx, "Не удалось прочитать -input_file: %v", err)
+		}
+		processOpts.input = input
+	}
+	if *breakerThreshold > 0 {
+		processOpts.breaker = newCircuitBreaker(*breakerThreshold, *breakerCooldown)
+	}
+
+	// Аффинное преобразование регистрируется, только если заданы его коэффициенты
+	if *affineParams != "" {
+		affine, err := ParseAffineTransformer(*affineParams)
+		if err != nil {
+			fatalf(ctx, "Некорректный -affine_params: %v", err)
+		}
+		RegisterTransformer("affine", affine)
+	}
+	if *reproject != "" {
+		transformer, err := lookupTransformer(*reproject)
+		if err != nil {
+			fatalf(ctx, "Некорректный -reproject: %v", err)
+		}
+		processOpts.transformer = transformer
+	}
+	fetcherOpts := []polygon.Option{
+		polygon.WithHTTPClient(client),
+		polygon.WithRetries(*maxRetries),
+		polygon.WithMethod(*httpMethod),
+		polygon.WithHeavyFunc(heavy),
+	}
+	for key, values := range header {
+		for _, value := range values {
+			fetcherOpts = append(fetcherOpts, polygon.WithHeader(key, value))
+		}
+	}
+	if *bodyTemplate != "" {
+		tmpl, err := template.New("request_body").Parse(*bodyTemplate)
+		if err != nil {
+			fatalf(ctx, "Некорректный -request_body_template: %v", err)
+		}
+		fetcherOpts = append(fetcherOpts, polygon.WithBodyTemplate(tmpl))
+	}
+	// Отдельные числа повторов соединения и запроса уточняют -max_retries
+	if *connectRetries >= 0 {
+		fetcherOpts = append(fetcherOpts, polygon.WithConnectRetries(*connectRetries))
+	}
+	if *requestRetries >= 0 {
+		fetcherOpts = append(fetcherOpts, polygon.WithRequestRetries(*requestRetries))
+	}
+	fetcher := newFetcher(processOpts, fetcherOpts...)
+
+	// Ограничение частоты запросов: общий бюджет и лимит на каждого воркера.
+	// Если есть приоритетные индексы, часть общего бюджета резервируется за ними
+	var priorityShare float64
+	if len(priority) > 0 {
+		priorityShare = priorityRateShare
+	}
+	limits := newFetchLimiter(*rateLimit, *perWorkerRate, priorityShare)
+
+	// Адаптивное снижение числа активных воркеров при ошибках сервера,
+	// а с -adaptive_workers - еще и при росте задержки
+	var throttle *workerThrottle
+	if *adaptiveWorkers {
+		if *minWorkers < 1 || *minWorkers > *numWorkers {
+			fatalf(ctx, "-min_workers должен быть от 1 до -workers (%d): %d", *numWorkers, *minWorkers)
+		}
+		throttle = newAdaptiveThrottle(*minWorkers, *numWorkers, *throttle5xx)
+	} else if *throttle5xx > 0 {
+		throttle = newWorkerThrottle(*numWorkers, *throttle5xx)
+	}
+
+	// Предыдущий результат загружается заранее, чтобы ошибка в файле
+	// обнаружилась до начала загрузки
+	var prevBboxes map[int]Bbox
+	if *prevResult != "" {
+		var err error
+		if prevBboxes, err = loadPrevBboxes(*prevResult); err != nil {
+			fatalf(ctx, "Не удалось загрузить -prev_result: %v", err)
+		}
+	}
+
+	collectOpts := collectOptions{
+		countOnly:         *countOnly,
+		importanceMetric:  *importanceMetric,
+		dedupByBbox:       *dedupByBbox,
+		coverageGrid:      *coverageGrid,
+		maxBboxWidth:      *maxBboxWidth,
+		maxBboxHeight:     *maxBboxHeight,
+		weightPercentiles: *weightPercents,
+		clip:              *clipToBbox,
+		clipRect:          clipRect,
+		mergeRadius:       *mergeRadius,
+		errors:            errorsOut,
+		errorsOnStderr:    *stdioStream,
+		stream:            streamOut,
+		prevBboxes:        prevBboxes,
+		areaBudget:        *areaBudget,
+		topN:              *topN,
+		sampleK:           *sampleK,
+		seed:              *seed,
+		adjacency:         *adjacency,
+		heatmapGrid:       *heatmapGrid,
+		snapshotInterval:  *snapshotInterval,
+		heavyThreshold:    *heavyThreshold,
+		perPolygon:        *perPolygon,
+		snapshotOut:       os.Stderr,
+		adjacencyTol:      *adjacencyTol,
+		overlaps:          *overlaps,
+		containsPoint:     queryPoint,
+		sizeClasses:       sizeClassBounds,
+	}
+
+	// Отдельный канал для финального результата
+	// Позволяет корректно обрабатывать ситуацию с таймаутом.
+	// Буфер на одно значение обязателен: если таймаут сработает одновременно
+	// с отправкой результата и main уже выберет ветку ctx.Done(), сборщик
+	// без буфера навсегда заблокируется на отправке (утечка горутины)
+	resCh := make(chan Result, 1)
+
+	// В последовательном режиме загрузка и обработка идут строго по порядку
+	// индексов в одной горутине, без пула воркеров. Итог совпадает с
+	// параллельным режимом с точностью до порядка тяжелых многоугольников
+	// (там он зависит от порядка ответов), поэтому служит детерминированным эталоном
+	if isWebsocketURL(*serverURL) {
+		// Сервер сам присылает многоугольники, индексы не раздаются
+		runWebsocket(ctx, *serverURL, *numWorkers, resCh, fetcher, collectOpts)
+	} else if *sequential {
+		go func() {
+			resCh <- runSequential(ctx, total, pending, skip, fetcher, collectOpts, limits)
+		}()
+	} else {
+		runPool(ctx, total, pending, skip, resCh, fetcher, collectOpts, limits, throttle, pause)
+	}
+
+	// Сборщик всегда присылает результат: полный или, по таймауту или сигналу,
+	// частичный. Повторный сигнал во время вывода завершает процесс сразу
+	result := <-resCh
+	// Частичный результат без отмены контекста означает, что лимит запросов
+	// не успевал до таймаута: это тоже таймаут, а не прерывание сигналом
+	interrupted := ctx.Err() != nil && timeoutCtx.Err() == nil
+	stop()
+	if *includeMeta {
+		result.Meta = newMeta(ctx, start, time.Now())
+	}
+	// Сводка скорости пишется в лог (stderr), чтобы не смешиваться с результатом
+	stats := metrics.runStats(time.Since(start), result.Processed)
+	logAttrs(ctx, slog.LevelInfo, "Статистика запуска",
+		"wall_time", stats.WallTime, "polygons_per_second", stats.PolygonsPerSecond,
+		"fetch_p50", stats.FetchLatency.P50, "fetch_p95", stats.FetchLatency.P95, "fetch_p99", stats.FetchLatency.P99)
+	if *includeStats {
+		result.Stats = stats
+	}
+
+	writeResult(ctx, result)
+
+	// Частичный результат выведен, но отдельный код выхода позволяет
+	// скриптам отличить его от полного, а прерывание сигналом - от таймаута
+	if result.Partial {
+		if interrupted {
+			warnf(ctx, "Прервано сигналом, обработано %d из %d многоугольников", result.Processed, result.Total)
+			exit(exitInterrupted)
+		}
+		warnf(ctx, "Превышено время выполнения (%d сек), обработано %d из %d многоугольников", *timeout, result.Processed, result.Total)
+		exit(exitTimeout)
+	}
+	// Ошибки отдельных многоугольников уже перечислены в результате,
+	// по умолчанию запуск с ними считается успешным
+	if *failOnError && result.err != nil {
+		fatalf(ctx, "Ошибки при обработке %d из %d многоугольников: %v", result.ErrorCount, result.Total, result.err)
+	}
+}
+
+// writeResult выводит результат в выбранном формате
+func writeResult(ctx context.Context, result Result) {
+	if *validateOutput {
+		if err := validateResult(result); err != nil {
+			fatalf(ctx, "Результат не соответствует схеме вывода: %v", err)
+		}
+	}
+
+	// Каждый тяжелый многоугольник в свой файл вместо вывода в stdout
+	if *splitOutputDir != "" && !*countOnly {
+		if err := writeSplitOutput(*splitOutputDir, result); err != nil {
+			fatalf(ctx, "Ошибка записи результатов: %v", err)
+		}
+		return
+	}
+
+	// Файл заменяется только полностью записанным результатом,
+	// поэтому при ошибке на его месте остается прежнее содержимое
+	if *outputFile != "" {
+		err := writeFileAtomic(*outputFile, *outputGzip, func(w io.Writer) error {
+			return encodeResult(w, result)
+		})
+		if err != nil {
+			fatalf(ctx, "Ошибка записи -output_file: %v", err)
+		}
+		return
+	}
+
+	if err := encodeResult(os.Stdout, result); err != nil {
+		fatalf(ctx, "Ошибка вывода результата: %v", err)
+	}
+}
+
+// encodeResult записывает результат в w в выбранном формате
+func encodeResult(w io.Writer, result Result) error {
+	// В режиме подсчета выводим одну строку со сводкой вместо JSON
+	if *countOnly {
+		_, err := fmt.Fprintf(w, "processed=%d errors=%d max_weight=%g\n", result.Processed, result.ErrorCount, result.MaxWeight)
+		return err
+	}
+
+	if *outputFormat == outputFormatSVG {
+		if err := writeSVG(w, result, *svgWeightColors); err != nil {
+			return fmt.Errorf("SVG: %v", err)
+		}
+		return nil
+	}
+
+	if *outputFormat == outputFormatGeoJSON {
+		if err := writeGeoJSON(w, result); err != nil {
+			return fmt.Errorf("GeoJSON: %v", err)
+		}
+		return nil
+	}
+
+	// Исправлено форматирование вывода JSON с отступами для лучшей читаемости.
+	// После потока строк JSON сводка тоже выводится одной строкой
+	var output []byte
+	var err error
+	if *stdioStream || *streamStdout {
+		output, err = json.Marshal(result)
+	} else {
+		output, err = json.MarshalIndent(result, "", "  ")
+	}
+	if err != nil {
+		return fmt.Errorf("сериализация JSON: %v", err)
+	}
+	_, err = fmt.Fprintln(w, string(output))
+	return err
+}
+
+// runPool запускает пул воркеров, подачу индексов и агрегацию результатов.
+// Итоговый Result отправляется в resCh
+func runPool(ctx context.Context, total, pending int, skip map[int]bool, resCh chan Result,
+	fetcher *cliFetcher, collectOpts collectOptions, limits *fetchLimiter,
+	throttle *workerThrottle, pause *pauseGate) {
 	// Реорганизация архитектуры для устранения гонок данных:
 	// - используем каналы для координации работы
 	// - разделяем загрузку, обработку и агрегацию результатов
-	indices := make(chan int, *polygonsNum)
+	indices := make(chan int, total)
 	results := make(chan PolygonResult, 100)
 	var wg sync.WaitGroup
 
+	// Воркер, которому лимит запросов не выдаст токен до таймаута, останавливает
+	// всех: остальные все равно не успеют, а сборщик по отмене сразу отдает
+	// частичный результат. Контекст отменяется и после завершения сборщика
+	ctx, stopWorkers := context.WithCancel(ctx)
+
+	// Дубликаты с -dedup_by_bbox не должны попадать в агрегат,
+	// а частичный агрегат пачки их уже учел бы, поэтому пачки отключаются
+	batchSize := *batchSize
+	if collectOpts.dedupByBbox {
+		batchSize = 1
+	}
+
 	// Запускаем воркеров динамически, основываясь на доступных CPU или параметре командной строки
 	// Это более эффективно, чем фиксированные 10 горутин из исходного кода
 	for i := 0; i < *numWorkers; i++ {
 		wg.Add(1)
+		limiter := limits.forWorker()
+		workerID := i
 		go func() {
 			defer wg.Done()
+
+			// С -batch_size результаты копятся в пачке и уходят сборщику вместе
+			var batch *resultBatch
+			if batchSize > 1 {
+				batch = newResultBatch(batchSize)
+			}
+
 			for {
+				// Ограничение числа активных воркеров проверяется до получения
+				// индекса, иначе приостановленный воркер удерживал бы его у себя
+				if throttle != nil {
+					if err := throttle.Wait(ctx, workerID); err != nil {
+						return
+					}
+				}
+
 				select {
 				case <-ctx.Done():
 					// Обработка таймаута: завершаем воркера при истечении времени
 					return
 				case idx, ok := <-indices:
 					if !ok {
-						// Корректное завершение при закрытии канала индексов
+						// Корректное завершение при закрытии канала индексов.
+						// Приостановленных воркеров тоже отпускаем, работы для них больше нет
+						if throttle != nil {
+							throttle.Stop()
+						}
+						if batch != nil && !batch.empty() {
+							select {
+							case <-ctx.Done():
+							case results <- PolygonResult{batch: batch}:
+							}
+						}
 						return
 					}
+					// Перед загрузкой ждем снятия паузы, таймаут при этом продолжает действовать
+					if err := pause.Wait(ctx); err != nil {
+						return
+					}
+					// Ожидание лимита также прерывается по таймауту.
+					// Приоритетные индексы не ждут собственного лимита воркера
+					if err := limiter.Wait(ctx, fetcher.opts.priority[idx]); err != nil {
+						stopWorkers()
+						return
+					}
+
 					// Вынесено в отдельную функцию для лучшей модульности и тестируемости
-					polygonResult := fetchAndProcessPolygon(ctx, idx)
-					
+					fetchStart := time.Now()
+					polygonResult := fetcher.fetchAndProcessPolygon(ctx, idx)
+					latency := time.Since(fetchStart)
+					if PostProcess != nil {
+						PostProcess(&polygonResult)
+					}
+					if throttle != nil {
+						throttle.Report(polygonResult.statusCode >= 500, latency)
+					}
+					if batch != nil {
+						batch.add(polygonResult, collectOpts)
+						if !batch.full() {
+							continue
+						}
+						polygonResult = PolygonResult{batch: batch}
+						batch = newResultBatch(batchSize)
+					}
+
 					// Правильная обработка отправки результата с учетом возможного таймаута
 					select {
 					case <-ctx.Done():
@@ -132,8 +1064,12 @@ func main() {
 
 	// Отдельная горутина для подачи индексов в канал
 	// Это предотвращает блокировку основного потока
+	// Приоритетные индексы подаются первыми
 	go func() {
-		for i := 0; i < *polygonsNum; i++ {
+		for _, i := range fetcher.opts.priority.order(total) {
+			if skip[i] {
+				continue
+			}
 			select {
 			case <-ctx.Done():
 				close(indices)
@@ -144,10 +1080,10 @@ func main() {
 		close(indices)
 	}()
 
-	// Отдельный канал для финального результата
-	// Позволяет корректно обрабатывать ситуацию с таймаутом
-	resCh := make(chan Result)
-	go collectResults(ctx, results, *polygonsNum, resCh)
+	go func() {
+		collectResults(ctx, results, pending, resCh, collectOpts)
+		stopWorkers()
+	}()
 
 	// Отдельная горутина для ожидания завершения всех воркеров
 	// Это позволяет корректно закрыть канал results после завершения всех обработчиков
@@ -155,65 +1091,265 @@ func main() {
 		wg.Wait()
 		close(results)
 	}()
+}
 
-	// Ожидание результата или таймаута с корректной обработкой ошибок
-	select {
-	case <-ctx.Done():
-		log.Printf("Превышено время выполнения (%d сек)", *timeout)
-		os.Exit(1)
-	case result := <-resCh:
-		// Исправлено форматирование вывода JSON с отступами для лучшей читаемости
-		output, err := json.MarshalIndent(result, "", "  ")
+// Разделение монолитной функции для улучшения тестируемости и модульности
+// Загрузка и обработка полигона теперь в отдельной функции
+func (f *cliFetcher) fetchAndProcessPolygon(ctx context.Context, idx int) PolygonResult {
+	// Индекс сохраняется в результате на любом пути, включая ошибки,
+	// чтобы сборщик и вывод могли сослаться на конкретный многоугольник
+	start := time.Now()
+	result := f.loadAndProcessPolygon(ctx, idx)
+	metrics.observeFetch(time.Since(start))
+	result.index = idx
+	metrics.recordFetch(result)
+	if result.err != nil {
+		result.err = fmt.Errorf("многоугольник %d: %w", idx, result.err)
+	}
+	return result
+}
+
+// loadAndProcessPolygon получает многоугольник из файла или с сервера
+// (с повторами) и обрабатывает его
+func (f *cliFetcher) loadAndProcessPolygon(ctx context.Context, idx int) PolygonResult {
+	// С -input_file многоугольники читаются из файла, а не с сервера
+	if f.opts.input != nil {
+		return readAndProcessPolygon(ctx, idx, f.opts)
+	}
+
+	// Сетевые ошибки и ответы 5xx обычно временные, поэтому запрос повторяется
+	// с экспоненциальной задержкой. Ошибки 4xx и разбора не повторяются.
+	// Сбои соединения и сбои уже отправленного запроса считаются отдельно:
+	// запрос, который сервер мог частично выполнить, повторяется только для
+	// идемпотентных методов. Приоритетные индексы получают больше повторов,
+	// но и для них такой запрос неидемпотентного метода не повторяется
+	connectRetries, requestRetries := f.Retries()
+	connectRetries = f.opts.priority.retries(idx, connectRetries)
+	if polygon.IdempotentMethod(f.Method()) {
+		requestRetries = f.opts.priority.retries(idx, requestRetries)
+	}
+	var result PolygonResult
+	var retry polygon.RetryKind
+	polygon.Retry(ctx, connectRetries, requestRetries, func(attempt int) polygon.RetryKind {
+		if attempt > 1 {
+			logAttrs(ctx, slog.LevelDebug, "Повтор загрузки многоугольника",
+				"polygon_index", idx, "attempt", attempt, "retry_kind", retry.String(),
+				"status_code", result.statusCode, "error", result.err)
+		}
+		result, retry = f.fetchPolygon(ctx, idx)
+		return retry
+	})
+	return result
+}
+
+// fetchPolygonOnce выполняет одну попытку загрузки и обработки многоугольника.
+// Второе значение сообщает, имеет ли смысл повторить попытку и какой это сбой
+func (f *cliFetcher) fetchPolygonOnce(ctx context.Context, idx int, url string) (PolygonResult, polygon.RetryKind) {
+	opts := f.opts
+
+	// Индекс подставляется в адрес с шаблоном, например /polygon/{index}
+	if tmpl := opts.urlTemplates[url]; tmpl != nil {
+		expanded, err := expandURL(tmpl, idx)
 		if err != nil {
-			log.Fatalf("Ошибка сериализации JSON: %v", err)
+			return PolygonResult{err: fmt.Errorf("ошибка шаблона адреса: %v", err)}, polygon.RetryNever
 		}
-		fmt.Println(string(output))
+		url = expanded
 	}
-}
 
-// Разделение монолитной функции для улучшения тестируемости и модульности
-// Загрузка и обработка полигона теперь в отдельной функции
-func fetchAndProcessPolygon(ctx context.Context, idx int) PolygonResult {
-	// Создаем HTTP-клиент с явным таймаутом вместо использования DefaultClient
-	// Это предотвращает зависание запросов
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	
-	// Используем запрос с контекстом для поддержки отмены по таймауту
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, *serverURL, nil)
+	// Используем запрос с контекстом для поддержки отмены по таймауту.
+	// Таймаут отдельного запроса задается контекстом, а не клиентом, общим для всех воркеров.
+	// Метод, тело и заголовки запроса задаются опциями polygon.Fetcher
+	reqCtx, cancel := context.WithTimeout(ctx, f.Timeout())
+	defer cancel()
+	req, err := f.NewRequest(reqCtx, idx, url)
 	if err != nil {
-		return PolygonResult{err: fmt.Errorf("ошибка создания запроса: %v", err)}
+		return PolygonResult{err: err}, polygon.RetryNever
+	}
+	// Сообщаем серверу, что умеем принимать MessagePack, оставляя JSON запасным вариантом
+	req.Header.Set("Accept", acceptHeader)
+	// Сжатие запрашивается явно, а не прозрачно через Transport: так ответ
+	// распаковывается здесь же и -max_response_bytes ограничивает распакованный размер
+	req.Header.Set("Accept-Encoding", "gzip")
+	// Идентификатор запуска позволяет сопоставить наши логи с логами сервера
+	if id := runIDFrom(ctx); id != "" {
+		req.Header.Set(runIDHeader, id)
 	}
-	
+	// Оставшееся до таймаута время позволяет серверу прервать работу,
+	// результат которой мы уже не дождемся
+	setDeadlineHeader(req)
+
+	// Пока предохранитель разомкнут, сервер не нагружаем
+	if opts.breaker != nil {
+		if err := opts.breaker.Allow(); err != nil {
+			return PolygonResult{err: err}, polygon.RetryNever
+		}
+	}
+
 	// Детальная обработка ошибок HTTP вместо простого "fail"
-	resp, err := client.Do(req)
+	resp, err := f.Client().Do(req)
+	// Сбоем сервера считаем сетевую ошибку или статус 5xx. Отмененный
+	// запрос (например, проигравший в -hedge) о сервере ничего не говорит
+	if opts.breaker != nil {
+		if err != nil && ctx.Err() != nil {
+			opts.breaker.Cancel()
+		} else {
+			opts.breaker.Record(err == nil && resp.StatusCode < 500)
+		}
+	}
 	if err != nil {
-		return PolygonResult{err: fmt.Errorf("ошибка HTTP запроса: %v", err)}
+		return PolygonResult{err: fmt.Errorf("ошибка HTTP запроса: %v", err)}, polygon.HTTPErrorKind(err)
 	}
 	defer resp.Body.Close() // Добавлен для предотвращения утечек ресурсов
-	
+
 	if resp.StatusCode != http.StatusOK {
-		return PolygonResult{err: fmt.Errorf("некорректный статус ответа: %d", resp.StatusCode)}
+		return PolygonResult{err: statusError(resp.StatusCode), statusCode: resp.StatusCode}, polygon.StatusKind(resp.StatusCode)
+	}
+
+	var body io.Reader = resp.Body
+	if opts.bandwidth != nil {
+		body = &throttledReader{ctx: reqCtx, r: resp.Body, limiter: opts.bandwidth}
+	}
+	// Лимит скорости считает байты из сети, поэтому распаковка идет после него
+	if isGzip(resp.Header.Get("Content-Encoding")) {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return PolygonResult{err: fmt.Errorf("ошибка распаковки gzip: %v", err)}, polygon.RetryNever
+		}
+		defer gz.Close()
+		body = gz
+	}
+	// Размер тела ограничен, чтобы некорректный сервер не исчерпал память
+	var limited *io.LimitedReader
+	if opts.maxResponseBytes > 0 {
+		limited = limitBody(body, opts.maxResponseBytes)
+		body = limited
+	}
+	tooLarge := func() bool { return limited != nil && limited.N <= 0 }
+
+	// JSON разбирается потоком прямо из соединения. Целиком тело нужно только
+	// для MessagePack, для разбора одних координат и для сохранения тела ошибки
+	contentType := resp.Header.Get("Content-Type")
+	if !isMsgpack(contentType) && !opts.bboxOnly && !opts.keepErrorBodies {
+		src := &readErrRecorder{r: body}
+		shape, err := decodeShapeStream(src, getPoints(), opts.strictJSON)
+		switch {
+		case tooLarge():
+			return PolygonResult{err: fmt.Errorf("тело ответа больше %d байт (-max_response_bytes)", opts.maxResponseBytes)}, polygon.RetryNever
+		case src.err != nil:
+			return PolygonResult{err: fmt.Errorf("ошибка чтения ответа: %v", src.err)}, polygon.RetryRequest
+		case err != nil:
+			return PolygonResult{err: err}, polygon.RetryNever
+		}
+		result := processShape(shape, ctx, opts)
+		// Точки возвращаются в пул, только если на многоугольник не останется
+		// ссылок: тяжелые выводятся в результате, с -heatmap_grid сборщик
+		// хранит все многоугольники, а хук PostProcess может сохранить результат
+		if opts.poolPoints && !result.isHeavy && PostProcess == nil {
+			result.polygon = nil
+			releasePoints(shape.Vertices())
+		}
+		return result, polygon.RetryNever
+	}
+
+	// Тело читается в переиспользуемый буфер из пула, который возвращается
+	// после разбора на любом пути выполнения
+	respBody, err := readBody(body)
+	defer releaseBody(respBody)
+	if tooLarge() {
+		return PolygonResult{err: fmt.Errorf("тело ответа больше %d байт (-max_response_bytes)", opts.maxResponseBytes)}, polygon.RetryNever
 	}
-	
-	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return PolygonResult{err: fmt.Errorf("ошибка чтения ответа: %v", err)}
+		return PolygonResult{err: fmt.Errorf("ошибка чтения ответа: %v", err)}, polygon.RetryRequest
+	}
+
+	// Формат тела определяется по Content-Type ответа. Когда нужен только bbox,
+	// JSON разбирается без весов, если строгий режим не требует проверить весь ответ
+	var shape Shape
+	if opts.bboxOnly && !opts.strictJSON && !isMsgpack(contentType) {
+		shape, err = decodeShapeCoords(respBody.Bytes())
+	} else {
+		shape, err = decodeShape(contentType, respBody.Bytes(), opts.strictJSON)
 	}
-	
-	var poly Polygon
-	err = json.Unmarshal(respBody, &poly)
 	if err != nil {
-		return PolygonResult{err: fmt.Errorf("ошибка разбора JSON: %v", err)}
+		// Буфер вернется в пул, поэтому тело для разбора ошибок копируем
+		var raw []byte
+		if opts.keepErrorBodies {
+			raw = bytes.Clone(respBody.Bytes())
+		}
+		return PolygonResult{err: err, rawBody: raw}, polygon.RetryNever
 	}
-	
+
 	// Вынесено в отдельную функцию для разделения загрузки и обработки
-	return processPolygon(&poly, ctx)
+	return processShape(shape, ctx, opts), polygon.RetryNever
+}
+
+// processShape обрабатывает фигуру любого вида. Bbox и вес ломаной считаются
+// так же, как у многоугольника, но выпуклость для незамкнутой фигуры не определена
+func processShape(shape Shape, ctx context.Context, opts processOptions) PolygonResult {
+	if poly, ok := shape.(*Polygon); ok {
+		return processPolygon(poly, ctx, opts)
+	}
+
+	// Замыкание и направление обхода имеют смысл только для многоугольников
+	opts.closure = ""
+	opts.normalizeWinding = false
+	result := processPolygon(&Polygon{Points: shape.Vertices()}, ctx, opts)
+	if result.heavy != nil {
+		result.heavy.Type = shapeTypePolyline
+		result.heavy.Convex = false
+		result.heavy.Orientation = ""
+	}
+	return result
+}
+
+// fetchPolygonCount запрашивает у сервера общее число многоугольников.
+// Ожидается тело ответа с одним положительным целым числом
+func fetchPolygonCount(ctx context.Context, client *http.Client, url string, header http.Header) (int, error) {
+	ctx, cancel := withRequestTimeout(ctx)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка создания запроса: %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if id := runIDFrom(ctx); id != "" {
+		req.Header.Set(runIDHeader, id)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка HTTP запроса: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, statusError(resp.StatusCode)
+	}
+
+	// Число не может быть длинным, ограничиваем чтение на случай некорректного ответа
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка чтения ответа: %v", err)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(string(body)))
+	if err != nil {
+		return 0, fmt.Errorf("ответ не является целым числом: %q", body)
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("число многоугольников должно быть положительным: %d", n)
+	}
+	return n, nil
 }
 
 // Вынесено в отдельную функцию для улучшения модульности и тестируемости
-func processPolygon(poly *Polygon, ctx context.Context) PolygonResult {
+func processPolygon(poly *Polygon, ctx context.Context, opts processOptions) PolygonResult {
+	start := time.Now()
+	defer func() { metrics.observeProcessing(time.Since(start)) }()
+
 	// Обработка краевого случая с пустым полигоном
 	if len(poly.Points) == 0 {
 		return PolygonResult{
@@ -224,102 +1360,540 @@ func processPolygon(poly *Polygon, ctx context.Context) PolygonResult {
 		}
 	}
 
-	// Инициализация bbox первой точкой для корректных начальных значений
-	bbox := Bbox{
-		X1: poly.Points[0].X,
-		Y1: poly.Points[0].Y,
-		X2: poly.Points[0].X,
-		Y2: poly.Points[0].Y,
+	// Перепроецирование выполняется до всех расчетов, чтобы bbox и
+	// характеристики считались уже в целевой системе координат
+	if opts.transformer != nil {
+		for i := range poly.Points {
+			p := &poly.Points[i]
+			p.X, p.Y = opts.transformer.Transform(p.X, p.Y)
+		}
+	}
+	// Привязка к сетке после преобразования: несколько точек могут попасть
+	// в один узел, их веса объединяются
+	if opts.snapGrid > 0 {
+		poly.Points = SnapToGrid(poly.Points, opts.snapGrid)
+	}
+	// Дубликаты убираются до расчета веса и bbox, чтобы оба отражали
+	// выбранное правило веса
+	if opts.dedupPoints {
+		poly.Points = DedupPoints(poly.Points, opts.dedupWeight)
+	}
+	// Замыкание приводится к нужному виду до расчета характеристик
+	if opts.closure != "" {
+		poly.Points = ApplyClosure(poly.Points, opts.closure)
+	}
+
+	// Bbox, суммарный вес и площадь по формуле шнурования считает пакет polygon.
+	// Контекст проверяется по ходу обхода для раннего прерывания при таймауте,
+	// это важно для больших полигонов (до 1М точек)
+	stats, err := polygon.ProcessPolygon(ctx, poly)
+	if err != nil {
+		return PolygonResult{err: err}
 	}
+	bbox, sumWeight, area := stats.Bbox, stats.Weight, stats.Area
+	var centroid Point
+	centroid.X, centroid.Y = Centroid(poly)
 
-	// Вычисление суммарного веса и обновление bbox
-	sumWeight := float32(0)
-	for i, p := range poly.Points {
-		// Регулярная проверка контекста для раннего прерывания при таймауте
-		// Это важно для больших полигонов (до 1М точек)
-		if i%1000 == 0 && ctx.Err() != nil {
-			return PolygonResult{err: ctx.Err()}
-		}
-		
-		sumWeight += p.Weight
-		bbox.X1 = MinInt(bbox.X1, p.X)
-		bbox.Y1 = MinInt(bbox.Y1, p.Y)
-		bbox.X2 = MaxInt(bbox.X2, p.X)
-		bbox.Y2 = MaxInt(bbox.Y2, p.Y)
+	// Bbox без выбросов заменяет обычный, вес при этом считается по всем точкам
+	if opts.confidenceBand != nil {
+		bbox = ConfidenceBbox(poly.Points, opts.confidenceBand[0], opts.confidenceBand[1])
 	}
 
-	// Критерий "тяжелого" полигона согласно ТЗ (>= 100)
-	isHeavy := sumWeight >= 100
+	// Критерий "тяжелого" полигона: по ТЗ вес >= 100, величина и порог задаются
+	// -heavy_by и -heavy_threshold, выражение -filter_expr заменяет их целиком.
+	// Критерий вызывается после обхода точек и не замедляет основной цикл
+	isHeavy := opts.heavy(poly, sumWeight, area)
+	if opts.bboxOnly {
+		isHeavy = false
+	}
+
+	// Характеристики нужны только в выходном списке тяжелых полигонов,
+	// поэтому дополнительные проходы по точкам делаем лишь для них.
+	// Считаем их в воркере, чтобы не нагружать единственную горутину агрегации
+	var heavy *HeavyPolygon
+	if isHeavy {
+		heavy = &HeavyPolygon{
+			Polygon: poly,
+			Convex:  IsConvex(poly),
+			weight:  sumWeight,
+			Area:    area,
+		}
+		if opts.normalizeWinding {
+			heavy.Polygon = NormalizeWinding(poly)
+		}
+		// Упрощается только выводимый контур: вес, площадь и bbox
+		// посчитаны по всем точкам
+		if opts.simplifyEpsilon > 0 {
+			heavy.Polygon = &Polygon{Points: SimplifyRDP(heavy.Points, opts.simplifyEpsilon)}
+		}
+		heavy.Orientation = Orientation(heavy.Polygon)
+		if opts.diameter {
+			diameter := PolygonDiameter(poly)
+			heavy.Diameter = &diameter
+		}
+		if opts.minWidth {
+			width := MinimumWidth(poly)
+			heavy.MinWidth = &width
+		}
+		if opts.complexity {
+			score := ComplexityScore(poly)
+			heavy.Complexity = &score
+		}
+		if opts.circleBoxRatio {
+			ratio := CircleBoxRatio(poly, bbox)
+			heavy.CircleBoxRatio = &ratio
+		}
+		if opts.turningSignature {
+			heavy.TurningAngles = TurningAngles(poly)
+		}
+		if opts.weightGradient {
+			heavy.WeightGradient = WeightGradient(poly)
+		}
+		if opts.longestChord {
+			if a, b, length := LongestInternalChord(poly); length > 0 {
+				heavy.LongestChord = &Chord{A: a, B: b, Length: length}
+			}
+		}
+		if opts.skeleton {
+			heavy.Skeleton = Skeleton(poly)
+		}
+		if opts.localHeatmap > 0 {
+			heavy.LocalHeatmap = LocalHeatmap(poly, opts.localHeatmap)
+		}
+	}
 
 	// Возвращаем структурированный результат для последующей агрегации
 	return PolygonResult{
-		localBbox: bbox,
-		weight:    sumWeight,
-		isHeavy:   isHeavy,
-		polygon:   poly,
+		localBbox:  bbox,
+		weight:     sumWeight,
+		isHeavy:    isHeavy,
+		polygon:    poly,
+		heavy:      heavy,
+		pointCount: len(poly.Points),
+		area:       area,
+		centroid:   centroid,
 	}
 }
 
-// Отдельная функция для безопасной агрегации результатов 
-// Устраняет гонки данных, так как только один поток модифицирует Result
-func collectResults(ctx context.Context, results chan PolygonResult, total int, resCh chan Result) {
-	// Инициализация начальных значений bbox для корректного поиска минимума/максимума
-	result := Result{
-		Bbox: Bbox{
-			X1: math.MaxInt,
-			Y1: math.MaxInt,
-			X2: math.MinInt,
-			Y2: math.MinInt,
-		},
-		MaxWeight:     0,
-		HeavyPolygons: []*Polygon{},
+// Ключ для дедупликации: совпадение bbox и веса гораздо дешевле проверить,
+// чем сравнивать многоугольники поточечно
+type bboxDedupKey struct {
+	bbox   Bbox
+	weight float64
+}
+
+type bboxDedup map[bboxDedupKey]struct{}
+
+// seen запоминает результат и сообщает, встречался ли такой же ранее
+func (d bboxDedup) seen(pr PolygonResult) bool {
+	key := bboxDedupKey{bbox: pr.localBbox, weight: pr.weight}
+	if _, ok := d[key]; ok {
+		return true
 	}
+	d[key] = struct{}{}
+	return false
+}
 
-	// Отслеживаем количество обработанных полигонов и ошибки
-	processed := 0
-	var processingError error
+// Отдельная функция для безопасной агрегации результатов
+// Устраняет гонки данных, так как только один поток модифицирует Result
+func collectResults(ctx context.Context, results chan PolygonResult, total int, resCh chan Result, opts collectOptions) {
+	agg := newAggregator(ctx, total, opts)
+
+	// Промежуточные снимки агрегата выводятся по таймеру, не прерывая сбор
+	var snapshots <-chan time.Time
+	if opts.snapshotInterval > 0 {
+		ticker := time.NewTicker(opts.snapshotInterval)
+		defer ticker.Stop()
+		snapshots = ticker.C
+	}
 
 	// Обработка результатов по мере поступления для эффективного использования памяти
-	for polygonResult := range results {
-		// Централизованная обработка ошибок
-		if polygonResult.err != nil {
-			processingError = polygonResult.err
-			log.Printf("Ошибка обработки многоугольника: %v", polygonResult.err)
+loop:
+	for {
+		select {
+		case polygonResult, ok := <-results:
+			if !ok {
+				break loop
+			}
+			// Отправка результата при обработке всех полигонов
+			if agg.add(polygonResult) {
+				resCh <- agg.finish()
+				return
+			}
+		case <-snapshots:
+			if err := agg.snapshot(opts.snapshotOut); err != nil {
+				warnf(ctx, "Ошибка вывода промежуточного результата: %v", err)
+			}
+		case <-ctx.Done():
+			// По таймауту отдаем то, что успели накопить, вместо пустого выхода
+			resCh <- agg.partial()
+			return
+		}
+	}
+
+	// Воркеры по таймауту тоже завершаются и закрывают канал,
+	// поэтому закрытие может быть замечено раньше отмены контекста
+	if ctx.Err() != nil {
+		resCh <- agg.partial()
+		return
+	}
+	// Если число многоугольников неизвестно, поток завершается закрытием канала
+	if total == totalUnknown {
+		resCh <- agg.finish()
+		return
+	}
+	// Незавершенный запуск завершает программу, сюда доходит лишь пустой набор:
+	// например, -polygons_num 0 или -append_output, когда все индексы уже записаны.
+	// Результат отправляется всегда, иначе main ждал бы его бесконечно
+	agg.fail()
+	resCh <- agg.finish()
+}
+
+// Число многоугольников, когда оно становится известно только по окончании потока
+const totalUnknown = -1
+
+// runSequential загружает и обрабатывает многоугольники строго по порядку
+// индексов в вызывающей горутине, без каналов и пула воркеров.
+// Агрегация та же, что и в collectResults
+func runSequential(ctx context.Context, total, pending int, skip map[int]bool, fetcher *cliFetcher,
+	collectOpts collectOptions, limits *fetchLimiter) Result {
+	agg := newAggregator(ctx, pending, collectOpts)
+	// Лимиты запросов действуют так же, как для единственного воркера пула
+	limiter := limits.forWorker()
+	for i := 0; i < total && ctx.Err() == nil; i++ {
+		if skip[i] {
 			continue
 		}
+		// Если токен не появится до истечения времени, Wait сообщает об этом
+		// сразу. Дожидаться самого таймаута незачем, частичный результат уже известен
+		if err := limiter.Wait(ctx, fetcher.opts.priority[i]); err != nil {
+			return agg.partial()
+		}
+		polygonResult := fetcher.fetchAndProcessPolygon(ctx, i)
+		if PostProcess != nil {
+			PostProcess(&polygonResult)
+		}
+		if agg.add(polygonResult) {
+			return agg.finish()
+		}
+	}
+	if ctx.Err() != nil {
+		return agg.partial()
+	}
+	// Незавершенный запуск завершает программу, сюда доходит лишь пустой набор
+	agg.fail()
+	return agg.finish()
+}
+
+// aggregator накапливает результаты отдельных многоугольников в общий Result.
+// Используется из одной горутины, поэтому синхронизация не нужна
+type aggregator struct {
+	ctx   context.Context
+	opts  collectOptions
+	total int
 
-		// Безопасное обновление общего bbox - только в одной горутине
-		result.Bbox.X1 = MinInt(result.Bbox.X1, polygonResult.localBbox.X1)
-		result.Bbox.Y1 = MinInt(result.Bbox.Y1, polygonResult.localBbox.Y1)
-		result.Bbox.X2 = MaxInt(result.Bbox.X2, polygonResult.localBbox.X2)
-		result.Bbox.Y2 = MaxInt(result.Bbox.Y2, polygonResult.localBbox.Y2)
+	result Result
 
-		// Безопасное обновление максимального веса
-		result.MaxWeight = MaxFloat32(result.MaxWeight, polygonResult.weight)
+	// Отслеживаем количество обработанных полигонов и ошибки
+	processed int
+	errs      []error
 
-		// Добавление тяжелых полигонов безопасно в одной горутине
-		if polygonResult.isHeavy {
-			result.HeavyPolygons = append(result.HeavyPolygons, polygonResult.polygon)
+	// Фильтр дубликатов по bbox и весу, работает только с -dedup_by_bbox
+	dedup bboxDedup
+
+	// Локальные bbox сохраняются только для расчета покрытия
+	localBboxes []Bbox
+
+	// Потоковая оценка перцентилей не требует хранить все веса
+	percentiles *weightPercentiles
+
+	// Все многоугольники сохраняются только для тепловой карты
+	polygons []*Polygon
+}
+
+func newAggregator(ctx context.Context, total int, opts collectOptions) *aggregator {
+	agg := &aggregator{
+		ctx:   ctx,
+		opts:  opts,
+		total: total,
+		// Инициализация начальных значений bbox для корректного поиска минимума/максимума
+		result: Result{
+			Bbox:           polygon.EmptyBbox(),
+			MaxWeight:      0,
+			HeavyThreshold: opts.heavyThreshold,
+			HeavyPolygons:  []*HeavyPolygon{},
+		},
+		dedup: bboxDedup{},
+	}
+	if opts.weightPercentiles {
+		agg.percentiles = newWeightPercentiles()
+	}
+	return agg
+}
+
+// add учитывает очередной результат и сообщает, обработаны ли все многоугольники
+func (a *aggregator) add(polygonResult PolygonResult) bool {
+	if batch := polygonResult.batch; batch != nil {
+		a.result.merge(batch.bbox, batch.maxWeight)
+		for _, member := range batch.results {
+			a.addOne(member, true)
 		}
+	} else {
+		a.addOne(polygonResult, false)
+	}
 
-		processed++
-		
-		// Отправка результата при обработке всех полигонов
-		if processed == total {
-			resCh <- result
-			return
+	// Ошибки не прерывают работу: многоугольник с ошибкой тоже считается
+	// завершенным, а ошибка попадает в список errors результата
+	return a.processed+a.result.ErrorCount == a.total
+}
+
+// addOne учитывает один результат. merged означает, что bbox и вес
+// уже вошли в агрегат вместе с пачкой воркера
+func (a *aggregator) addOne(polygonResult PolygonResult, merged bool) {
+	ctx, opts := a.ctx, a.opts
+
+	// Централизованная обработка ошибок
+	if polygonResult.err != nil {
+		a.errs = append(a.errs, polygonResult.err)
+		a.result.Errors = append(a.result.Errors, PolygonError{
+			Index:      polygonResult.index,
+			StatusCode: polygonResult.statusCode,
+			Error:      polygonResult.err.Error(),
+		})
+		if !opts.errorsOnStderr {
+			logAttrs(ctx, slog.LevelError, "Ошибка обработки многоугольника",
+				"polygon_index", polygonResult.index, "status_code", polygonResult.statusCode,
+				"error", polygonResult.err)
+		}
+		if opts.errors != nil {
+			if err := opts.errors.Write(polygonResult); err != nil {
+				warnf(ctx, "Ошибка записи в файл ошибок: %v", err)
+			}
+		}
+		a.result.ErrorCount++
+	} else if opts.dedupByBbox && a.dedup.seen(polygonResult) {
+		// Дубликат считается обработанным, но в агрегат не попадает
+		a.processed++
+	} else {
+		if merged {
+			a.result.addHeavy(polygonResult, opts)
+		} else {
+			a.result.add(polygonResult, opts)
+		}
+		if opts.stream != nil {
+			if err := opts.stream.Write(polygonResult); err != nil {
+				warnf(ctx, "Ошибка потокового вывода: %v", err)
+			}
+		}
+		if opts.coverageGrid > 0 {
+			a.localBboxes = append(a.localBboxes, polygonResult.localBbox)
+		}
+		if opts.heatmapGrid > 0 {
+			a.polygons = append(a.polygons, polygonResult.polygon)
+		}
+		if opts.perPolygon {
+			a.result.Polygons = append(a.result.Polygons, summarize(polygonResult))
+		}
+		if a.percentiles != nil {
+			a.percentiles.Add(polygonResult.weight)
 		}
+		a.processed++
 	}
+}
+
+// snapshot выводит текущий агрегат одной строкой JSON с пометкой snapshot.
+// Шаги finish не выполняются: они требуют полного набора и меняют тяжелые
+// многоугольники, а сбор после снимка продолжается
+func (a *aggregator) snapshot(w io.Writer) error {
+	snapshot := a.result
+	snapshot.Snapshot = true
+	a.setProgress(&snapshot)
+	for _, heavy := range snapshot.HeavyPolygons {
+		snapshot.TotalHeavyArea += heavy.Area
+	}
+	snapshot.setBboxSize()
+	line, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// setProgress заполняет счетчики обработанных и всех многоугольников.
+// Если общее число неизвестно, им считается число уже завершенных
+func (a *aggregator) setProgress(result *Result) {
+	result.Processed = a.processed
+	result.Total = a.total
+	if a.total == totalUnknown {
+		result.Total = a.processed + result.ErrorCount
+	}
+}
+
+// finish выполняет шаги, которым нужен полный набор результатов
+func (a *aggregator) finish() Result {
+	ctx, opts, result := a.ctx, a.opts, a.result
+	a.setProgress(&result)
+	// Результаты приходят в порядке готовности, а выводятся по индексам
+	slices.SortFunc(result.Polygons, func(x, y PolygonSummary) int { return cmp.Compare(x.Index, y.Index) })
+	slices.SortFunc(result.Errors, func(x, y PolygonError) int { return cmp.Compare(x.Index, y.Index) })
+	result.err = errors.Join(a.errs...)
+	if err := opts.checkBboxLimits(result.Bbox); err != nil {
+		fatalf(ctx, "Проверка bbox не пройдена: %v", err)
+	}
+	if opts.coverageGrid > 0 {
+		coverage := bboxCoverage(result.Bbox, a.localBboxes, opts.coverageGrid)
+		result.GlobalCoverage = &coverage
+	}
+	if a.percentiles != nil {
+		result.WeightPercentiles = a.percentiles.Result()
+	}
+	// Тепловая карта строится до обрезки, по исходным точкам
+	if opts.heatmapGrid > 0 {
+		result.Heatmap = buildHeatmap(result.Bbox, a.polygons, opts.heatmapGrid)
+	}
+	// Общий bbox известен только после агрегации, поэтому обрезка
+	// выполняется вторым проходом по уже отобранным тяжелым многоугольникам
+	if opts.clip {
+		rect := result.Bbox
+		if opts.clipRect != nil {
+			rect = *opts.clipRect
+		}
+		for _, heavy := range result.HeavyPolygons {
+			heavy.Polygon = ClipPolygonToBbox(heavy.Polygon, rect)
+		}
+	}
+	if opts.areaBudget > 0 {
+		result.HeavyPolygons = applyAreaBudget(result.HeavyPolygons, opts.areaBudget)
+	}
+	if opts.topN > 0 {
+		result.HeavyPolygons = heaviestN(result.HeavyPolygons, opts.topN)
+	}
+	if opts.sampleK > 0 {
+		result.HeavyPolygons = sampleWeighted(result.HeavyPolygons, opts.sampleK, opts.seed)
+	}
+	if opts.mergeRadius > 0 {
+		result.MergedPolygons = mergeByProximity(result.HeavyPolygons, opts.mergeRadius)
+	}
+	if opts.adjacency {
+		result.Adjacency = Adjacency(result.HeavyPolygons, opts.adjacencyTol)
+	}
+	if opts.overlaps {
+		result.Overlaps = BboxOverlaps(result.HeavyPolygons)
+	}
+	if opts.containsPoint != nil {
+		result.Containing = ContainingPolygons(result.HeavyPolygons, *opts.containsPoint)
+	}
+	// Сумма считается по итоговому списку, после всех отборов
+	for _, heavy := range result.HeavyPolygons {
+		result.TotalHeavyArea += heavy.Area
+	}
+	result.setBboxSize()
+	return result
+}
+
+// setBboxSize заполняет размеры общего bbox. Пока не учтен ни один
+// многоугольник, bbox остается пустым (с бесконечными границами, которые
+// нельзя вывести в JSON), поэтому он заменяется нулевым с пометкой empty
+func (result *Result) setBboxSize() {
+	b := result.Bbox
+	if b.X1 > b.X2 || b.Y1 > b.Y2 {
+		result.Bbox = Bbox{}
+		result.Width, result.Height, result.Area = 0, 0, 0
+		result.Empty = true
+		return
+	}
+	result.Width = b.X2 - b.X1
+	result.Height = b.Y2 - b.Y1
+	result.Area = result.Width * result.Height
+}
 
-	// Обработка случаев неполного завершения
-	// Улучшенная диагностика проблем с подробными сообщениями об ошибках
-	if processed < total {
-		if processingError != nil {
-			log.Fatalf("Не все многоугольники обработаны: %v", processingError)
+// partial возвращает накопленный к таймауту результат с пометкой partial.
+// Шаги finish выполняются над тем, что успело прийти
+func (a *aggregator) partial() Result {
+	result := a.finish()
+	result.Partial = true
+	return result
+}
+
+// fail завершает программу, если результаты закончились раньше, чем ожидалось.
+// Улучшенная диагностика проблем с подробными сообщениями об ошибках
+func (a *aggregator) fail() {
+	ctx := a.ctx
+	if a.processed < a.total {
+		if len(a.errs) > 0 {
+			fatalf(ctx, "Не все многоугольники обработаны: %v", errors.Join(a.errs...))
 		} else if ctx.Err() != nil {
-			log.Fatalf("Превышено время выполнения: %v", ctx.Err())
+			fatalf(ctx, "Превышено время выполнения: %v", ctx.Err())
 		} else {
-			log.Fatalf("Неизвестная ошибка: обработано только %d из %d многоугольников", processed, total)
+			fatalf(ctx, "Неизвестная ошибка: обработано только %d из %d многоугольников", a.processed, a.total)
 		}
 	}
-} 
\ No newline at end of file
+}
+
+// applyAreaBudget оставляет тяжелые многоугольники в порядке убывания площади,
+// пока их суммарная площадь не превышает бюджет. Первый не поместившийся
+// многоугольник завершает список, даже если следующие меньшие еще поместились бы
+func applyAreaBudget(heavies []*HeavyPolygon, budget float64) []*HeavyPolygon {
+	sorted := slices.Clone(heavies)
+	slices.SortStableFunc(sorted, func(a, b *HeavyPolygon) int {
+		return cmp.Compare(b.Area, a.Area)
+	})
+
+	total := 0.0
+	for i, heavy := range sorted {
+		total += heavy.Area
+		if total > budget {
+			return sorted[:i]
+		}
+	}
+	return sorted
+}
+
+// heaviestN оставляет n многоугольников с наибольшим суммарным весом
+// в порядке убывания веса. При равных весах первым идет меньший индекс,
+// чтобы вывод не зависел от порядка поступления результатов
+func heaviestN(heavies []*HeavyPolygon, n int) []*HeavyPolygon {
+	sorted := slices.Clone(heavies)
+	slices.SortFunc(sorted, func(a, b *HeavyPolygon) int {
+		if c := cmp.Compare(b.weight, a.weight); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Index, b.Index)
+	})
+	return sorted[:min(n, len(sorted))]
+}
+
+// add учитывает успешно обработанный многоугольник в агрегате.
+// Вызывается только из горутины collectResults, поэтому синхронизация не нужна
+func (result *Result) add(polygonResult PolygonResult, opts collectOptions) {
+	result.merge(polygonResult.localBbox, opts.importance(polygonResult))
+	result.addHeavy(polygonResult, opts)
+}
+
+// merge учитывает bbox и вес отдельного многоугольника или частичного агрегата
+func (result *Result) merge(bbox Bbox, weight float64) {
+	// Безопасное обновление общего bbox - только в одной горутине
+	result.Bbox = result.Bbox.Union(bbox)
+
+	// Безопасное обновление максимального веса по выбранной метрике
+	result.MaxWeight = MaxFloat(result.MaxWeight, weight)
+}
+
+// addHeavy добавляет многоугольник в список тяжелых, если он тяжелый
+func (result *Result) addHeavy(polygonResult PolygonResult, opts collectOptions) {
+	// Добавление тяжелых полигонов безопасно в одной горутине
+	if polygonResult.isHeavy && !opts.countOnly {
+		polygonResult.heavy.Index = polygonResult.index
+		polygonResult.heavy.bbox = polygonResult.localBbox
+		polygonResult.heavy.SizeClass = opts.sizeClasses.classify(polygonResult.heavy.Area)
+		if opts.prevBboxes != nil {
+			iou := 0.0
+			if prev, ok := opts.prevBboxes[polygonResult.index]; ok {
+				iou = BboxIoU(polygonResult.localBbox, prev)
+			}
+			polygonResult.heavy.BboxIoU = &iou
+		}
+		result.HeavyPolygons = append(result.HeavyPolygons, polygonResult.heavy)
+	}
+}
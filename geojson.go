@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Минимальное подмножество GeoJSON (RFC 7946), нужное для вывода результата
+type geoJSONCollection struct {
+	Type     string           `json:"type"`
+	Bbox     []float64        `json:"bbox,omitempty"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties geoJSONProps    `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string `json:"type"`
+	Coordinates any    `json:"coordinates"`
+}
+
+type geoJSONProps struct {
+	Index int `json:"index"`
+	// Суммарный вес многоугольника
+	Weight float64 `json:"weight"`
+	// Веса вершин в порядке обхода
+	PointWeights []float64 `json:"point_weights"`
+}
+
+// writeGeoJSON выводит тяжелые многоугольники как FeatureCollection.
+// Кольцо многоугольника замыкается повторением первой вершины. Фигуры
+// из одной или двух различных вершин выводятся как Point и LineString,
+// чтобы не получить некорректный Polygon, пустые пропускаются
+func writeGeoJSON(w io.Writer, result Result) error {
+	collection := geoJSONCollection{
+		Type:     "FeatureCollection",
+		Features: []geoJSONFeature{},
+	}
+	if len(result.HeavyPolygons) > 0 {
+		collection.Bbox = []float64{result.Bbox.X1, result.Bbox.Y1, result.Bbox.X2, result.Bbox.Y2}
+	}
+
+	for _, heavy := range result.HeavyPolygons {
+		if heavy.Polygon == nil || len(heavy.Points) == 0 {
+			continue
+		}
+		weights := make([]float64, len(heavy.Points))
+		for i, p := range heavy.Points {
+			weights[i] = p.Weight
+		}
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONGeometryOf(heavy),
+			Properties: geoJSONProps{
+				Index:        heavy.Index,
+				Weight:       heavy.weight,
+				PointWeights: weights,
+			},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(collection)
+}
+
+func geoJSONGeometryOf(heavy *HeavyPolygon) geoJSONGeometry {
+	ring := ringPoints(heavy.Points)
+	coords := make([][2]float64, 0, len(ring)+1)
+	for _, p := range ring {
+		coords = append(coords, [2]float64{p.X, p.Y})
+	}
+
+	switch {
+	case len(coords) == 1:
+		return geoJSONGeometry{Type: "Point", Coordinates: coords[0]}
+	case len(coords) == 2 || heavy.Type == shapeTypePolyline:
+		return geoJSONGeometry{Type: "LineString", Coordinates: coords}
+	}
+	coords = append(coords, coords[0])
+	return geoJSONGeometry{Type: "Polygon", Coordinates: [][][2]float64{coords}}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/kscvrmn/tev_test/polygon"
+)
+
+// Вывод GeoJSON разбирается обратно через encoding/json: проверяется
+// структура FeatureCollection, замыкание колец и вырожденные фигуры
+func TestWriteGeoJSONRoundTrip(t *testing.T) {
+	square := &HeavyPolygon{
+		Polygon: polygonOf([2]float64{0, 0}, [2]float64{4, 0}, [2]float64{4, 4}, [2]float64{0, 4}),
+		Index:   2,
+		weight:  104,
+	}
+	// Явно замкнутое кольцо не должно замыкаться повторно
+	closed := &HeavyPolygon{
+		Polygon: polygonOf([2]float64{5, 5}, [2]float64{6, 5}, [2]float64{6, 6}, [2]float64{5, 5}),
+		Index:   4,
+		weight:  150,
+	}
+	line := &HeavyPolygon{Polygon: polygonOf([2]float64{1, 1}, [2]float64{2, 2}), Index: 6, weight: 101}
+	point := &HeavyPolygon{Polygon: polygonOf([2]float64{3, 3}, [2]float64{3, 3}), Index: 8, weight: 120}
+	polyline := &HeavyPolygon{
+		Polygon: polygonOf([2]float64{0, 0}, [2]float64{1, 2}, [2]float64{2, 0}),
+		Index:   10,
+		Type:    shapeTypePolyline,
+		weight:  130,
+	}
+	empty := &HeavyPolygon{Polygon: &Polygon{}, Index: 12, weight: 200}
+	result := Result{
+		Bbox:          Bbox{X1: 0, Y1: 0, X2: 6, Y2: 6},
+		HeavyPolygons: []*HeavyPolygon{square, closed, line, point, polyline, empty},
+	}
+
+	var buf bytes.Buffer
+	if err := writeGeoJSON(&buf, result); err != nil {
+		t.Fatal(err)
+	}
+	var collection struct {
+		Type     string    `json:"type"`
+		Bbox     []float64 `json:"bbox"`
+		Features []struct {
+			Type     string `json:"type"`
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties geoJSONProps `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &collection); err != nil {
+		t.Fatalf("вывод не разбирается как JSON: %v\n%s", err, buf.Bytes())
+	}
+
+	if collection.Type != "FeatureCollection" {
+		t.Errorf("type = %q, ожидался FeatureCollection", collection.Type)
+	}
+	if want := []float64{0, 0, 6, 6}; !slices.Equal(collection.Bbox, want) {
+		t.Errorf("bbox = %v, ожидался %v", collection.Bbox, want)
+	}
+
+	tests := []struct {
+		index    int
+		geometry string
+		coords   string
+		weight   float64
+	}{
+		{2, "Polygon", "[[[0,0],[4,0],[4,4],[0,4],[0,0]]]", 104},
+		{4, "Polygon", "[[[5,5],[6,5],[6,6],[5,5]]]", 150},
+		{6, "LineString", "[[1,1],[2,2]]", 101},
+		{8, "Point", "[3,3]", 120},
+		{10, "LineString", "[[0,0],[1,2],[2,0]]", 130},
+	}
+	if len(collection.Features) != len(tests) {
+		t.Fatalf("объектов %d, ожидалось %d: пустой многоугольник должен пропускаться", len(collection.Features), len(tests))
+	}
+	for i, tt := range tests {
+		f := collection.Features[i]
+		if f.Type != "Feature" {
+			t.Errorf("объект %d: type = %q, ожидался Feature", i, f.Type)
+		}
+		if f.Properties.Index != tt.index || f.Properties.Weight != tt.weight {
+			t.Errorf("объект %d: index %d, weight %g, ожидались %d и %g", i, f.Properties.Index, f.Properties.Weight, tt.index, tt.weight)
+		}
+		if f.Geometry.Type != tt.geometry {
+			t.Errorf("многоугольник %d: геометрия %s, ожидалась %s", tt.index, f.Geometry.Type, tt.geometry)
+		}
+		var coords bytes.Buffer
+		if err := json.Compact(&coords, f.Geometry.Coordinates); err != nil {
+			t.Fatal(err)
+		}
+		if coords.String() != tt.coords {
+			t.Errorf("многоугольник %d: координаты %s, ожидались %s", tt.index, coords.String(), tt.coords)
+		}
+	}
+	if got := collection.Features[0].Properties.PointWeights; !slices.Equal(got, []float64{1, 1, 1, 1}) {
+		t.Errorf("веса вершин %v, ожидались единичные", got)
+	}
+}
+
+// Без тяжелых многоугольников выводится пустой, но корректный FeatureCollection
+func TestWriteGeoJSONEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeGeoJSON(&buf, Result{Bbox: polygon.EmptyBbox()}); err != nil {
+		t.Fatal(err)
+	}
+	var collection map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &collection); err != nil {
+		t.Fatalf("вывод не разбирается как JSON: %v", err)
+	}
+	if features, ok := collection["features"].([]any); !ok || len(features) != 0 {
+		t.Errorf("features = %v, ожидался пустой массив", collection["features"])
+	}
+	if _, ok := collection["bbox"]; ok {
+		t.Error("у пустого набора не должно быть bbox")
+	}
+}
@@ -0,0 +1,443 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/kscvrmn/tev_test/polygon"
+)
+
+// Геометрические функции над многоугольниками вынесены в отдельный файл,
+// чтобы не смешивать их с загрузкой и агрегацией результатов
+
+// IsConvex проверяет, что многоугольник выпуклый: знак векторного произведения
+// соседних ребер должен совпадать для всех вершин. Коллинеарные вершины и
+// повторяющиеся точки пропускаются, направление обхода значения не имеет.
+// Дополнительно считаем смены направления по осям - без этого самопересекающаяся
+// "звезда" с одинаковым знаком поворотов была бы признана выпуклой.
+func IsConvex(p *Polygon) bool {
+	pts := ringPoints(p.Points)
+	n := len(pts)
+	if n < 3 {
+		return false
+	}
+
+	sign := 0
+	var firstX, prevX, flipsX int
+	var firstY, prevY, flipsY int
+	for i := 0; i < n; i++ {
+		a, b, c := pts[i], pts[(i+1)%n], pts[(i+2)%n]
+		dx1, dy1 := b.X-a.X, b.Y-a.Y
+		dx2, dy2 := c.X-b.X, c.Y-b.Y
+
+		if s := sign64(dx1*dy2 - dy1*dx2); s != 0 {
+			if sign == 0 {
+				sign = s
+			} else if s != sign {
+				return false
+			}
+		}
+
+		countFlip(sign64(dx1), &firstX, &prevX, &flipsX)
+		countFlip(sign64(dy1), &firstY, &prevY, &flipsY)
+	}
+
+	// Все точки на одной прямой - многоугольник вырожденный
+	if sign == 0 {
+		return false
+	}
+
+	// Замыкаем цикл: сравниваем направление последнего ребра с первым
+	if prevX != firstX {
+		flipsX++
+	}
+	if prevY != firstY {
+		flipsY++
+	}
+	return flipsX <= 2 && flipsY <= 2
+}
+
+// ringPoints возвращает вершины многоугольника без подряд идущих дубликатов
+// и без явной замыкающей точки, совпадающей с первой
+func ringPoints(points []WeightedPoint) []Point {
+	pts := make([]Point, 0, len(points))
+	for _, p := range points {
+		if len(pts) > 0 && pts[len(pts)-1] == p.Point {
+			continue
+		}
+		pts = append(pts, p.Point)
+	}
+	for len(pts) > 1 && pts[len(pts)-1] == pts[0] {
+		pts = pts[:len(pts)-1]
+	}
+	return pts
+}
+
+// countFlip учитывает смену знака направления ребра по одной из осей.
+// Нулевые приращения (ребро параллельно другой оси) не меняют направление.
+func countFlip(s int, first, prev, flips *int) {
+	if s == 0 {
+		return
+	}
+	if *first == 0 {
+		*first = s
+	}
+	if *prev != 0 && *prev != s {
+		*flips++
+	}
+	*prev = s
+}
+
+func sign64(v float64) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// До этого числа вершин диаметр дешевле найти полным перебором пар,
+// чем строить выпуклую оболочку
+const diameterBruteForceLimit = 16
+
+// PolygonDiameter возвращает наибольшее расстояние между двумя вершинами.
+// Для больших многоугольников используется метод вращающихся калиперов
+// по выпуклой оболочке - O(n log n) вместо O(n^2) при полном переборе
+func PolygonDiameter(p *Polygon) float64 {
+	pts := ringPoints(p.Points)
+	if len(pts) <= diameterBruteForceLimit {
+		return bruteForceDiameter(pts)
+	}
+
+	hull := ConvexHull(pts)
+	n := len(hull)
+	if n < 3 {
+		return bruteForceDiameter(hull)
+	}
+
+	// Для каждого ребра оболочки сдвигаем антиподальную вершину j, пока
+	// площадь треугольника (ребро, j) растет, и проверяем найденные пары
+	var best float64
+	j := 1
+	for i := 0; i < n; i++ {
+		next := (i + 1) % n
+		for cross(hull[i], hull[next], hull[(j+1)%n]) > cross(hull[i], hull[next], hull[j]) {
+			j = (j + 1) % n
+		}
+		best = max(best, dist2(hull[i], hull[j]), dist2(hull[next], hull[j]))
+	}
+	return math.Sqrt(best)
+}
+
+func bruteForceDiameter(pts []Point) float64 {
+	var best float64
+	for i := range pts {
+		for j := i + 1; j < len(pts); j++ {
+			best = max(best, dist2(pts[i], pts[j]))
+		}
+	}
+	return math.Sqrt(best)
+}
+
+// MinimumWidth возвращает ширину многоугольника - наименьшее расстояние
+// между двумя параллельными опорными прямыми. Одна из прямых в оптимуме
+// проходит через ребро выпуклой оболочки, поэтому вращающимися калиперами
+// для каждого ребра находится самая удаленная вершина. Для вырожденных
+// многоугольников (меньше трех точек не на одной прямой) возвращается 0
+func MinimumWidth(p *Polygon) float64 {
+	hull := ConvexHull(ringPoints(p.Points))
+	n := len(hull)
+	if n < 3 {
+		return 0
+	}
+
+	best := math.Inf(1)
+	j := 1
+	for i := 0; i < n; i++ {
+		next := (i + 1) % n
+		for cross(hull[i], hull[next], hull[(j+1)%n]) > cross(hull[i], hull[next], hull[j]) {
+			j = (j + 1) % n
+		}
+		edge := math.Sqrt(dist2(hull[i], hull[next]))
+		best = min(best, cross(hull[i], hull[next], hull[j])/edge)
+	}
+	return best
+}
+
+// ConvexHull строит выпуклую оболочку точек алгоритмом Эндрю (монотонная цепочка).
+// Вершины возвращаются против часовой стрелки, коллинеарные точки на ребрах отбрасываются
+func ConvexHull(pts []Point) []Point {
+	sorted := slices.Clone(pts)
+	slices.SortFunc(sorted, func(a, b Point) int {
+		if a.X != b.X {
+			return cmp.Compare(a.X, b.X)
+		}
+		return cmp.Compare(a.Y, b.Y)
+	})
+	sorted = slices.Compact(sorted)
+	if len(sorted) < 3 {
+		return sorted
+	}
+
+	hull := make([]Point, 0, 2*len(sorted))
+	// Нижняя цепочка
+	for _, p := range sorted {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	// Верхняя цепочка
+	lower := len(hull) + 1
+	for i := len(sorted) - 2; i >= 0; i-- {
+		p := sorted[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	// Последняя точка совпадает с первой
+	return hull[:len(hull)-1]
+}
+
+// cross возвращает векторное произведение (b - a) x (c - a):
+// положительное при повороте против часовой стрелки
+func cross(a, b, c Point) float64 {
+	return (b.X-a.X)*(c.Y-a.Y) - (b.Y-a.Y)*(c.X-a.X)
+}
+
+// dist2 возвращает квадрат расстояния между точками
+func dist2(a, b Point) float64 {
+	dx, dy := a.X-b.X, a.Y-b.Y
+	return dx*dx + dy*dy
+}
+
+// Centroid возвращает центр масс многоугольника по формуле для площади
+// (а не среднее вершин, которое смещается к участкам с частыми точками).
+// Для вырожденных многоугольников с нулевой площадью возвращается среднее
+// арифметическое вершин, для пустых - начало координат
+func Centroid(p *Polygon) (float64, float64) {
+	n := len(p.Points)
+	if n == 0 {
+		return 0, 0
+	}
+
+	// Координаты отсчитываем от первой точки, чтобы уменьшить
+	// потерю точности при больших абсолютных значениях
+	origin := p.Points[0].Point
+	var area2, cx, cy float64
+	for i := 0; i < n; i++ {
+		a, b := p.Points[i].Point, p.Points[(i+1)%n].Point
+		ax, ay := a.X-origin.X, a.Y-origin.Y
+		bx, by := b.X-origin.X, b.Y-origin.Y
+		c := ax*by - bx*ay
+		area2 += c
+		cx += (ax + bx) * c
+		cy += (ay + by) * c
+	}
+
+	if area2 == 0 {
+		var sx, sy float64
+		for _, pt := range p.Points {
+			sx += pt.X
+			sy += pt.Y
+		}
+		return sx / float64(n), sy / float64(n)
+	}
+	return origin.X + cx/(3*area2), origin.Y + cy/(3*area2)
+}
+
+// Направление обхода вершин многоугольника в поле orientation
+const (
+	orientationCW         = "cw"
+	orientationCCW        = "ccw"
+	orientationDegenerate = "degenerate"
+)
+
+// Orientation определяет направление обхода по знаку площади (ось Y
+// направлена вверх). Многоугольник нулевой площади считается вырожденным
+func Orientation(p *Polygon) string {
+	switch area := p.SignedArea(); {
+	case area > 0:
+		return orientationCCW
+	case area < 0:
+		return orientationCW
+	}
+	return orientationDegenerate
+}
+
+// NormalizeWinding приводит обход к каноническому - против часовой стрелки.
+// Точки разворачиваются в копии: исходный срез может быть общим с другими
+// ссылками на многоугольник. Вырожденный многоугольник не меняется
+func NormalizeWinding(p *Polygon) *Polygon {
+	if p.SignedArea() >= 0 {
+		return p
+	}
+	points := slices.Clone(p.Points)
+	slices.Reverse(points)
+	return &Polygon{Points: points}
+}
+
+// SnapToGrid привязывает точки к узлам сетки с шагом grid и объединяет
+// совпавшие точки, суммируя их веса. Порядок точек определяется первым
+// вхождением узла. Шаг 1 округляет координаты до целых. При grid <= 0
+// точки возвращаются без изменений, иначе возвращается новый срез
+func SnapToGrid(points []WeightedPoint, grid int) []WeightedPoint {
+	if grid <= 0 {
+		return points
+	}
+
+	g := float64(grid)
+	snapped := make([]WeightedPoint, 0, len(points))
+	positions := make(map[Point]int, len(points))
+	for _, p := range points {
+		cell := Point{
+			X: math.Round(p.X/g) * g,
+			Y: math.Round(p.Y/g) * g,
+		}
+		if i, ok := positions[cell]; ok {
+			snapped[i].Weight += p.Weight
+			continue
+		}
+		positions[cell] = len(snapped)
+		snapped = append(snapped, WeightedPoint{Point: cell, Weight: p.Weight})
+	}
+	return snapped
+}
+
+// TurningAngles возвращает знаковый внешний угол поворота в градусах в каждой
+// вершине с учетом замыкающего ребра. Положительные углы - поворот против
+// часовой стрелки, отрицательные - по часовой (рефлексные вершины при обходе
+// против часовой). В коллинеарных вершинах угол равен 0. Повторяющиеся точки
+// пропускаются, так как у ребра нулевой длины нет направления
+func TurningAngles(p *Polygon) []float64 {
+	pts := ringPoints(p.Points)
+	n := len(pts)
+	if n < 3 {
+		return nil
+	}
+
+	angles := make([]float64, n)
+	for i := 0; i < n; i++ {
+		prev, cur, next := pts[(i+n-1)%n], pts[i], pts[(i+1)%n]
+		ux, uy := cur.X-prev.X, cur.Y-prev.Y
+		vx, vy := next.X-cur.X, next.Y-cur.Y
+		angles[i] = math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy) * 180 / math.Pi
+	}
+	return angles
+}
+
+// WeightGradient возвращает скорость изменения веса вдоль границы:
+// (w[i+1]-w[i]) / длина ребра для каждого ребра, включая замыкающее
+// от последней точки к первой. У ребра нулевой длины градиент 0
+func WeightGradient(p *Polygon) []float64 {
+	n := len(p.Points)
+	if n < 2 {
+		return nil
+	}
+
+	gradient := make([]float64, n)
+	for i := 0; i < n; i++ {
+		a, b := p.Points[i], p.Points[(i+1)%n]
+		if length := polygon.SegmentLength(a, b); length > 0 {
+			gradient[i] = (b.Weight - a.Weight) / length
+		}
+	}
+	return gradient
+}
+
+// ConfidenceBbox строит bbox только по точкам, вес которых лежит между
+// перцентилями low и high (в процентах) весов многоугольника. Веса здесь
+// трактуются как уверенность детекции, а точки с весом вне полосы - как выбросы.
+// Если все веса равны, в полосу попадают все точки
+func ConfidenceBbox(points []WeightedPoint, low, high float64) Bbox {
+	if len(points) == 0 {
+		return Bbox{}
+	}
+
+	weights := make([]float64, len(points))
+	for i, p := range points {
+		weights[i] = p.Weight
+	}
+	slices.Sort(weights)
+	lo, hi := nearestRank(weights, low), nearestRank(weights, high)
+
+	bbox := polygon.EmptyBbox()
+	for _, p := range points {
+		if p.Weight < lo || p.Weight > hi {
+			continue
+		}
+		bbox.X1 = MinFloat(bbox.X1, p.X)
+		bbox.Y1 = MinFloat(bbox.Y1, p.Y)
+		bbox.X2 = MaxFloat(bbox.X2, p.X)
+		bbox.Y2 = MaxFloat(bbox.Y2, p.Y)
+	}
+	return bbox
+}
+
+// nearestRank возвращает перцентиль p (в процентах) отсортированных значений
+// методом ближайшего ранга. Результат всегда один из элементов среза,
+// поэтому полоса [lo, hi] никогда не оказывается пустой
+func nearestRank(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	rank = min(max(rank, 1), len(sorted))
+	return sorted[rank-1]
+}
+
+// Допустимые значения -closure
+const (
+	closureImplicit = "implicit"
+	closureExplicit = "explicit"
+)
+
+// Допустимые значения -dedup_weight
+const (
+	dedupWeightFirst = "first"
+	dedupWeightSum   = "sum"
+)
+
+// DedupPoints схлопывает подряд идущие точки с одинаковыми координатами
+// в одну. Ее вес - вес первой точки серии (first) или сумма весов серии (sum),
+// поэтому в режиме first суммарный вес многоугольника может уменьшиться.
+// Возвращается новый срез, исходный не изменяется
+func DedupPoints(points []WeightedPoint, mode string) []WeightedPoint {
+	deduped := make([]WeightedPoint, 0, len(points))
+	for _, p := range points {
+		if n := len(deduped); n > 0 && deduped[n-1].Point == p.Point {
+			if mode == dedupWeightSum {
+				deduped[n-1].Weight += p.Weight
+			}
+			continue
+		}
+		deduped = append(deduped, p)
+	}
+	return deduped
+}
+
+// ApplyClosure приводит кольцо к выбранному способу замыкания.
+// В явном режиме в конец добавляется копия первой точки с нулевым весом,
+// в неявном повторяющая первую последняя точка удаляется, а ее вес переносится
+// на первую. Суммарный вес, площадь и периметр от режима не зависят.
+// Пустой mode оставляет точки как есть
+func ApplyClosure(points []WeightedPoint, mode string) []WeightedPoint {
+	if len(points) < 2 {
+		return points
+	}
+	first, last := points[0], points[len(points)-1]
+	closed := first.Point == last.Point
+
+	switch {
+	case mode == closureExplicit && !closed:
+		out := make([]WeightedPoint, len(points), len(points)+1)
+		copy(out, points)
+		return append(out, WeightedPoint{Point: first.Point})
+	case mode == closureImplicit && closed:
+		out := slices.Clone(points[:len(points)-1])
+		out[0].Weight += last.Weight
+		return out
+	}
+	return points
+}
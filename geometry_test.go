@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"github.com/kscvrmn/tev_test/polygon"
+)
+
+// polygonOf строит многоугольник с единичными весами из пар координат
+func polygonOf(coords ...[2]float64) *Polygon {
+	points := make([]WeightedPoint, len(coords))
+	for i, c := range coords {
+		points[i] = WeightedPoint{Point: Point{X: c[0], Y: c[1]}, Weight: 1}
+	}
+	return &Polygon{Points: points}
+}
+
+// reversed возвращает многоугольник с обратным порядком обхода
+func reversed(p *Polygon) *Polygon {
+	points := slices.Clone(p.Points)
+	slices.Reverse(points)
+	return &Polygon{Points: points}
+}
+
+func TestIsConvex(t *testing.T) {
+	pentagon := polygonOf([2]float64{0, 0}, [2]float64{4, 0}, [2]float64{5, 3}, [2]float64{2, 5}, [2]float64{-1, 3})
+	// Стрелка вправо: выемка у хвоста дает вершину с обратным поворотом
+	arrow := polygonOf([2]float64{0, 0}, [2]float64{3, 2}, [2]float64{6, 0}, [2]float64{3, 6})
+
+	tests := []struct {
+		name string
+		p    *Polygon
+		want bool
+	}{
+		{"выпуклый пятиугольник", pentagon, true},
+		{"пятиугольник по часовой", reversed(pentagon), true},
+		{"пятиугольник с явным замыканием", polygonOf([2]float64{0, 0}, [2]float64{4, 0}, [2]float64{5, 3}, [2]float64{2, 5}, [2]float64{-1, 3}, [2]float64{0, 0}), true},
+		{"коллинеарная точка на ребре", polygonOf([2]float64{0, 0}, [2]float64{2, 0}, [2]float64{4, 0}, [2]float64{4, 4}, [2]float64{0, 4}), true},
+		{"повторяющиеся точки", polygonOf([2]float64{0, 0}, [2]float64{4, 0}, [2]float64{4, 0}, [2]float64{4, 4}, [2]float64{0, 4}), true},
+		{"вогнутая стрелка", arrow, false},
+		{"стрелка по часовой", reversed(arrow), false},
+		{"самопересекающаяся звезда", polygonOf([2]float64{0, 0}, [2]float64{2, 6}, [2]float64{4, 0}, [2]float64{-1, 4}, [2]float64{5, 4}), false},
+		{"все точки на прямой", polygonOf([2]float64{0, 0}, [2]float64{1, 1}, [2]float64{2, 2}), false},
+		{"две точки", polygonOf([2]float64{0, 0}, [2]float64{1, 1}), false},
+		{"пустой", &Polygon{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsConvex(tt.p); got != tt.want {
+				t.Errorf("IsConvex = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// regularPolygon - правильный n-угольник с центром в начале координат
+func regularPolygon(n int, radius float64) *Polygon {
+	p := &Polygon{}
+	for i := range n {
+		angle := 2 * math.Pi * float64(i) / float64(n)
+		p.Points = append(p.Points, wp(radius*math.Cos(angle), radius*math.Sin(angle), 1))
+	}
+	return p
+}
+
+// randomPolygon - n случайных точек в квадрате 100 x 100
+func randomPolygon(rng *rand.Rand, n int) *Polygon {
+	p := &Polygon{}
+	for range n {
+		p.Points = append(p.Points, wp(rng.Float64()*100, rng.Float64()*100, 1))
+	}
+	return p
+}
+
+func TestPolygonDiameter(t *testing.T) {
+	tests := []struct {
+		name string
+		p    *Polygon
+		want float64
+	}{
+		{"пустой", &Polygon{}, 0},
+		{"одна точка", polygonOf([2]float64{3, 4}), 0},
+		{"прямоугольник 3x4", polygonOf([2]float64{0, 0}, [2]float64{3, 0}, [2]float64{3, 4}, [2]float64{0, 4}), 5},
+		// Больше diameterBruteForceLimit вершин: считается по выпуклой оболочке
+		{"правильный 40-угольник", regularPolygon(40, 5), 10},
+		{"точки на прямой", polygonOf([2]float64{0, 0}, [2]float64{1, 1}, [2]float64{2, 2}, [2]float64{3, 3}), 3 * math.Sqrt2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PolygonDiameter(tt.p); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("диаметр %g, ожидалось %g", got, tt.want)
+			}
+		})
+	}
+}
+
+// Вращающиеся калиперы дают тот же диаметр, что и полный перебор пар
+func TestPolygonDiameterMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, n := range []int{17, 50, 200, 1000} {
+		p := randomPolygon(rng, n)
+		want := bruteForceDiameter(ringPoints(p.Points))
+		if got := PolygonDiameter(p); math.Abs(got-want) > 1e-9 {
+			t.Errorf("%d точек: диаметр %g, полный перебор %g", n, got, want)
+		}
+	}
+}
+
+// wp - точка с весом для таблиц тестов
+func wp(x, y, weight float64) WeightedPoint {
+	return WeightedPoint{Point: Point{X: x, Y: y}, Weight: weight}
+}
+
+func TestDedupPoints(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []WeightedPoint
+		first  []WeightedPoint
+		sum    []WeightedPoint
+	}{
+		{"пустой", nil, []WeightedPoint{}, []WeightedPoint{}},
+		{"без дубликатов", []WeightedPoint{wp(0, 0, 1), wp(1, 0, 2), wp(1, 1, 3)},
+			[]WeightedPoint{wp(0, 0, 1), wp(1, 0, 2), wp(1, 1, 3)},
+			[]WeightedPoint{wp(0, 0, 1), wp(1, 0, 2), wp(1, 1, 3)}},
+		{"серии подряд", []WeightedPoint{wp(0, 0, 1), wp(0, 0, 2), wp(0, 0, 3), wp(4, 0, 1), wp(4, 4, 5), wp(4, 4, 5)},
+			[]WeightedPoint{wp(0, 0, 1), wp(4, 0, 1), wp(4, 4, 5)},
+			[]WeightedPoint{wp(0, 0, 6), wp(4, 0, 1), wp(4, 4, 10)}},
+		// Совпадает только с соседями: несмежные повторы - разные вершины
+		{"несмежный повтор", []WeightedPoint{wp(0, 0, 1), wp(1, 0, 1), wp(0, 0, 1)},
+			[]WeightedPoint{wp(0, 0, 1), wp(1, 0, 1), wp(0, 0, 1)},
+			[]WeightedPoint{wp(0, 0, 1), wp(1, 0, 1), wp(0, 0, 1)}},
+		// Замыкающая точка кольца не считается дубликатом первой,
+		// ее приводит к нужному виду -closure
+		{"первая и последняя совпадают", []WeightedPoint{wp(0, 0, 1), wp(2, 0, 1), wp(2, 2, 1), wp(0, 0, 4)},
+			[]WeightedPoint{wp(0, 0, 1), wp(2, 0, 1), wp(2, 2, 1), wp(0, 0, 4)},
+			[]WeightedPoint{wp(0, 0, 1), wp(2, 0, 1), wp(2, 2, 1), wp(0, 0, 4)}},
+		{"замыкание с серией в конце", []WeightedPoint{wp(0, 0, 1), wp(2, 0, 1), wp(2, 2, 1), wp(0, 0, 2), wp(0, 0, 3)},
+			[]WeightedPoint{wp(0, 0, 1), wp(2, 0, 1), wp(2, 2, 1), wp(0, 0, 2)},
+			[]WeightedPoint{wp(0, 0, 1), wp(2, 0, 1), wp(2, 2, 1), wp(0, 0, 5)}},
+		{"все точки одинаковые", []WeightedPoint{wp(3, 3, 1), wp(3, 3, 2), wp(3, 3, 4)},
+			[]WeightedPoint{wp(3, 3, 1)},
+			[]WeightedPoint{wp(3, 3, 7)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := slices.Clone(tt.points)
+			if got := DedupPoints(tt.points, dedupWeightFirst); !slices.Equal(got, tt.first) {
+				t.Errorf("first: %v, ожидалось %v", got, tt.first)
+			}
+			if got := DedupPoints(tt.points, dedupWeightSum); !slices.Equal(got, tt.sum) {
+				t.Errorf("sum: %v, ожидалось %v", got, tt.sum)
+			}
+			if !slices.Equal(tt.points, original) {
+				t.Errorf("исходные точки изменены: %v", tt.points)
+			}
+		})
+	}
+}
+
+// С -dedup_points вес, bbox и число точек многоугольника отражают
+// выбранное правило веса согласованно
+func TestProcessPolygonDedup(t *testing.T) {
+	points := []WeightedPoint{wp(0, 0, 50), wp(0, 0, 50), wp(4, 0, 10), wp(4, 4, 10), wp(4, 4, 10), wp(0, 4, 10)}
+	tests := []struct {
+		name   string
+		opts   processOptions
+		weight float64
+		count  int
+		heavy  bool
+	}{
+		{"без схлопывания", processOptions{}, 140, 6, true},
+		{"first", processOptions{dedupPoints: true, dedupWeight: dedupWeightFirst}, 80, 4, false},
+		{"sum", processOptions{dedupPoints: true, dedupWeight: dedupWeightSum}, 140, 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.heavy = polygon.WeightAtLeast(100)
+			r := processPolygon(&Polygon{Points: slices.Clone(points)}, context.Background(), tt.opts)
+			if r.err != nil {
+				t.Fatal(r.err)
+			}
+			if r.weight != tt.weight || r.pointCount != tt.count || r.isHeavy != tt.heavy {
+				t.Errorf("вес %g, точек %d, тяжелый %v, ожидалось %g, %d, %v", r.weight, r.pointCount, r.isHeavy, tt.weight, tt.count, tt.heavy)
+			}
+			if want := (Bbox{X1: 0, Y1: 0, X2: 4, Y2: 4}); r.localBbox != want || r.area != 16 {
+				t.Errorf("bbox %+v, площадь %g", r.localBbox, r.area)
+			}
+			if tt.heavy && len(r.heavy.Points) != tt.count {
+				t.Errorf("у тяжелого многоугольника %d точек, ожидалось %d", len(r.heavy.Points), tt.count)
+			}
+		})
+	}
+}
+
+func TestSnapToGrid(t *testing.T) {
+	points := []WeightedPoint{wp(0.2, 0.4, 1), wp(0.6, -0.3, 2), wp(1.4, 0.1, 3), wp(2.6, 2.5, 4), wp(4.9, 5.2, 5)}
+	tests := []struct {
+		name string
+		grid int
+		want []WeightedPoint
+	}{
+		{"без привязки", 0, points},
+		// Шаг 1 округляет дробные координаты до целых
+		{"шаг 1", 1, []WeightedPoint{wp(0, 0, 1), wp(1, 0, 5), wp(3, 3, 4), wp(5, 5, 5)}},
+		{"шаг 5", 5, []WeightedPoint{wp(0, 0, 6), wp(5, 5, 9)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := slices.Clone(points)
+			if got := SnapToGrid(points, tt.grid); !slices.Equal(got, tt.want) {
+				t.Errorf("SnapToGrid = %v, ожидалось %v", got, tt.want)
+			}
+			if !slices.Equal(points, original) {
+				t.Errorf("исходные точки изменены: %v", points)
+			}
+		})
+	}
+}
+
+// -snap_grid выполняется до расчета характеристик: вес сохраняется,
+// а число точек и bbox считаются по узлам сетки
+func TestProcessPolygonSnapGrid(t *testing.T) {
+	p := &Polygon{Points: []WeightedPoint{wp(0.2, 0.1, 30), wp(-0.3, 0.4, 30), wp(9.8, 0.2, 20), wp(10.1, 9.7, 20), wp(0.4, 10.3, 20)}}
+	r := processed(t, 0, p, processOptions{snapGrid: 1})
+	if r.weight != 120 || r.pointCount != 4 || !r.isHeavy {
+		t.Errorf("вес %g, точек %d, тяжелый %v, ожидалось 120, 4, true", r.weight, r.pointCount, r.isHeavy)
+	}
+	if want := (Bbox{X1: 0, Y1: 0, X2: 10, Y2: 10}); r.localBbox != want || r.area != 100 {
+		t.Errorf("bbox %+v, площадь %g, ожидалось %+v и 100", r.localBbox, r.area, want)
+	}
+}
+
+func TestTurningAngles(t *testing.T) {
+	square := polygonOf([2]float64{0, 0}, [2]float64{2, 0}, [2]float64{2, 2}, [2]float64{0, 2})
+	lShape := polygonOf([2]float64{0, 0}, [2]float64{2, 0}, [2]float64{2, 1}, [2]float64{1, 1}, [2]float64{1, 2}, [2]float64{0, 2})
+	tests := []struct {
+		name string
+		p    *Polygon
+		want []float64
+	}{
+		{"квадрат против часовой", square, []float64{90, 90, 90, 90}},
+		{"квадрат по часовой", reversed(square), []float64{-90, -90, -90, -90}},
+		// Во вдавленной вершине (1, 1) поворот в обратную сторону
+		{"L-образный", lShape, []float64{90, 90, 90, -90, 90, 90}},
+		{"коллинеарная вершина", polygonOf([2]float64{0, 0}, [2]float64{1, 0}, [2]float64{2, 0}, [2]float64{2, 2}, [2]float64{0, 2}), []float64{90, 0, 90, 90, 90}},
+		// Повторы и замыкающая точка не дают вершин с нулевым ребром
+		{"повторы и замыкание", polygonOf([2]float64{0, 0}, [2]float64{2, 0}, [2]float64{2, 0}, [2]float64{2, 2}, [2]float64{0, 2}, [2]float64{0, 0}), []float64{90, 90, 90, 90}},
+		{"вырожденный", polygonOf([2]float64{0, 0}, [2]float64{1, 1}), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TurningAngles(tt.p)
+			if !slices.EqualFunc(got, tt.want, func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }) {
+				t.Errorf("TurningAngles = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Точки с весом вне полосы перцентилей не расширяют bbox
+func TestConfidenceBbox(t *testing.T) {
+	var points []WeightedPoint
+	for i := range 18 {
+		points = append(points, wp(float64(i%3*5), float64(i/3*2), 5))
+	}
+	// Выбросы: почти нулевая уверенность слева и аномально высокая сверху
+	points = append(points, wp(-100, 0, 0.1), wp(0, 100, 100))
+	inliers := Bbox{X1: 0, Y1: 0, X2: 10, Y2: 10}
+
+	tests := []struct {
+		name      string
+		points    []WeightedPoint
+		low, high float64
+		want      Bbox
+	}{
+		{"без выбросов", points, 10, 90, inliers},
+		// 5-й перцентиль из 20 значений - это первое, то есть выброс 0.1
+		{"нижний выброс в полосе", points, 5, 95, Bbox{X1: -100, Y1: 0, X2: 10, Y2: 10}},
+		{"вся полоса", points, 0, 100, Bbox{X1: -100, Y1: 0, X2: 10, Y2: 100}},
+		{"равные веса", points[:18], 10, 90, inliers},
+		{"пусто", nil, 5, 95, Bbox{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ConfidenceBbox(tt.points, tt.low, tt.high); got != tt.want {
+				t.Errorf("ConfidenceBbox = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyClosure(t *testing.T) {
+	open := []WeightedPoint{wp(0, 0, 1), wp(2, 0, 2), wp(2, 2, 3)}
+	closed := []WeightedPoint{wp(0, 0, 1), wp(2, 0, 2), wp(2, 2, 3), wp(0, 0, 4)}
+	tests := []struct {
+		name   string
+		points []WeightedPoint
+		mode   string
+		want   []WeightedPoint
+	}{
+		{"явное для открытого", open, closureExplicit, []WeightedPoint{wp(0, 0, 1), wp(2, 0, 2), wp(2, 2, 3), wp(0, 0, 0)}},
+		{"явное для замкнутого", closed, closureExplicit, closed},
+		// Вес замыкающей точки переносится на первую
+		{"неявное для замкнутого", closed, closureImplicit, []WeightedPoint{wp(0, 0, 5), wp(2, 0, 2), wp(2, 2, 3)}},
+		{"неявное для открытого", open, closureImplicit, open},
+		{"без режима", closed, "", closed},
+		{"одна точка", open[:1], closureExplicit, open[:1]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := slices.Clone(tt.points)
+			if got := ApplyClosure(tt.points, tt.mode); !slices.Equal(got, tt.want) {
+				t.Errorf("ApplyClosure = %v, ожидалось %v", got, tt.want)
+			}
+			if !slices.Equal(tt.points, original) {
+				t.Errorf("исходные точки изменены: %v", tt.points)
+			}
+		})
+	}
+}
+
+// Суммарный вес, площадь и периметр от способа замыкания не зависят
+func TestProcessPolygonClosure(t *testing.T) {
+	points := []WeightedPoint{wp(0, 0, 40), wp(4, 0, 30), wp(4, 3, 30), wp(0, 0, 20)}
+	want := processed(t, 0, &Polygon{Points: slices.Clone(points)}, processOptions{})
+	tests := []struct {
+		mode  string
+		count int
+	}{
+		{closureExplicit, 4},
+		{closureImplicit, 3},
+	}
+	for _, tt := range tests {
+		r := processed(t, 0, &Polygon{Points: slices.Clone(points)}, processOptions{closure: tt.mode})
+		if r.pointCount != tt.count {
+			t.Errorf("%s: точек %d, ожидалось %d", tt.mode, r.pointCount, tt.count)
+		}
+		if r.weight != want.weight || r.area != want.area || r.localBbox != want.localBbox || r.heavy.Perimeter() != want.heavy.Perimeter() {
+			t.Errorf("%s: вес %g, площадь %g, периметр %g, ожидалось %g, %g, %g", tt.mode, r.weight, r.area, r.heavy.Perimeter(), want.weight, want.area, want.heavy.Perimeter())
+		}
+	}
+}
+
+func TestWeightGradient(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []WeightedPoint
+		want   []float64
+	}{
+		// Замыкающее ребро от (0, 4) к (0, 0) длиной 4: (1 - 13) / 4
+		{"треугольник", []WeightedPoint{wp(0, 0, 1), wp(3, 0, 7), wp(0, 4, 13)}, []float64{2, 1.2, -3}},
+		{"повтор точки", []WeightedPoint{wp(0, 0, 1), wp(0, 0, 5), wp(2, 0, 9)}, []float64{0, 2, -4}},
+		{"одна точка", []WeightedPoint{wp(1, 1, 1)}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WeightGradient(&Polygon{Points: tt.points})
+			if !slices.EqualFunc(got, tt.want, func(a, b float64) bool { return math.Abs(a-b) < 1e-12 }) {
+				t.Errorf("WeightGradient = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinimumWidth(t *testing.T) {
+	rotated := &Polygon{}
+	sin, cos := math.Sincos(math.Pi / 6)
+	for _, c := range [][2]float64{{0, 0}, {10, 0}, {10, 3}, {0, 3}} {
+		rotated.Points = append(rotated.Points, wp(c[0]*cos-c[1]*sin, c[0]*sin+c[1]*cos, 1))
+	}
+	tests := []struct {
+		name string
+		p    *Polygon
+		want float64
+	}{
+		{"прямоугольник", polygonOf([2]float64{0, 0}, [2]float64{10, 0}, [2]float64{10, 3}, [2]float64{0, 3}), 3},
+		{"повернутый прямоугольник", rotated, 3},
+		{"по часовой", reversed(rotated), 3},
+		// Высота к гипотенузе: 3 * 4 / 5
+		{"прямоугольный треугольник", polygonOf([2]float64{0, 0}, [2]float64{4, 0}, [2]float64{0, 3}), 2.4},
+		{"шестиугольник", regularPolygon(6, 1), math.Sqrt(3)},
+		// Ширина невыпуклого многоугольника - ширина его оболочки: от срезанного
+		// угла x + y = 5 до вершины (0, 0)
+		{"L-образный", polygonOf([2]float64{0, 0}, [2]float64{4, 0}, [2]float64{4, 1}, [2]float64{1, 1}, [2]float64{1, 4}, [2]float64{0, 4}), 5 / math.Sqrt2},
+		{"отрезок", polygonOf([2]float64{0, 0}, [2]float64{1, 1}, [2]float64{2, 2}), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MinimumWidth(tt.p); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("MinimumWidth = %g, ожидалось %g", got, tt.want)
+			}
+		})
+	}
+}
+
+// Калиперы дают ту же ширину, что и перебор всех вершин для каждого ребра оболочки
+func TestMinimumWidthMatchesBruteForce(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	for _, n := range []int{5, 17, 200} {
+		p := randomPolygon(rng, n)
+		hull := ConvexHull(ringPoints(p.Points))
+		want := math.Inf(1)
+		for i := range hull {
+			a, b := hull[i], hull[(i+1)%len(hull)]
+			farthest := 0.0
+			for _, c := range hull {
+				farthest = max(farthest, math.Abs(cross(a, b, c))/math.Sqrt(dist2(a, b)))
+			}
+			want = min(want, farthest)
+		}
+		if got := MinimumWidth(p); math.Abs(got-want) > 1e-9 {
+			t.Errorf("%d точек: ширина %g, перебор %g", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/kscvrmn/tev_test
+
+go 1.22
+
+require (
+	github.com/gorilla/websocket v1.5.3
+	github.com/vmihailenco/msgpack/v5 v5.4.1
+	go.uber.org/goleak v1.3.0
+	golang.org/x/time v0.5.0
+)
+
+require github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
@@ -0,0 +1,76 @@
+package main
+
+// Максимальное разрешение тепловых карт: сетки выводятся целиком в JSON,
+// локальная - для каждого тяжелого многоугольника
+const (
+	maxHeatmapGrid      = 1024
+	maxLocalHeatmapGrid = 64
+)
+
+// Тепловая карта весов поверх общего bbox. Cells[j][i] - сумма весов точек,
+// попавших в ячейку i по X и j по Y
+type Heatmap struct {
+	Bbox  Bbox        `json:"bbox"`
+	Size  int         `json:"size"`
+	Cells [][]float64 `json:"cells"`
+}
+
+// buildHeatmap раскладывает веса всех точек по сетке n x n поверх общего bbox.
+// Общий bbox известен только после агрегации, поэтому это второй проход
+// по сохраненным многоугольникам. Точки вне bbox (например, отброшенные
+// -confidence_bbox) не учитываются, точки на правой и верхней границах
+// попадают в крайние ячейки
+func buildHeatmap(global Bbox, polygons []*Polygon, n int) *Heatmap {
+	cells := make([][]float64, n)
+	for j := range cells {
+		cells[j] = make([]float64, n)
+	}
+	heatmap := &Heatmap{Bbox: global, Size: n, Cells: cells}
+	if global.X1 > global.X2 || global.Y1 > global.Y2 {
+		return heatmap
+	}
+
+	for _, poly := range polygons {
+		for _, p := range poly.Points {
+			if p.X < global.X1 || p.X > global.X2 || p.Y < global.Y1 || p.Y > global.Y2 {
+				continue
+			}
+			i := heatmapCell(p.X, global.X1, global.X2, n)
+			j := heatmapCell(p.Y, global.Y1, global.Y2, n)
+			cells[j][i] += p.Weight
+		}
+	}
+	return heatmap
+}
+
+// heatmapCell возвращает номер ячейки для координаты v в отрезке [lo, hi]
+func heatmapCell(v, lo, hi float64, n int) int {
+	if hi == lo {
+		return 0
+	}
+	return min(int((v-lo)/(hi-lo)*float64(n)), n-1)
+}
+
+// LocalHeatmap раскладывает веса точек многоугольника по сетке n x n поверх
+// его собственного bbox. Для вырожденного bbox (все точки на одной вертикали
+// или горизонтали) соответствующая ось целиком попадает в первую ячейку
+func LocalHeatmap(p *Polygon, n int) [][]float64 {
+	if n <= 0 {
+		return nil
+	}
+	cells := make([][]float64, n)
+	for j := range cells {
+		cells[j] = make([]float64, n)
+	}
+	if len(p.Points) == 0 {
+		return cells
+	}
+
+	local := pointsBbox(p.Points)
+	for _, pt := range p.Points {
+		i := heatmapCell(pt.X, local.X1, local.X2, n)
+		j := heatmapCell(pt.Y, local.Y1, local.Y2, n)
+		cells[j][i] += pt.Weight
+	}
+	return cells
+}
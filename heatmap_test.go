@@ -0,0 +1,79 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/kscvrmn/tev_test/polygon"
+)
+
+func TestBuildHeatmap(t *testing.T) {
+	global := Bbox{X1: 0, Y1: 0, X2: 4, Y2: 4}
+	p := &Polygon{Points: []WeightedPoint{
+		wp(0, 0, 1), wp(1.9, 0.5, 2),
+		// Правая и верхняя границы попадают в крайние ячейки
+		wp(4, 4, 4), wp(4, 0, 8),
+		wp(2, 2, 16),
+		// Вне общего bbox
+		wp(-1, 2, 100),
+	}}
+	heatmap := buildHeatmap(global, []*Polygon{p}, 2)
+	want := [][]float64{{3, 8}, {0, 20}}
+	if heatmap.Size != 2 || heatmap.Bbox != global || !slices.EqualFunc(heatmap.Cells, want, slices.Equal) {
+		t.Errorf("тепловая карта %+v, ожидались ячейки %v", heatmap, want)
+	}
+
+	// Без точек общий bbox пуст, а сетка все равно выводится целиком
+	empty := buildHeatmap(polygon.EmptyBbox(), nil, 3)
+	if len(empty.Cells) != 3 || len(empty.Cells[2]) != 3 {
+		t.Errorf("пустая тепловая карта %+v", empty)
+	}
+}
+
+// С -heatmap_grid сборщик сохраняет точки всех многоугольников, а не только
+// тяжелых
+func TestCollectHeatmap(t *testing.T) {
+	result := collect(t, collectOptions{heatmapGrid: 4},
+		processed(t, 0, square(0, 0, 1, 150), processOptions{}),
+		processed(t, 1, square(3, 3, 1, 8), processOptions{}),
+	)
+	if result.Heatmap == nil {
+		t.Fatal("тепловая карта не построена")
+	}
+	if want := (Bbox{X1: 0, Y1: 0, X2: 4, Y2: 4}); result.Heatmap.Bbox != want {
+		t.Errorf("bbox тепловой карты %+v, ожидался %+v", result.Heatmap.Bbox, want)
+	}
+	// Вершины квадрата со стороной 1 попадают в четыре соседние ячейки
+	want := [][]float64{{37.5, 37.5, 0, 0}, {37.5, 37.5, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 8}}
+	if !slices.EqualFunc(result.Heatmap.Cells, want, slices.Equal) {
+		t.Errorf("ячейки %v, ожидались %v", result.Heatmap.Cells, want)
+	}
+}
+
+// Локальная тепловая карта строится по собственному bbox многоугольника
+func TestLocalHeatmap(t *testing.T) {
+	p := &Polygon{Points: []WeightedPoint{wp(10, 10, 1), wp(14, 10, 2), wp(14, 14, 4), wp(10, 14, 8), wp(11, 11, 16)}}
+	want := [][]float64{{17, 2}, {8, 4}}
+	if got := LocalHeatmap(p, 2); !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("LocalHeatmap = %v, ожидалось %v", got, want)
+	}
+
+	// Вырожденный bbox: все точки на одной вертикали попадают в первый столбец
+	line := &Polygon{Points: []WeightedPoint{wp(5, 0, 1), wp(5, 2, 2), wp(5, 4, 4)}}
+	if got, want := LocalHeatmap(line, 2), [][]float64{{1, 0}, {6, 0}}; !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("для вертикали LocalHeatmap = %v, ожидалось %v", got, want)
+	}
+
+	if got := LocalHeatmap(&Polygon{}, 2); len(got) != 2 || got[1][1] != 0 {
+		t.Errorf("для пустого многоугольника %v", got)
+	}
+	if got := LocalHeatmap(p, 0); got != nil {
+		t.Errorf("при нулевом размере %v, ожидался nil", got)
+	}
+
+	// С -local_heatmap сетка есть у каждого тяжелого многоугольника
+	r := processed(t, 0, square(0, 0, 10, 150), processOptions{localHeatmap: 2})
+	if want := [][]float64{{37.5, 37.5}, {37.5, 37.5}}; !slices.EqualFunc(r.heavy.LocalHeatmap, want, slices.Equal) {
+		t.Errorf("локальная тепловая карта %v, ожидалась %v", r.heavy.LocalHeatmap, want)
+	}
+}
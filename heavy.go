@@ -0,0 +1,13 @@
+package main
+
+import "github.com/kscvrmn/tev_test/polygon"
+
+// HeavyFunc - критерий тяжелого многоугольника, см. polygon.HeavyFunc
+type HeavyFunc = polygon.HeavyFunc
+
+// heavyByFilter превращает выражение -filter_expr в критерий
+func heavyByFilter(filter *FilterExpr) HeavyFunc {
+	return func(p *Polygon, sumWeight, area float64) bool {
+		return filter.Match(sumWeight, area, len(p.Points))
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kscvrmn/tev_test/polygon"
+)
+
+// parseURLs разбирает список адресов -urls через запятую
+func parseURLs(s string) []string {
+	var urls []string
+	for _, url := range strings.Split(s, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// endpointFor выбирает адрес для индекса. Без -hedge индексы распределяются
+// по адресам -urls по кругу, без -urls используется -url
+func endpointFor(idx int, urls []string) string {
+	if len(urls) == 0 {
+		return *serverURL
+	}
+	return urls[idx%len(urls)]
+}
+
+// fetchPolygon выполняет одну попытку загрузки многоугольника с одного
+// адреса или, в режиме -hedge, со всех адресов сразу
+func (f *cliFetcher) fetchPolygon(ctx context.Context, idx int) (PolygonResult, polygon.RetryKind) {
+	if f.opts.hedge && len(f.opts.urls) > 1 {
+		return f.fetchPolygonHedged(ctx, idx, f.opts.urls)
+	}
+	return f.fetchPolygonOnce(ctx, idx, endpointFor(idx, f.opts.urls))
+}
+
+// Исход запроса к одному из адресов в режиме -hedge
+type hedgedAttempt struct {
+	result PolygonResult
+	retry  polygon.RetryKind
+}
+
+// fetchPolygonHedged запрашивает многоугольник со всех адресов одновременно
+// и берет первый успешный ответ, отменяя остальные запросы. Если успешных
+// ответов нет, возвращается ошибка первого завершившегося запроса. Повтор
+// имеет смысл, если хотя бы одна из ошибок временная, и безопасен как
+// повтор соединения, только если ни один запрос не дошел до сервера
+func (f *cliFetcher) fetchPolygonHedged(ctx context.Context, idx int, urls []string) (PolygonResult, polygon.RetryKind) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Канал с запасом на все запросы, чтобы опоздавшие не блокировались
+	attempts := make(chan hedgedAttempt, len(urls))
+	for _, url := range urls {
+		go func(url string) {
+			result, retry := f.fetchPolygonOnce(ctx, idx, url)
+			if result.err != nil {
+				result.err = fmt.Errorf("%s: %w", url, result.err)
+			}
+			attempts <- hedgedAttempt{result: result, retry: retry}
+		}(url)
+	}
+
+	var first hedgedAttempt
+	for i := range urls {
+		attempt := <-attempts
+		if attempt.result.err == nil {
+			return attempt.result, polygon.RetryNever
+		}
+		if i == 0 {
+			first = attempt
+		}
+		first.retry = max(first.retry, attempt.retry)
+	}
+	return first.result, first.retry
+}
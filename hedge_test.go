@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kscvrmn/tev_test/polygontest"
+)
+
+func TestParseURLs(t *testing.T) {
+	got := parseURLs(" http://a/{index}, ,http://b/{index},")
+	if want := []string{"http://a/{index}", "http://b/{index}"}; !slices.Equal(got, want) {
+		t.Errorf("parseURLs = %q, ожидалось %q", got, want)
+	}
+	if got := parseURLs(""); got != nil {
+		t.Errorf("для пустой строки %q", got)
+	}
+}
+
+// Без -hedge индексы распределяются по адресам по кругу
+func TestEndpointFor(t *testing.T) {
+	urls := []string{"a", "b", "c"}
+	var got []string
+	for idx := range 5 {
+		got = append(got, endpointFor(idx, urls))
+	}
+	if want := []string{"a", "b", "c", "a", "b"}; !slices.Equal(got, want) {
+		t.Errorf("адреса %q, ожидались %q", got, want)
+	}
+	if got := endpointFor(7, nil); got != *serverURL {
+		t.Errorf("без -urls адрес %q, ожидался -url %q", got, *serverURL)
+	}
+}
+
+// hedgedFetcher создает загрузчик, запрашивающий каждый индекс со всех серверов
+func hedgedFetcher(t *testing.T, servers ...*polygontest.Server) *cliFetcher {
+	t.Helper()
+	var urls []string
+	for _, s := range servers {
+		urls = append(urls, s.PolygonURL())
+	}
+	f := newTestFetcher(t, urls[0], http.DefaultClient)
+	templates, err := parseURLTemplates(urls...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.opts.urls, f.opts.urlTemplates, f.opts.hedge = urls, templates, true
+	return f
+}
+
+// Зависший адрес не задерживает загрузку: берется первый успешный ответ,
+// а ответ с ошибкой от другого адреса его не перекрывает
+func TestHedgeFirstSuccess(t *testing.T) {
+	slow := polygontest.NewServer(1, map[int]polygontest.Case{0: polygontest.Slow})
+	defer slow.Close()
+	failing := polygontest.NewServer(1, map[int]polygontest.Case{0: polygontest.ServerError})
+	defer failing.Close()
+	fast := polygontest.NewServer(1, nil)
+	defer fast.Close()
+	f := hedgedFetcher(t, slow, failing, fast)
+
+	start := time.Now()
+	r := f.fetchAndProcessPolygon(context.Background(), 0)
+	if r.err != nil || r.weight != 109 {
+		t.Errorf("ошибка %v, вес %g, ожидался ответ быстрого адреса", r.err, r.weight)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("загрузка заняла %v, зависший адрес не отменен", elapsed)
+	}
+}
+
+// Если ни один адрес не ответил успешно, ошибка указывает адрес
+func TestHedgeAllFail(t *testing.T) {
+	a := polygontest.NewServer(1, map[int]polygontest.Case{0: polygontest.ServerError})
+	defer a.Close()
+	b := polygontest.NewServer(1, map[int]polygontest.Case{0: polygontest.Malformed})
+	defer b.Close()
+	f := hedgedFetcher(t, a, b)
+
+	r := f.fetchAndProcessPolygon(context.Background(), 0)
+	if r.err == nil {
+		t.Fatal("ошибки всех адресов не переданы")
+	}
+	if msg := r.err.Error(); !strings.Contains(msg, a.PolygonURL()) && !strings.Contains(msg, b.PolygonURL()) {
+		t.Errorf("в ошибке %q нет адреса", msg)
+	}
+}
+
+// Отмененный проигравший запрос не засчитывается предохранителю как сбой
+func TestHedgeCancelNotBreakerFailure(t *testing.T) {
+	slow := polygontest.NewServer(1, map[int]polygontest.Case{0: polygontest.Slow})
+	defer slow.Close()
+	fast := polygontest.NewServer(1, nil)
+	defer fast.Close()
+	f := hedgedFetcher(t, slow, fast)
+	f.opts.breaker = newCircuitBreaker(1, time.Hour)
+
+	for range 3 {
+		if r := f.fetchAndProcessPolygon(context.Background(), 0); r.err != nil {
+			t.Fatal(r.err)
+		}
+	}
+	if err := f.opts.breaker.Allow(); err != nil {
+		t.Errorf("предохранитель разомкнут отмененными запросами: %v", err)
+	}
+}
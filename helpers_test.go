@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/kscvrmn/tev_test/polygon"
+)
+
+// newTestFetcher создает загрузчик без повторов для многоугольников с url.
+// Адрес может содержать подстановку индекса {index}, options дополняют
+// и переопределяют настройки по умолчанию
+func newTestFetcher(t testing.TB, url string, client *http.Client, options ...polygon.Option) *cliFetcher {
+	t.Helper()
+	templates, err := parseURLTemplates(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options = append([]polygon.Option{polygon.WithHTTPClient(client), polygon.WithRetries(0)}, options...)
+	return newFetcher(processOptions{urls: []string{url}, urlTemplates: templates}, options...)
+}
+
+// square - квадрат со стороной side и левым нижним углом в (x, y),
+// вес weight поровну распределен по вершинам
+func square(x, y, side, weight float64) *Polygon {
+	w := weight / 4
+	return &Polygon{Points: []WeightedPoint{wp(x, y, w), wp(x+side, y, w), wp(x+side, y+side, w), wp(x, y+side, w)}}
+}
+
+// processed обрабатывает многоугольник так же, как воркер для индекса idx.
+// Без критерия в opts тяжелым считается вес от DefaultHeavyThreshold
+func processed(t testing.TB, idx int, p *Polygon, opts processOptions) PolygonResult {
+	t.Helper()
+	if opts.heavy == nil {
+		opts.heavy = polygon.WeightAtLeast(polygon.DefaultHeavyThreshold)
+	}
+	r := processPolygon(p, context.Background(), opts)
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	r.index = idx
+	return r
+}
+
+// collect агрегирует результаты через collectResults, как после пула воркеров
+func collect(t testing.TB, opts collectOptions, results ...PolygonResult) Result {
+	t.Helper()
+	ch := make(chan PolygonResult, len(results))
+	for _, r := range results {
+		ch <- r
+	}
+	close(ch)
+	resCh := make(chan Result, 1)
+	collectResults(context.Background(), ch, len(results), resCh, opts)
+	return <-resCh
+}
@@ -0,0 +1,13 @@
+package main
+
+import (
+	"context"
+
+	"github.com/kscvrmn/tev_test/polygon"
+)
+
+// withRequestTimeout ограничивает время служебного запроса (например,
+// числа многоугольников) так же, как polygon.Fetcher по умолчанию ограничивает загрузку
+func withRequestTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, polygon.DefaultTimeout)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+)
+
+// polygonFile - файл многоугольников в формате JSON Lines, по одному на строку.
+// Файл читается целиком при запуске, а воркеры обращаются к строкам по индексу
+type polygonFile struct {
+	name  string
+	lines [][]byte
+}
+
+func openPolygonFile(name string) (*polygonFile, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	// Завершающий перевод строки не означает еще одну пустую строку
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	var lines [][]byte
+	if len(data) > 0 {
+		lines = bytes.Split(data, []byte("\n"))
+	}
+	return &polygonFile{name: name, lines: lines}, nil
+}
+
+// line возвращает строку с номером idx (с нуля)
+func (f *polygonFile) line(idx int) ([]byte, error) {
+	if idx < 0 || idx >= len(f.lines) {
+		return nil, fmt.Errorf("в %s нет строки %d: файл закончился после %d строк", f.name, idx+1, len(f.lines))
+	}
+	return bytes.TrimSuffix(f.lines[idx], []byte("\r")), nil
+}
+
+// readAndProcessPolygon - аналог fetchAndProcessPolygon для -input_file:
+// многоугольник берется из строки файла, обработка та же
+func readAndProcessPolygon(ctx context.Context, idx int, opts processOptions) PolygonResult {
+	line, err := opts.input.line(idx)
+	if err != nil {
+		return PolygonResult{err: err}
+	}
+
+	var shape Shape
+	if opts.bboxOnly && !opts.strictJSON {
+		shape, err = decodeShapeCoords(line)
+	} else {
+		shape, err = decodeShape(contentTypeJSON, line, opts.strictJSON)
+	}
+	if err != nil {
+		var raw []byte
+		if opts.keepErrorBodies {
+			raw = line
+		}
+		return PolygonResult{err: fmt.Errorf("строка %d: %v", idx+1, err), rawBody: raw}
+	}
+	return processShape(shape, ctx, opts)
+}
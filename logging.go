@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Допустимые значения -log_format
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// setupLogger настраивает общий slog-логгер: уровень из -log_level
+// (debug, info, warn, error) и формат строк из -log_format (text, json)
+func setupLogger(level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(strings.ToUpper(level))); err != nil {
+		return fmt.Errorf("неизвестный уровень %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch format {
+	case logFormatText:
+		handler = slog.NewTextHandler(os.Stderr, opts)
+	case logFormatJSON:
+		handler = slog.NewJSONHandler(os.Stderr, opts)
+	default:
+		return fmt.Errorf("неизвестный формат %q", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// logAttrs пишет в лог сообщение со структурированными атрибутами
+// и идентификатором запуска из контекста, чтобы строки одного запуска
+// можно было найти в общем потоке логов
+func logAttrs(ctx context.Context, level slog.Level, msg string, args ...any) {
+	if id := runIDFrom(ctx); id != "" {
+		args = append(args, "run_id", id)
+	}
+	slog.Log(ctx, level, msg, args...)
+}
+
+// logf пишет информационное сообщение
+func logf(ctx context.Context, format string, args ...any) {
+	logAttrs(ctx, slog.LevelInfo, fmt.Sprintf(format, args...))
+}
+
+// warnf пишет предупреждение о сбое, не прерывающем работу
+func warnf(ctx context.Context, format string, args ...any) {
+	logAttrs(ctx, slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+
+// fatalf - аналог log.Fatalf с идентификатором запуска
+func fatalf(ctx context.Context, format string, args ...any) {
+	logAttrs(ctx, slog.LevelError, fmt.Sprintf(format, args...))
+	exit(1)
+}
@@ -0,0 +1,105 @@
+package main
+
+import "math"
+
+// Объединенная группа близко расположенных тяжелых многоугольников
+type MergedPolygon struct {
+	Points []WeightedPoint `json:"points"` // объединение точек всех многоугольников группы
+	Weight float64         `json:"weight"` // суммарный вес группы
+	Count  int             `json:"count"`  // число объединенных многоугольников
+}
+
+// mergeByProximity объединяет тяжелые многоугольники, центроиды которых
+// находятся не дальше radius друг от друга (с учетом транзитивности).
+// Связные компоненты графа близости ищутся через систему непересекающихся
+// множеств, а кандидаты в соседи - по сетке с ячейкой radius, чтобы не
+// сравнивать все пары многоугольников
+func mergeByProximity(heavies []*HeavyPolygon, radius float64) []*MergedPolygon {
+	if len(heavies) == 0 || radius <= 0 {
+		return nil
+	}
+
+	type cell struct{ x, y int64 }
+	cx := make([]float64, len(heavies))
+	cy := make([]float64, len(heavies))
+	grid := make(map[cell][]int)
+	for i, heavy := range heavies {
+		cx[i], cy[i] = Centroid(heavy.Polygon)
+		c := cell{int64(math.Floor(cx[i] / radius)), int64(math.Floor(cy[i] / radius))}
+		grid[c] = append(grid[c], i)
+	}
+
+	uf := newUnionFind(len(heavies))
+	r2 := radius * radius
+	for i := range heavies {
+		c := cell{int64(math.Floor(cx[i] / radius)), int64(math.Floor(cy[i] / radius))}
+		// Соседи на расстоянии не больше radius лежат в соседних ячейках
+		for dx := int64(-1); dx <= 1; dx++ {
+			for dy := int64(-1); dy <= 1; dy++ {
+				for _, j := range grid[cell{c.x + dx, c.y + dy}] {
+					if j <= i {
+						continue
+					}
+					if ddx, ddy := cx[i]-cx[j], cy[i]-cy[j]; ddx*ddx+ddy*ddy <= r2 {
+						uf.union(i, j)
+					}
+				}
+			}
+		}
+	}
+
+	// Группы выводятся в порядке первого вхождения для воспроизводимости
+	groups := make(map[int]*MergedPolygon)
+	var merged []*MergedPolygon
+	for i, heavy := range heavies {
+		root := uf.find(i)
+		group, ok := groups[root]
+		if !ok {
+			group = &MergedPolygon{}
+			groups[root] = group
+			merged = append(merged, group)
+		}
+		group.Points = append(group.Points, heavy.Points...)
+		group.Weight += heavy.weight
+		group.Count++
+	}
+	return merged
+}
+
+// Система непересекающихся множеств со сжатием путей и объединением по рангу
+type unionFind struct {
+	parent []int
+	rank   []int
+}
+
+func newUnionFind(n int) *unionFind {
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	return &unionFind{parent: parent, rank: make([]int, n)}
+}
+
+func (u *unionFind) find(x int) int {
+	for u.parent[x] != x {
+		u.parent[x] = u.parent[u.parent[x]]
+		x = u.parent[x]
+	}
+	return x
+}
+
+func (u *unionFind) union(a, b int) {
+	ra, rb := u.find(a), u.find(b)
+	if ra == rb {
+		return
+	}
+	switch {
+	case u.rank[ra] < u.rank[rb]:
+		u.parent[ra] = rb
+	case u.rank[ra] > u.rank[rb]:
+		u.parent[rb] = ra
+	default:
+		u.parent[rb] = ra
+		u.rank[ra]++
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+// heavySquare - тяжелый многоугольник-квадрат со стороной 2 и центром в (x, y)
+func heavySquare(idx int, x, y, weight float64) *HeavyPolygon {
+	return &HeavyPolygon{Polygon: square(x-1, y-1, 2, weight), Index: idx, Area: 4, weight: weight}
+}
+
+func TestMergeByProximity(t *testing.T) {
+	tests := []struct {
+		name    string
+		heavies []*HeavyPolygon
+		radius  float64
+		// Число многоугольников и суммарный вес в группах по порядку
+		counts  []int
+		weights []float64
+	}{
+		{"три близких и один далекий", []*HeavyPolygon{
+			heavySquare(0, 0, 0, 100), heavySquare(1, 3, 0, 110), heavySquare(2, 0, 3, 120), heavySquare(3, 50, 50, 130),
+		}, 5, []int{3, 1}, []float64{330, 130}},
+		// 0 и 2 дальше радиуса, но связаны через 1
+		{"цепочка", []*HeavyPolygon{
+			heavySquare(0, 0, 0, 100), heavySquare(1, 4, 0, 100), heavySquare(2, 8, 0, 100),
+		}, 5, []int{3}, []float64{300}},
+		{"ровно на радиусе", []*HeavyPolygon{heavySquare(0, 0, 0, 100), heavySquare(1, 5, 0, 100)}, 5, []int{2}, []float64{200}},
+		{"все далеко", []*HeavyPolygon{heavySquare(0, 0, 0, 100), heavySquare(1, 10, 0, 150)}, 5, []int{1, 1}, []float64{100, 150}},
+		// Соседние ячейки сетки по отрицательным координатам
+		{"отрицательные координаты", []*HeavyPolygon{heavySquare(0, -1, -1, 100), heavySquare(1, 1, 1, 100)}, 5, []int{2}, []float64{200}},
+		{"пусто", nil, 5, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var counts []int
+			var weights []float64
+			for _, group := range mergeByProximity(tt.heavies, tt.radius) {
+				counts = append(counts, group.Count)
+				weights = append(weights, group.Weight)
+				if len(group.Points) != 4*group.Count {
+					t.Errorf("в группе из %d многоугольников %d точек", group.Count, len(group.Points))
+				}
+			}
+			if !slices.Equal(counts, tt.counts) || !slices.Equal(weights, tt.weights) {
+				t.Errorf("группы %v с весами %v, ожидались %v с весами %v", counts, weights, tt.counts, tt.weights)
+			}
+		})
+	}
+}
+
+// С -merge_radius группы строятся по итоговому списку тяжелых
+func TestCollectMergeRadius(t *testing.T) {
+	result := collect(t, collectOptions{mergeRadius: 5},
+		processed(t, 0, square(0, 0, 2, 100), processOptions{}),
+		processed(t, 1, square(3, 0, 2, 100), processOptions{}),
+		processed(t, 2, square(40, 40, 2, 100), processOptions{}),
+		processed(t, 3, square(1, 1, 2, 10), processOptions{}),
+	)
+	if len(result.MergedPolygons) != 2 || result.MergedPolygons[0].Count+result.MergedPolygons[1].Count != 3 {
+		t.Errorf("группы %+v, ожидалось две из трех тяжелых", result.MergedPolygons)
+	}
+}
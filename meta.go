@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Версия задается при сборке: go build -ldflags "-X main.version=1.2.3".
+// Без нее берется версия модуля из информации о сборке
+var version = ""
+
+// Meta описывает, как был получен результат
+type Meta struct {
+	Version  string            `json:"version"`
+	RunID    string            `json:"run_id,omitempty"`
+	Flags    map[string]string `json:"flags"`
+	Hostname string            `json:"hostname,omitempty"`
+	Start    time.Time         `json:"start"`
+	End      time.Time         `json:"end"`
+	// Длительность в секундах
+	Duration float64 `json:"duration"`
+}
+
+// Заменяет значения флагов с учетными данными в метаданных
+const redacted = "[скрыто]"
+
+// newMeta собирает метаданные запуска. Флаги записываются все,
+// включая значения по умолчанию, чтобы запуск можно было повторить.
+// Учетные данные в результат не попадают, см. metaFlagValue
+func newMeta(ctx context.Context, start, end time.Time) *Meta {
+	flags := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		flags[f.Name] = metaFlagValue(f)
+	})
+	hostname, _ := os.Hostname()
+	return &Meta{
+		Version:  toolVersion(),
+		RunID:    runIDFrom(ctx),
+		Flags:    flags,
+		Hostname: hostname,
+		Start:    start.UTC(),
+		End:      end.UTC(),
+		Duration: end.Sub(start).Seconds(),
+	}
+}
+
+// metaFlagValue возвращает значение флага для метаданных. Значения
+// -auth_bearer и -auth_basic скрываются, от -header остаются только имена
+// заголовков: в них часто передают ключи API
+func metaFlagValue(f *flag.Flag) string {
+	switch f.Name {
+	case "auth_bearer", "auth_basic":
+		if f.Value.String() != "" {
+			return redacted
+		}
+	case "header":
+		if h, ok := f.Value.(headerFlags); ok {
+			names := make([]string, 0, len(h))
+			for name := range h {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			return strings.Join(names, ",")
+		}
+		return redacted
+	}
+	return f.Value.String()
+}
+
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "devel"
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setFlag задает значение флага на время теста
+func setFlag(t *testing.T, name, value string) {
+	t.Helper()
+	f := flag.Lookup(name)
+	old := f.Value.String()
+	if err := f.Value.Set(value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Value.Set(old) })
+}
+
+// Метаданные описывают запуск: версию, идентификатор, все флаги
+// с текущими значениями и время в UTC
+func TestNewMeta(t *testing.T) {
+	old := version
+	version = "1.2.3"
+	t.Cleanup(func() { version = old })
+	setFlag(t, "polygons_num", "42")
+
+	msk := time.FixedZone("MSK", 3*60*60)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, msk)
+	meta := newMeta(withRunID(context.Background(), "run-1"), start, start.Add(1500*time.Millisecond))
+
+	if meta.Version != "1.2.3" || meta.RunID != "run-1" || meta.Duration != 1.5 {
+		t.Errorf("версия %q, run_id %q, длительность %g", meta.Version, meta.RunID, meta.Duration)
+	}
+	if !meta.Start.Equal(start) || meta.Start.Location() != time.UTC || meta.End.Location() != time.UTC {
+		t.Errorf("время %v - %v, ожидалось в UTC", meta.Start, meta.End)
+	}
+	if meta.Flags["polygons_num"] != "42" {
+		t.Errorf("polygons_num = %q, ожидалось 42", meta.Flags["polygons_num"])
+	}
+	// Флаги по умолчанию тоже записываются, чтобы запуск можно было повторить
+	flag.VisitAll(func(f *flag.Flag) {
+		if _, ok := meta.Flags[f.Name]; !ok {
+			t.Errorf("флаг %s отсутствует в метаданных", f.Name)
+		}
+	})
+
+	data, err := json.Marshal(Result{Meta: meta})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"meta":{"version":"1.2.3","run_id":"run-1"`, `"start":"2024-01-01T09:00:00Z"`, `"duration":1.5`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("в выводе нет %s:\n%s", field, data)
+		}
+	}
+	if data, _ := json.Marshal(Result{}); strings.Contains(string(data), `"meta"`) {
+		t.Errorf("без -include_meta блок meta выводится: %s", data)
+	}
+}
+
+// Учетные данные из флагов не попадают в выводимый результат:
+// от -header остаются только имена заголовков
+func TestMetaRedactsSecrets(t *testing.T) {
+	setFlag(t, "auth_bearer", "s3cr3t")
+	setFlag(t, "auth_basic", "user:pa55")
+	extraHeaders.Set("X-Api-Key=k3y")
+	extraHeaders.Set("X-Team=maps")
+	t.Cleanup(func() { clear(extraHeaders) })
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := Result{Meta: newMeta(context.Background(), start, start.Add(time.Second))}
+	var out bytes.Buffer
+	if err := encodeResult(&out, result); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range []string{"s3cr3t", "user:pa55", "pa55", "k3y", "maps"} {
+		if strings.Contains(out.String(), secret) {
+			t.Errorf("секрет %q попал в вывод:\n%s", secret, out.String())
+		}
+	}
+	flags := result.Meta.Flags
+	if flags["auth_bearer"] != redacted || flags["auth_basic"] != redacted {
+		t.Errorf("auth_bearer = %q, auth_basic = %q, ожидалось %q", flags["auth_bearer"], flags["auth_basic"], redacted)
+	}
+	if flags["header"] != "X-Api-Key,X-Team" {
+		t.Errorf("header = %q, ожидались только имена заголовков", flags["header"])
+	}
+}
+
+// Пустые учетные данные остаются пустыми, чтобы было видно, что их не задавали
+func TestMetaEmptyAuth(t *testing.T) {
+	flags := newMeta(context.Background(), time.Now(), time.Now()).Flags
+	if flags["auth_bearer"] != "" || flags["auth_basic"] != "" || flags["header"] != "" {
+		t.Errorf("auth_bearer = %q, auth_basic = %q, header = %q, ожидались пустые", flags["auth_bearer"], flags["auth_basic"], flags["header"])
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Границы корзин гистограммы длительности обработки в секундах,
+// как у клиента Prometheus по умолчанию
+var durationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// fetchMetrics - счетчики загрузки и обработки многоугольников. Формат
+// вывода - текстовый формат Prometheus, который понимает любой скрейпер,
+// поэтому отдельная клиентская библиотека не нужна
+type fetchMetrics struct {
+	fetched atomic.Uint64
+	heavy   atomic.Uint64
+
+	mu sync.Mutex
+	// Ошибки загрузки по HTTP-статусу, 0 - ответ не получен
+	errorsByStatus map[int]uint64
+	// Гистограмма длительности обработки: число наблюдений в каждой корзине
+	// (без накопления), сумма и общее число
+	bucketCounts []uint64
+	durationSum  float64
+	durationN    uint64
+	// Потоковые оценки перцентилей длительности загрузки с повторами
+	latencyP50, latencyP95, latencyP99 *p2Quantile
+}
+
+// Метрики общие для всего процесса: обновляются из воркеров всегда,
+// а отдаются наружу, только если задан -metrics_addr
+var metrics = newFetchMetrics()
+
+func newFetchMetrics() *fetchMetrics {
+	return &fetchMetrics{
+		errorsByStatus: make(map[int]uint64),
+		bucketCounts:   make([]uint64, len(durationBuckets)),
+		latencyP50:     newP2Quantile(0.5),
+		latencyP95:     newP2Quantile(0.95),
+		latencyP99:     newP2Quantile(0.99),
+	}
+}
+
+// recordFetch учитывает итог загрузки одного многоугольника после всех повторов
+func (m *fetchMetrics) recordFetch(result PolygonResult) {
+	if result.err != nil {
+		m.mu.Lock()
+		m.errorsByStatus[result.statusCode]++
+		m.mu.Unlock()
+		return
+	}
+	m.fetched.Add(1)
+	if result.isHeavy {
+		m.heavy.Add(1)
+	}
+}
+
+// observeProcessing учитывает длительность обработки одного многоугольника
+func (m *fetchMetrics) observeProcessing(d time.Duration) {
+	seconds := d.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if i, _ := slices.BinarySearch(durationBuckets, seconds); i < len(durationBuckets) {
+		m.bucketCounts[i]++
+	}
+	m.durationSum += seconds
+	m.durationN++
+}
+
+// observeFetch учитывает длительность загрузки и обработки одного
+// многоугольника вместе со всеми повторами
+func (m *fetchMetrics) observeFetch(d time.Duration) {
+	seconds := d.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latencyP50.Add(seconds)
+	m.latencyP95.Add(seconds)
+	m.latencyP99.Add(seconds)
+}
+
+// Статистика скорости запуска в выходном JSON (только с -stats).
+// Длительности в секундах
+type RunStats struct {
+	WallTime          float64      `json:"wall_time"`
+	PolygonsPerSecond float64      `json:"polygons_per_second"`
+	FetchLatency      FetchLatency `json:"fetch_latency"`
+}
+
+// Приближенные перцентили длительности загрузки многоугольника
+type FetchLatency struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// runStats подводит итог скорости запуска длительностью wall,
+// за который обработано processed многоугольников
+func (m *fetchMetrics) runStats(wall time.Duration, processed int) *RunStats {
+	stats := &RunStats{WallTime: wall.Seconds()}
+	if wall > 0 {
+		stats.PolygonsPerSecond = float64(processed) / wall.Seconds()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats.FetchLatency = FetchLatency{
+		P50: m.latencyP50.Value(),
+		P95: m.latencyP95.Value(),
+		P99: m.latencyP99.Value(),
+	}
+	return stats
+}
+
+// writeTo выводит метрики в текстовом формате Prometheus
+func (m *fetchMetrics) writeTo(w io.Writer) {
+	fmt.Fprintln(w, "# HELP polygons_fetched_total Успешно загруженные и обработанные многоугольники.")
+	fmt.Fprintln(w, "# TYPE polygons_fetched_total counter")
+	fmt.Fprintf(w, "polygons_fetched_total %d\n", m.fetched.Load())
+
+	fmt.Fprintln(w, "# HELP heavy_polygons_total Найденные тяжелые многоугольники.")
+	fmt.Fprintln(w, "# TYPE heavy_polygons_total counter")
+	fmt.Fprintf(w, "heavy_polygons_total %d\n", m.heavy.Load())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP polygon_fetch_errors_total Ошибки загрузки по HTTP-статусу, 0 - ответ не получен.")
+	fmt.Fprintln(w, "# TYPE polygon_fetch_errors_total counter")
+	codes := make([]int, 0, len(m.errorsByStatus))
+	for code := range m.errorsByStatus {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "polygon_fetch_errors_total{status_code=\"%d\"} %d\n", code, m.errorsByStatus[code])
+	}
+
+	fmt.Fprintln(w, "# HELP polygon_processing_duration_seconds Длительность обработки одного многоугольника.")
+	fmt.Fprintln(w, "# TYPE polygon_processing_duration_seconds histogram")
+	var cumulative uint64
+	for i, le := range durationBuckets {
+		cumulative += m.bucketCounts[i]
+		fmt.Fprintf(w, "polygon_processing_duration_seconds_bucket{le=\"%s\"} %d\n", strconv.FormatFloat(le, 'g', -1, 64), cumulative)
+	}
+	fmt.Fprintf(w, "polygon_processing_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.durationN)
+	fmt.Fprintf(w, "polygon_processing_duration_seconds_sum %g\n", m.durationSum)
+	fmt.Fprintf(w, "polygon_processing_duration_seconds_count %d\n", m.durationN)
+}
+
+// serveMetrics отдает метрики по адресу addr на /metrics до отмены контекста,
+// после чего сервер останавливается, дожидаясь текущих запросов
+func serveMetrics(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		metrics.writeTo(w)
+	})
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		warnf(ctx, "Ошибка сервера метрик: %v", err)
+	}
+}
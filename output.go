@@ -0,0 +1,184 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// writeSplitOutput записывает каждый тяжелый многоугольник в отдельный файл
+// polygon_<index>.json и общую сводку в summary.json. Каталог создается при необходимости
+func writeSplitOutput(dir string, result Result) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("ошибка создания каталога %s: %v", dir, err)
+	}
+
+	for _, heavy := range result.HeavyPolygons {
+		name := filepath.Join(dir, fmt.Sprintf("polygon_%d.json", heavy.Index))
+		if err := writeJSONFile(name, heavy); err != nil {
+			return err
+		}
+	}
+
+	// В сводке вместо самих тяжелых многоугольников указывается их количество.
+	// Поле внешней структуры перекрывает одноименное поле встроенного Result
+	summary := struct {
+		Result
+		HeavyPolygons int `json:"heavy_polygons"`
+	}{
+		Result:        result,
+		HeavyPolygons: len(result.HeavyPolygons),
+	}
+	return writeJSONFile(filepath.Join(dir, "summary.json"), summary)
+}
+
+func writeJSONFile(name string, v any) error {
+	output, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации JSON: %v", err)
+	}
+	if err := os.WriteFile(name, output, 0o644); err != nil {
+		return fmt.Errorf("ошибка записи %s: %v", name, err)
+	}
+	return nil
+}
+
+// writeFileAtomic записывает файл name через временный файл в том же каталоге
+// и переименование, чтобы сбой посреди записи не оставил обрезанный файл.
+// С compress содержимое сжимается gzip
+func writeFileAtomic(name string, compress bool, write func(w io.Writer) error) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	// CreateTemp создает файл только для владельца, права делаем как у os.Create
+	if err := tmp.Chmod(0o644); err != nil {
+		return err
+	}
+
+	var w io.Writer = tmp
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(tmp)
+		w = zw
+	}
+	if err := write(w); err != nil {
+		return err
+	}
+	if zw != nil {
+		if err := zw.Close(); err != nil {
+			return err
+		}
+	}
+	// Данные должны попасть на диск до переименования, иначе после сбоя
+	// питания на месте файла может оказаться пустой
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// Запись об ошибке обработки многоугольника для файла -errors_file
+type errorRecord struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+	Body  string `json:"body,omitempty"` // сырое тело ответа, если его удалось получить
+}
+
+// errorsWriter пишет ошибки построчно в формате JSON Lines,
+// чтобы файл было удобно разбирать после запуска
+type errorsWriter struct {
+	enc *json.Encoder
+}
+
+func newErrorsWriter(w io.Writer) *errorsWriter {
+	return &errorsWriter{enc: json.NewEncoder(w)}
+}
+
+func (w *errorsWriter) Write(pr PolygonResult) error {
+	return w.enc.Encode(errorRecord{
+		Index: pr.index,
+		Error: pr.err.Error(),
+		Body:  string(pr.rawBody),
+	})
+}
+
+// Допустимые значения -output_format
+const (
+	outputFormatJSON    = "json"
+	outputFormatSVG     = "svg"
+	outputFormatGeoJSON = "geojson"
+)
+
+// writeSVG выводит тяжелые многоугольники как SVG-документ. Общий bbox
+// становится viewBox, координаты переносятся без преобразований (ось Y в SVG
+// направлена вниз). При colorByWeight заливка меняется от синего для легких
+// к красному для самых тяжелых многоугольников
+func writeSVG(w io.Writer, result Result, colorByWeight bool) error {
+	var b strings.Builder
+
+	// Для пустого агрегата или вырожденного bbox размеры не должны быть нулевыми
+	x, y := result.Bbox.X1, result.Bbox.Y1
+	width, height := result.Bbox.X2-result.Bbox.X1, result.Bbox.Y2-result.Bbox.Y1
+	if result.Bbox.X1 > result.Bbox.X2 || result.Bbox.Y1 > result.Bbox.Y2 {
+		x, y, width, height = 0, 0, 1, 1
+	}
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"%s %s %s %s\">\n", svgNumber(x), svgNumber(y), svgNumber(max(width, 1)), svgNumber(max(height, 1)))
+
+	var maxWeight float64
+	for _, heavy := range result.HeavyPolygons {
+		maxWeight = max(maxWeight, heavy.weight)
+	}
+
+	for _, heavy := range result.HeavyPolygons {
+		points := heavy.Points
+		if len(points) == 0 {
+			continue
+		}
+		// Явно замыкаем контур, если последняя точка не совпадает с первой
+		if points[0].Point != points[len(points)-1].Point {
+			points = append(points[:len(points):len(points)], points[0])
+		}
+
+		b.WriteString(`  <polygon points="`)
+		for i, p := range points {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			fmt.Fprintf(&b, "%s,%s", svgNumber(p.X), svgNumber(p.Y))
+		}
+		b.WriteString(`"`)
+
+		fill := "none"
+		if colorByWeight && maxWeight > 0 {
+			// Оттенок от 240 (синий) до 0 (красный) пропорционально весу
+			hue := 240 * (1 - heavy.weight/maxWeight)
+			fill = fmt.Sprintf("hsl(%.0f,100%%,50%%)", hue)
+		}
+		fmt.Fprintf(&b, " fill=%q stroke=\"black\" vector-effect=\"non-scaling-stroke\"/>\n", fill)
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// svgNumber выводит координату без экспоненты, которую понимают не все
+// просмотрщики SVG, и без лишних нулей дробной части
+func svgNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
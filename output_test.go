@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kscvrmn/tev_test/polygontest"
+)
+
+func TestWriteSplitOutput(t *testing.T) {
+	// Индексы на сервере не совпадают с позициями в списке тяжелых
+	result := Result{
+		Bbox:      Bbox{X1: 0, Y1: 0, X2: 30, Y2: 10},
+		Processed: 10,
+		Total:     10,
+		HeavyPolygons: []*HeavyPolygon{
+			{Polygon: polygonOf([2]float64{20, 0}, [2]float64{30, 0}, [2]float64{30, 10}), Index: 7},
+			{Polygon: polygonOf([2]float64{0, 0}, [2]float64{10, 0}, [2]float64{10, 10}), Index: 3},
+		},
+	}
+	// Каталог создается, если его нет
+	dir := filepath.Join(t.TempDir(), "out")
+	if err := writeSplitOutput(dir, result); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"polygon_3.json", "polygon_7.json", "summary.json"}; !slices.Equal(names, want) {
+		t.Fatalf("файлы %v, ожидались %v", names, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "polygon_7.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var heavy struct {
+		Index  int             `json:"index"`
+		Points []WeightedPoint `json:"points"`
+	}
+	if err := json.Unmarshal(data, &heavy); err != nil {
+		t.Fatalf("некорректный JSON многоугольника: %v", err)
+	}
+	if heavy.Index != 7 || len(heavy.Points) != 3 {
+		t.Errorf("polygon_7.json: индекс %d, точек %d", heavy.Index, len(heavy.Points))
+	}
+
+	data, err = os.ReadFile(filepath.Join(dir, "summary.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary struct {
+		Bbox          Bbox `json:"bbox"`
+		HeavyPolygons int  `json:"heavy_polygons"`
+		Processed     int  `json:"processed"`
+	}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatalf("некорректный JSON сводки: %v", err)
+	}
+	if summary.HeavyPolygons != 2 || summary.Processed != 10 || summary.Bbox != result.Bbox {
+		t.Errorf("сводка %+v не соответствует результату", summary)
+	}
+}
+
+// Каждая ошибка попадает в -errors_file строкой JSON с индексом и сообщением,
+// а для неразобранного ответа - и с его телом
+func TestErrorsFile(t *testing.T) {
+	const total = 5
+	server := polygontest.NewServer(total, map[int]polygontest.Case{
+		1: polygontest.ServerError,
+		3: polygontest.Malformed,
+	})
+	defer server.Close()
+	f := newTestFetcher(t, server.PolygonURL(), server.Client())
+	f.opts.keepErrorBodies = true
+
+	var out bytes.Buffer
+	resCh := make(chan Result, 1)
+	runPool(context.Background(), total, total, nil, resCh, f,
+		collectOptions{errors: newErrorsWriter(&out), errorsOnStderr: true}, newFetchLimiter(0, 0, 0), nil, newPauseGate())
+	result := <-resCh
+	if result.ErrorCount != 2 {
+		t.Fatalf("ошибок %d, ожидалось 2", result.ErrorCount)
+	}
+
+	records := make(map[int]errorRecord)
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var record errorRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("строка %q: %v", line, err)
+		}
+		records[record.Index] = record
+	}
+	if len(records) != 2 {
+		t.Fatalf("записи %v, ожидались для индексов 1 и 3", records)
+	}
+	if r := records[1]; !strings.Contains(r.Error, "500") || r.Body != "" {
+		t.Errorf("индекс 1: %+v, ожидалась ошибка статуса 500 без тела", r)
+	}
+	if r := records[3]; !strings.Contains(r.Error, "JSON") || !strings.HasPrefix(r.Body, `{"points"`) {
+		t.Errorf("индекс 3: %+v, ожидалась ошибка разбора с телом ответа", r)
+	}
+	// Сообщения в файле совпадают с ошибками результата
+	for _, e := range result.Errors {
+		if records[e.Index].Error != e.Error {
+			t.Errorf("индекс %d: в файле %q, в результате %q", e.Index, records[e.Index].Error, e.Error)
+		}
+	}
+}
+
+// SVG: общий bbox задает viewBox, контуры замыкаются, а с раскраской
+// самый тяжелый многоугольник красный, вдвое более легкий - зеленый
+func TestWriteSVG(t *testing.T) {
+	result := collect(t, collectOptions{},
+		processed(t, 0, square(0, 0, 10, 150), processOptions{}),
+		processed(t, 1, square(20, 5, 10.5, 300), processOptions{}),
+	)
+	slices.SortFunc(result.HeavyPolygons, func(a, b *HeavyPolygon) int { return a.Index - b.Index })
+
+	tests := []struct {
+		name          string
+		colorByWeight bool
+		want          []string
+	}{
+		{"без раскраски", false, []string{
+			`viewBox="0 0 30.5 15.5"`,
+			`<polygon points="0,0 10,0 10,10 0,10 0,0" fill="none"`,
+			`<polygon points="20,5 30.5,5 30.5,15.5 20,15.5 20,5" fill="none"`,
+		}},
+		{"по весу", true, []string{
+			`points="0,0 10,0 10,10 0,10 0,0" fill="hsl(120,100%,50%)"`,
+			`points="20,5 30.5,5 30.5,15.5 20,15.5 20,5" fill="hsl(0,100%,50%)"`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := writeSVG(&out, result, tt.colorByWeight); err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("в SVG нет %s:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+
+	// Без многоугольников bbox вырожден, но размеры viewBox не нулевые
+	var out bytes.Buffer
+	if err := writeSVG(&out, collect(t, collectOptions{}), true); err != nil {
+		t.Fatal(err)
+	}
+	if want := "<svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 0 1 1\">\n</svg>\n"; out.String() != want {
+		t.Errorf("пустой SVG %q, ожидался %q", out.String(), want)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Пара тяжелых многоугольников с пересекающимися bbox (индексы на сервере)
+type BboxOverlap struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+// BboxOverlaps находит пары тяжелых многоугольников, локальные bbox которых
+// пересекаются по области ненулевой площади. Касание границами пересечением
+// не считается: такие пары находит -adjacency. Многоугольники упорядочиваются
+// по левой границе bbox, и для каждого просматриваются только те, что
+// начинаются левее его правой границы
+func BboxOverlaps(heavies []*HeavyPolygon) []BboxOverlap {
+	sorted := slices.Clone(heavies)
+	slices.SortFunc(sorted, func(a, b *HeavyPolygon) int { return cmp.Compare(a.bbox.X1, b.bbox.X1) })
+
+	var overlaps []BboxOverlap
+	for i, a := range sorted {
+		for _, b := range sorted[i+1:] {
+			if b.bbox.X1 >= a.bbox.X2 {
+				break
+			}
+			if min(a.bbox.X2, b.bbox.X2) > b.bbox.X1 && min(a.bbox.Y2, b.bbox.Y2) > max(a.bbox.Y1, b.bbox.Y1) {
+				overlaps = append(overlaps, BboxOverlap{min(a.Index, b.Index), max(a.Index, b.Index)})
+			}
+		}
+	}
+
+	// Порядок пар не зависит от порядка поступления многоугольников
+	slices.SortFunc(overlaps, func(x, y BboxOverlap) int {
+		return cmp.Or(cmp.Compare(x.A, y.A), cmp.Compare(x.B, y.B))
+	})
+	return overlaps
+}
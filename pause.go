@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// Шлюз паузы: воркеры проверяют его перед каждой загрузкой и блокируются,
+// пока загрузка приостановлена. Это позволяет временно снять нагрузку с сервера,
+// не прерывая всю обработку
+type pauseGate struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	paused bool
+}
+
+func newPauseGate() *pauseGate {
+	g := &pauseGate{}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// Toggle переключает состояние паузы и возвращает новое значение
+func (g *pauseGate) Toggle() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paused = !g.paused
+	g.cond.Broadcast()
+	return g.paused
+}
+
+// SetPaused явно включает или снимает паузу
+func (g *pauseGate) SetPaused(paused bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paused = paused
+	g.cond.Broadcast()
+}
+
+// Wait блокируется, пока включена пауза. sync.Cond ничего не знает о контексте,
+// поэтому при отмене контекста будим всех ожидающих вручную, иначе таймаут
+// не смог бы прервать приостановленных воркеров
+func (g *pauseGate) Wait(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		return ctx.Err()
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.cond.Broadcast()
+	})
+	defer stop()
+
+	for g.paused && ctx.Err() == nil {
+		g.cond.Wait()
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "context"
+
+// На платформах без SIGUSR1/SIGUSR2 управление паузой через сигналы недоступно
+func watchPauseSignals(ctx context.Context, gate *pauseGate) {}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchPauseSignals управляет паузой через сигналы:
+// SIGUSR1 переключает паузу, SIGUSR2 снимает ее
+func watchPauseSignals(ctx context.Context, gate *pauseGate) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-sigCh:
+			if sig == syscall.SIGUSR2 {
+				gate.SetPaused(false)
+				logf(ctx, "Загрузка возобновлена")
+				continue
+			}
+			if gate.Toggle() {
+				logf(ctx, "Загрузка приостановлена")
+			} else {
+				logf(ctx, "Загрузка возобновлена")
+			}
+		}
+	}
+}
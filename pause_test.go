@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kscvrmn/tev_test/polygontest"
+)
+
+// countingTransport считает запросы, прошедшие через клиент
+type countingTransport struct {
+	http.RoundTripper
+	requests atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.requests.Add(1)
+	return c.RoundTripper.RoundTrip(r)
+}
+
+// Пока включена пауза, воркеры не загружают многоугольники,
+// а после ее снятия пул доводит работу до конца
+func TestPauseHaltsAndResumesPool(t *testing.T) {
+	const total = 10
+	server := polygontest.NewServer(total, nil)
+	defer server.Close()
+	transport := &countingTransport{RoundTripper: server.Client().Transport}
+	f := newTestFetcher(t, server.PolygonURL(), &http.Client{Transport: transport})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pause := newPauseGate()
+	pause.Toggle()
+	resCh := make(chan Result, 1)
+	runPool(ctx, total, total, nil, resCh, f, collectOptions{}, newFetchLimiter(0, 0, 0), nil, pause)
+
+	time.Sleep(100 * time.Millisecond)
+	if got := transport.requests.Load(); got != 0 {
+		t.Fatalf("во время паузы выполнено %d запросов", got)
+	}
+
+	if pause.Toggle() {
+		t.Fatal("повторное переключение не сняло паузу")
+	}
+	select {
+	case result := <-resCh:
+		if result.Partial || result.Processed != total {
+			t.Errorf("partial %v, обработано %d из %d", result.Partial, result.Processed, total)
+		}
+	case <-ctx.Done():
+		t.Fatal("пул не завершился после снятия паузы")
+	}
+	if got := transport.requests.Load(); got != total {
+		t.Errorf("%d запросов, ожидалось %d", got, total)
+	}
+}
+
+// Отмена контекста прерывает ожидание на паузе
+func TestPauseWaitCancel(t *testing.T) {
+	pause := newPauseGate()
+	pause.SetPaused(true)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() { done <- pause.Wait(ctx) }()
+	select {
+	case <-done:
+		t.Fatal("Wait вернулся во время паузы")
+	case <-time.After(50 * time.Millisecond):
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("ошибка %v, ожидалась context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("отмена контекста не прервала паузу")
+	}
+}
@@ -0,0 +1,66 @@
+package polygon
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// FloatCoords включает вывод целых координат в JSON в виде 1.0 вместо 1.
+// Маршалер не принимает параметров, поэтому формат задается один раз
+// до сериализации и дальше не меняется
+var FloatCoords bool
+
+// MarshalJSON выводит целые координаты без дробной части или, с FloatCoords,
+// в виде 1.0, как ожидают потребители с вещественными координатами
+func (p Point) MarshalJSON() ([]byte, error) {
+	b, err := appendPoint([]byte(`{`), p)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '}'), nil
+}
+
+// MarshalJSON нужен явно: иначе метод встроенного Point подменил бы
+// сериализацию точки и вес потерялся бы
+func (p WeightedPoint) MarshalJSON() ([]byte, error) {
+	weight, err := json.Marshal(p.Weight)
+	if err != nil {
+		return nil, err
+	}
+	b, err := appendPoint([]byte(`{`), p.Point)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, `,"weight":`...)
+	b = append(b, weight...)
+	return append(b, '}'), nil
+}
+
+// appendPoint дописывает поля x и y без закрывающей скобки
+func appendPoint(b []byte, p Point) ([]byte, error) {
+	b = append(b, `"x":`...)
+	b, err := appendCoord(b, p.X)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, `,"y":`...)
+	return appendCoord(b, p.Y)
+}
+
+// appendCoord дописывает координату. NaN и бесконечности в JSON не
+// представимы, поэтому, как и json.Marshal, для них возвращается ошибка
+func appendCoord(b []byte, v float64) ([]byte, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil, &json.UnsupportedValueError{
+			Value: reflect.ValueOf(v),
+			Str:   strconv.FormatFloat(v, 'g', -1, 64),
+		}
+	}
+	b = strconv.AppendFloat(b, v, 'f', -1, 64)
+	if FloatCoords && v == math.Trunc(v) {
+		b = append(b, ".0"...)
+	}
+	return b, nil
+}
@@ -0,0 +1,54 @@
+package polygon
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestMarshalCoords(t *testing.T) {
+	tests := []struct {
+		name        string
+		v           any
+		floatCoords bool
+		want        string
+	}{
+		{"целые", Point{X: 1, Y: -2}, false, `{"x":1,"y":-2}`},
+		{"дробные", Point{X: 0.5, Y: 1e-7}, false, `{"x":0.5,"y":0.0000001}`},
+		{"целые с FloatCoords", Point{X: 1, Y: 0}, true, `{"x":1.0,"y":0.0}`},
+		{"дробные с FloatCoords", Point{X: 1.5, Y: 2}, true, `{"x":1.5,"y":2.0}`},
+		{"точка с весом", WeightedPoint{Point: Point{X: 3, Y: 4}, Weight: 2.5}, false, `{"x":3,"y":4,"weight":2.5}`},
+		{"многоугольник", Polygon{Points: []WeightedPoint{{Point: Point{X: 1, Y: 2}, Weight: 1}}}, false, `{"points":[{"x":1,"y":2,"weight":1}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			FloatCoords = tt.floatCoords
+			defer func() { FloatCoords = false }()
+			got, err := json.Marshal(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("получено %s, ожидалось %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// Как и json.Marshal для float64, сериализация NaN и бесконечностей
+// возвращает ошибку вместо некорректного JSON
+func TestMarshalNonFiniteCoords(t *testing.T) {
+	for _, v := range []any{
+		Point{X: math.NaN()},
+		Point{Y: math.Inf(1)},
+		WeightedPoint{Point: Point{X: math.Inf(-1)}},
+		Polygon{Points: []WeightedPoint{{Point: Point{Y: math.NaN()}}}},
+	} {
+		b, err := json.Marshal(v)
+		var unsupported *json.UnsupportedValueError
+		if !errors.As(err, &unsupported) {
+			t.Errorf("%+v: получено %s, %v, ожидалась json.UnsupportedValueError", v, b, err)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package polygon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"text/template"
+	"time"
+)
+
+// Число повторов загрузки по умолчанию, совпадает со значением -max_retries
+const DefaultMaxRetries = 3
+
+// Ограничение времени одного HTTP-запроса вместе с чтением тела ответа
+const DefaultTimeout = 30 * time.Second
+
+// Fetcher загружает многоугольники по индексам и обрабатывает их.
+// HTTP-поведение настраивается опциями NewFetcher, поэтому при встраивании
+// не нужно передавать растущий список позиционных параметров.
+// Fetcher безопасен для одновременного использования из нескольких горутин
+type Fetcher struct {
+	// Клиент для всех запросов, общий для воркеров
+	client *http.Client
+	// Ограничение времени одного запроса вместе с чтением тела ответа
+	timeout time.Duration
+	// Метод HTTP-запроса
+	method string
+	// Число повторов при сбое соединения, когда запрос не дошел до сервера
+	connectRetries int
+	// Число повторов запроса, который мог быть частично выполнен (5xx, обрыв
+	// при чтении ответа). Для неидемпотентных методов такие запросы не повторяются
+	requestRetries int
+	// Дополнительные заголовки каждого запроса
+	header http.Header
+	// Шаблон тела запроса, nil - запрос без тела
+	bodyTemplate *template.Template
+	// Критерий тяжелого многоугольника и порог суммарного веса, который
+	// действует, если критерий не задан
+	heavy          HeavyFunc
+	heavyThreshold float64
+}
+
+// Option настраивает Fetcher при создании
+type Option func(*Fetcher)
+
+// NewFetcher создает Fetcher. Без опций поведение совпадает с запуском
+// утилиты без флагов: общий клиент с пулом соединений, таймаут запроса
+// 30 секунд, GET, 3 повтора и порог тяжелого многоугольника 100
+func NewFetcher(options ...Option) *Fetcher {
+	f := &Fetcher{
+		timeout:        DefaultTimeout,
+		method:         http.MethodGet,
+		connectRetries: DefaultMaxRetries,
+		requestRetries: DefaultMaxRetries,
+		header:         http.Header{},
+		heavyThreshold: DefaultHeavyThreshold,
+	}
+	for _, option := range options {
+		option(f)
+	}
+	// Критерий выбирается после всех опций, поэтому результат не зависит
+	// от их порядка: WithHeavyFunc всегда важнее WithHeavyThreshold
+	if f.heavy == nil {
+		f.heavy = WeightAtLeast(f.heavyThreshold)
+	}
+	// Клиент по умолчанию создается, только если его не передали опцией
+	if f.client == nil {
+		f.client = NewHTTPClient(runtime.GOMAXPROCS(0))
+	}
+	return f
+}
+
+// NewHTTPClient создает клиент, общий для всех воркеров. Один Transport
+// переиспользует соединения между запросами (keep-alive), а запас простаивающих
+// соединений рассчитан на все воркеры, чтобы при большом -polygons_num не
+// открывать новое TCP/TLS-соединение на каждый запрос. Общий таймаут клиента
+// не задается: время запроса ограничивается контекстом (см. WithTimeout)
+func NewHTTPClient(workers int) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = max(transport.MaxIdleConns, workers)
+	transport.MaxIdleConnsPerHost = max(workers, 1)
+	transport.IdleConnTimeout = 90 * time.Second
+	return &http.Client{Transport: transport}
+}
+
+// WithHTTPClient задает клиент, например с собственным Transport или
+// клиент httptest.Server в тестах
+func WithHTTPClient(client *http.Client) Option {
+	return func(f *Fetcher) {
+		f.client = client
+	}
+}
+
+// WithTimeout ограничивает время одного запроса. Общий дедлайн контекста,
+// если он наступает раньше, продолжает действовать
+func WithTimeout(timeout time.Duration) Option {
+	return func(f *Fetcher) {
+		f.timeout = timeout
+	}
+}
+
+// WithRetries задает одинаковое число повторов при сбоях соединения
+// и запроса, 0 - без повторов
+func WithRetries(retries int) Option {
+	return func(f *Fetcher) {
+		f.connectRetries = max(retries, 0)
+		f.requestRetries = max(retries, 0)
+	}
+}
+
+// WithConnectRetries задает число повторов, когда соединение не установлено
+// и запрос гарантированно не дошел до сервера
+func WithConnectRetries(retries int) Option {
+	return func(f *Fetcher) {
+		f.connectRetries = max(retries, 0)
+	}
+}
+
+// WithRequestRetries задает число повторов запроса, который сервер мог
+// частично выполнить. Действует только для идемпотентных методов
+func WithRequestRetries(retries int) Option {
+	return func(f *Fetcher) {
+		f.requestRetries = max(retries, 0)
+	}
+}
+
+// WithMethod задает метод HTTP-запроса, по умолчанию GET
+func WithMethod(method string) Option {
+	return func(f *Fetcher) {
+		f.method = method
+	}
+}
+
+// WithBodyTemplate задает шаблон тела запроса. Шаблон выполняется для
+// каждого запроса с данными RequestBodyData, например {"id": {{.Index}}}.
+// Тело отправляется с Content-Type application/json, если тип не задан WithHeader
+func WithBodyTemplate(tmpl *template.Template) Option {
+	return func(f *Fetcher) {
+		f.bodyTemplate = tmpl
+	}
+}
+
+// WithHeader добавляет заголовок ко всем запросам. Заголовки, которые
+// выставляет утилита (Accept, идентификатор запуска, дедлайн), имеют приоритет
+func WithHeader(key, value string) Option {
+	return func(f *Fetcher) {
+		f.header.Add(key, value)
+	}
+}
+
+// WithHeavyFunc задает критерий тяжелого многоугольника вместо порога
+// суммарного веса. nil оставляет порог
+func WithHeavyFunc(heavy HeavyFunc) Option {
+	return func(f *Fetcher) {
+		f.heavy = heavy
+	}
+}
+
+// WithHeavyThreshold задает порог суммарного веса тяжелого многоугольника,
+// по умолчанию 100. Не действует, если задан WithHeavyFunc
+func WithHeavyThreshold(threshold float64) Option {
+	return func(f *Fetcher) {
+		f.heavyThreshold = threshold
+	}
+}
+
+// Client возвращает клиент, через который выполняются запросы
+func (f *Fetcher) Client() *http.Client {
+	return f.client
+}
+
+// Timeout возвращает ограничение времени одного запроса
+func (f *Fetcher) Timeout() time.Duration {
+	return f.timeout
+}
+
+// Header возвращает копию дополнительных заголовков запросов
+func (f *Fetcher) Header() http.Header {
+	return f.header.Clone()
+}
+
+// Method возвращает HTTP-метод запросов
+func (f *Fetcher) Method() string {
+	return f.method
+}
+
+// Retries возвращает число повторов при сбоях соединения и запроса.
+// Для неидемпотентного метода запросы, дошедшие до сервера, не повторяются
+func (f *Fetcher) Retries() (connect, request int) {
+	if !IdempotentMethod(f.method) {
+		return f.connectRetries, 0
+	}
+	return f.connectRetries, f.requestRetries
+}
+
+// Heavy возвращает критерий тяжелого многоугольника
+func (f *Fetcher) Heavy() HeavyFunc {
+	return f.heavy
+}
+
+// Тип тела запроса по умолчанию: шаблоны описывают JSON
+const requestBodyContentType = "application/json"
+
+// RequestBodyData - данные, доступные в шаблоне тела запроса
+type RequestBodyData struct {
+	// Индекс запрашиваемого многоугольника
+	Index int
+}
+
+// NewRequest создает запрос многоугольника idx по адресу url с методом,
+// телом и заголовками Fetcher. Тело строится заново для каждого вызова:
+// повтор отправляет его целиком, а прочитанное прошлой попыткой тело
+// использовать нельзя
+func (f *Fetcher) NewRequest(ctx context.Context, idx int, url string) (*http.Request, error) {
+	var body io.Reader
+	if f.bodyTemplate != nil {
+		var buf bytes.Buffer
+		if err := f.bodyTemplate.Execute(&buf, RequestBodyData{Index: idx}); err != nil {
+			return nil, fmt.Errorf("ошибка шаблона тела запроса: %v", err)
+		}
+		body = &buf
+	}
+	req, err := http.NewRequestWithContext(ctx, f.method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка создания запроса: %v", err)
+	}
+	for key, values := range f.header {
+		req.Header[key] = values
+	}
+	// Тип тела, заданный через WithHeader, не переопределяется
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", requestBodyContentType)
+	}
+	return req, nil
+}
+
+// Fetched - загруженный многоугольник и его характеристики
+type Fetched struct {
+	Index   int
+	Polygon *Polygon
+	Stats
+	Heavy bool
+}
+
+// Fetch загружает многоугольник idx в формате JSON с адреса url, повторяя
+// запрос при временных сбоях, и считает его характеристики. Возможности
+// утилиты поверх этого (несколько адресов, лимиты, MessagePack, ломаные)
+// в Fetch не входят
+func (f *Fetcher) Fetch(ctx context.Context, idx int, url string) (Fetched, error) {
+	var fetched Fetched
+	var err error
+	connectRetries, requestRetries := f.Retries()
+	Retry(ctx, connectRetries, requestRetries, func(int) RetryKind {
+		var retry RetryKind
+		fetched, retry, err = f.fetchOnce(ctx, idx, url)
+		return retry
+	})
+	if err != nil {
+		return Fetched{}, fmt.Errorf("многоугольник %d: %w", idx, err)
+	}
+	return fetched, nil
+}
+
+func (f *Fetcher) fetchOnce(ctx context.Context, idx int, url string) (Fetched, RetryKind, error) {
+	reqCtx, cancel := context.WithTimeout(ctx, f.timeout)
+	defer cancel()
+	req, err := f.NewRequest(reqCtx, idx, url)
+	if err != nil {
+		return Fetched{}, RetryNever, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Fetched{}, HTTPErrorKind(err), fmt.Errorf("ошибка HTTP запроса: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Fetched{}, StatusKind(resp.StatusCode), &StatusError{Code: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Fetched{}, RetryRequest, fmt.Errorf("ошибка чтения ответа: %w", err)
+	}
+	p := &Polygon{}
+	if err := json.Unmarshal(body, p); err != nil {
+		return Fetched{}, RetryNever, fmt.Errorf("ошибка разбора JSON: %w", err)
+	}
+	stats, err := ProcessPolygon(ctx, p)
+	if err != nil {
+		return Fetched{}, RetryNever, err
+	}
+	return Fetched{Index: idx, Polygon: p, Stats: stats, Heavy: f.heavy(p, stats.Weight, stats.Area)}, RetryNever, nil
+}
+
+// StatusError - неуспешный статус ответа сервера
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("некорректный статус ответа: %d", e.Code)
+}
@@ -0,0 +1,255 @@
+package polygon_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/kscvrmn/tev_test/polygon"
+	"github.com/kscvrmn/tev_test/polygontest"
+)
+
+func TestNewFetcherDefaults(t *testing.T) {
+	f := polygon.NewFetcher()
+	if f.Client() == nil {
+		t.Error("клиент по умолчанию не создан")
+	}
+	if f.Timeout() != polygon.DefaultTimeout {
+		t.Errorf("таймаут %v, ожидался %v", f.Timeout(), polygon.DefaultTimeout)
+	}
+	if f.Method() != http.MethodGet {
+		t.Errorf("метод %s, ожидался GET", f.Method())
+	}
+	if connect, request := f.Retries(); connect != polygon.DefaultMaxRetries || request != polygon.DefaultMaxRetries {
+		t.Errorf("повторы %d/%d, ожидалось %d", connect, request, polygon.DefaultMaxRetries)
+	}
+	if heavy := f.Heavy(); heavy(&polygon.Polygon{}, 99, 0) || !heavy(&polygon.Polygon{}, 100, 0) {
+		t.Error("по умолчанию тяжелым должен быть многоугольник с весом от 100")
+	}
+}
+
+func TestFetcherRetryOptions(t *testing.T) {
+	tests := []struct {
+		name             string
+		options          []polygon.Option
+		connect, request int
+	}{
+		{"WithRetries", []polygon.Option{polygon.WithRetries(5)}, 5, 5},
+		{"отрицательное число", []polygon.Option{polygon.WithRetries(-1)}, 0, 0},
+		{"раздельные повторы", []polygon.Option{polygon.WithConnectRetries(4), polygon.WithRequestRetries(1)}, 4, 1},
+		{"уточнение WithRetries", []polygon.Option{polygon.WithRetries(2), polygon.WithRequestRetries(7)}, 2, 7},
+		// Запрос, который сервер мог выполнить, для POST не повторяется
+		{"неидемпотентный метод", []polygon.Option{polygon.WithMethod(http.MethodPost), polygon.WithRetries(2)}, 2, 0},
+		{"идемпотентный метод", []polygon.Option{polygon.WithMethod(http.MethodPut), polygon.WithRetries(2)}, 2, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			connect, request := polygon.NewFetcher(tt.options...).Retries()
+			if connect != tt.connect || request != tt.request {
+				t.Errorf("повторы %d/%d, ожидалось %d/%d", connect, request, tt.connect, tt.request)
+			}
+		})
+	}
+}
+
+// Метод, заголовки и тело по шаблону доходят до сервера
+func TestFetcherRequestOptions(t *testing.T) {
+	type request struct {
+		method, contentType, token, body string
+	}
+	var mu sync.Mutex
+	var got []request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, request{r.Method, r.Header.Get("Content-Type"), r.Header.Get("X-Token"), string(body)})
+		mu.Unlock()
+		w.Write([]byte(`{"points":[]}`))
+	}))
+	defer server.Close()
+
+	tmpl := template.Must(template.New("body").Parse(`{"id": {{.Index}}}`))
+	tests := []struct {
+		name    string
+		options []polygon.Option
+		want    request
+	}{
+		{"по умолчанию", nil, request{method: http.MethodGet}},
+		{"заголовок", []polygon.Option{polygon.WithHeader("X-Token", "secret")}, request{method: http.MethodGet, token: "secret"}},
+		{
+			"тело по шаблону",
+			[]polygon.Option{polygon.WithMethod(http.MethodPost), polygon.WithBodyTemplate(tmpl)},
+			request{method: http.MethodPost, contentType: "application/json", body: `{"id": 7}`},
+		},
+		{
+			"тип тела из заголовка",
+			[]polygon.Option{polygon.WithMethod(http.MethodPost), polygon.WithBodyTemplate(tmpl), polygon.WithHeader("Content-Type", "text/plain")},
+			request{method: http.MethodPost, contentType: "text/plain", body: `{"id": 7}`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			options := append([]polygon.Option{polygon.WithHTTPClient(server.Client())}, tt.options...)
+			if _, err := polygon.NewFetcher(options...).Fetch(context.Background(), 7, server.URL); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("запросы %+v, ожидался %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetcherTimeout(t *testing.T) {
+	server := polygontest.NewServer(1, map[int]polygontest.Case{0: polygontest.Slow})
+	defer server.Close()
+	f := polygon.NewFetcher(
+		polygon.WithHTTPClient(server.Client()),
+		polygon.WithTimeout(50*time.Millisecond),
+		polygon.WithRetries(0),
+	)
+	start := time.Now()
+	if _, err := f.Fetch(context.Background(), 0, server.URL+"/polygon/0"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ошибка %v, ожидалось превышение таймаута", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("запрос прерван через %v", elapsed)
+	}
+}
+
+// Ответы 5xx повторяются не больше WithRequestRetries раз
+func TestFetcherRetriesServerErrors(t *testing.T) {
+	for _, tt := range []struct {
+		retries  int
+		requests int32
+		ok       bool
+	}{
+		{0, 1, false},
+		{1, 2, false},
+		{2, 3, true},
+	} {
+		var requests atomic.Int32
+		// Первые два ответа - 500, затем многоугольник
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) <= 2 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(`{"points":[{"x":1,"y":2,"weight":3}]}`))
+		}))
+		f := polygon.NewFetcher(polygon.WithHTTPClient(server.Client()), polygon.WithRequestRetries(tt.retries))
+		_, err := f.Fetch(context.Background(), 0, server.URL)
+		server.Close()
+
+		var status *polygon.StatusError
+		switch {
+		case tt.ok && err != nil:
+			t.Errorf("повторов %d: %v", tt.retries, err)
+		case !tt.ok && (!errors.As(err, &status) || status.Code != http.StatusInternalServerError):
+			t.Errorf("повторов %d: ошибка %v, ожидался статус 500", tt.retries, err)
+		}
+		if got := requests.Load(); got != tt.requests {
+			t.Errorf("повторов %d: %d запросов, ожидалось %d", tt.retries, got, tt.requests)
+		}
+	}
+}
+
+// Сбой соединения повторяется WithConnectRetries раз, даже без повторов запроса
+func TestFetcherRetriesConnectErrors(t *testing.T) {
+	var dials atomic.Int32
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dials.Add(1)
+			return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("соединение отклонено")}
+		},
+	}
+	f := polygon.NewFetcher(
+		polygon.WithHTTPClient(&http.Client{Transport: transport}),
+		polygon.WithConnectRetries(2),
+		polygon.WithRequestRetries(0),
+	)
+	if _, err := f.Fetch(context.Background(), 0, "http://polygons.invalid/0"); err == nil {
+		t.Fatal("загрузка без соединения прошла без ошибки")
+	}
+	if got := dials.Load(); got != 3 {
+		t.Errorf("%d попыток соединения, ожидалось 3", got)
+	}
+}
+
+func TestFetcherFetch(t *testing.T) {
+	server := polygontest.NewServer(4, map[int]polygontest.Case{
+		1: polygontest.Empty,
+		2: polygontest.ServerError,
+		3: polygontest.Malformed,
+	})
+	defer server.Close()
+	f := polygon.NewFetcher(polygon.WithHTTPClient(server.Client()), polygon.WithRetries(0))
+	url := func(idx int) string { return server.URL + "/polygon/" + strconv.Itoa(idx) }
+
+	fetched, err := f.Fetch(context.Background(), 0, url(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := polygontest.Polygon(0)
+	if fetched.Index != 0 || fetched.Weight != 109 || fetched.Area != 100 || fetched.Points != 4 || !fetched.Heavy {
+		t.Errorf("многоугольник 0: %+v", fetched)
+	}
+	if fetched.Bbox != (polygon.Bbox{X1: 0, Y1: 0, X2: 10, Y2: 10}) || len(fetched.Polygon.Points) != len(want.Points) {
+		t.Errorf("многоугольник 0: bbox %+v, точек %d", fetched.Bbox, len(fetched.Polygon.Points))
+	}
+
+	if fetched, err := f.Fetch(context.Background(), 1, url(1)); err != nil || fetched.Points != 0 || fetched.Heavy {
+		t.Errorf("пустой многоугольник: %+v, %v", fetched, err)
+	}
+	if _, err := f.Fetch(context.Background(), 2, url(2)); err == nil {
+		t.Error("ответ 500 принят")
+	}
+	if _, err := f.Fetch(context.Background(), 3, url(3)); err == nil {
+		t.Error("обрезанный JSON принят")
+	}
+}
+
+// Критерий тяжелого многоугольника не зависит от порядка опций
+func TestFetcherHeavyOptionOrder(t *testing.T) {
+	server := polygontest.NewServer(2, nil)
+	defer server.Close()
+	byPoints := func(p *polygon.Polygon, _, _ float64) bool { return len(p.Points) >= 4 }
+	client := polygon.WithHTTPClient(server.Client())
+
+	tests := []struct {
+		name    string
+		options []polygon.Option
+		// Тяжелые ли многоугольники 0 (вес 109) и 1 (вес 9)
+		want [2]bool
+	}{
+		{"по умолчанию", nil, [2]bool{true, false}},
+		{"порог", []polygon.Option{polygon.WithHeavyThreshold(5)}, [2]bool{true, true}},
+		{"критерий до порога", []polygon.Option{polygon.WithHeavyFunc(byPoints), polygon.WithHeavyThreshold(1e9)}, [2]bool{true, true}},
+		{"критерий после порога", []polygon.Option{polygon.WithHeavyThreshold(1e9), polygon.WithHeavyFunc(byPoints)}, [2]bool{true, true}},
+		{"nil оставляет порог", []polygon.Option{polygon.WithHeavyThreshold(1e9), polygon.WithHeavyFunc(nil)}, [2]bool{false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := polygon.NewFetcher(append(tt.options, client)...)
+			for idx, want := range tt.want {
+				fetched, err := f.Fetch(context.Background(), idx, server.URL+"/polygon/"+strconv.Itoa(idx))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if fetched.Heavy != want {
+					t.Errorf("многоугольник %d: тяжелый = %v, ожидалось %v", idx, fetched.Heavy, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package polygon
+
+import "fmt"
+
+// Порог веса тяжелого многоугольника по умолчанию
+const DefaultHeavyThreshold = 100
+
+// HeavyFunc решает, считать ли многоугольник тяжелым, по уже посчитанным
+// суммарному весу и площади. Вызывается одновременно из нескольких
+// воркеров, поэтому функция должна быть безопасной для конкурентного использования
+type HeavyFunc func(p *Polygon, sumWeight float64, area float64) bool
+
+// Величины встроенных критериев HeavyBy
+const (
+	HeavyByWeight = "weight"
+	HeavyByArea   = "area"
+	HeavyByPoints = "points"
+)
+
+// HeavyBy возвращает встроенный критерий: величина name не меньше порога
+func HeavyBy(name string, threshold float64) (HeavyFunc, error) {
+	switch name {
+	case HeavyByWeight:
+		return WeightAtLeast(threshold), nil
+	case HeavyByArea:
+		return func(_ *Polygon, _, area float64) bool { return area >= threshold }, nil
+	case HeavyByPoints:
+		return func(p *Polygon, _, _ float64) bool { return float64(len(p.Points)) >= threshold }, nil
+	}
+	return nil, fmt.Errorf("неизвестный критерий %q, доступны: %s, %s, %s", name, HeavyByWeight, HeavyByArea, HeavyByPoints)
+}
+
+// WeightAtLeast - критерий по умолчанию: суммарный вес не меньше порога
+func WeightAtLeast(threshold float64) HeavyFunc {
+	return func(_ *Polygon, sumWeight, _ float64) bool { return sumWeight >= threshold }
+}
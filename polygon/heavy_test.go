@@ -0,0 +1,37 @@
+package polygon
+
+import "testing"
+
+func TestHeavyBy(t *testing.T) {
+	// Вес 6, площадь 4, 4 точки
+	square := &Polygon{Points: []WeightedPoint{
+		{Point: Point{X: 0, Y: 0}, Weight: 3},
+		{Point: Point{X: 2, Y: 0}, Weight: 1},
+		{Point: Point{X: 2, Y: 2}, Weight: 1},
+		{Point: Point{X: 0, Y: 2}, Weight: 1},
+	}}
+	tests := []struct {
+		name      string
+		threshold float64
+		want      bool
+	}{
+		{HeavyByWeight, 6, true},
+		{HeavyByWeight, 6.5, false},
+		{HeavyByArea, 4, true},
+		{HeavyByArea, 5, false},
+		{HeavyByPoints, 4, true},
+		{HeavyByPoints, 5, false},
+	}
+	for _, tt := range tests {
+		heavy, err := HeavyBy(tt.name, tt.threshold)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := heavy(square, 6, square.Area()); got != tt.want {
+			t.Errorf("HeavyBy(%q, %g) = %v, ожидалось %v", tt.name, tt.threshold, got, tt.want)
+		}
+	}
+	if _, err := HeavyBy("perimeter", 1); err == nil {
+		t.Error("неизвестный критерий принят")
+	}
+}
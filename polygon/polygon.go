@@ -0,0 +1,89 @@
+// Package polygon содержит типы многоугольников, расчет их характеристик
+// и загрузку по HTTP. Пакет не зависит от флагов командной строки, поэтому
+// его можно использовать и тестировать вне утилиты
+package polygon
+
+import "math"
+
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type WeightedPoint struct {
+	Point
+	Weight float64 `json:"weight"`
+}
+
+type Polygon struct {
+	Points []WeightedPoint `json:"points"`
+}
+
+type Bbox struct {
+	X1 float64 `json:"x1"`
+	Y1 float64 `json:"y1"`
+	X2 float64 `json:"x2"`
+	Y2 float64 `json:"y2"`
+}
+
+// EmptyBbox возвращает bbox, объединение с которым не меняет другой bbox
+func EmptyBbox() Bbox {
+	return Bbox{X1: math.Inf(1), Y1: math.Inf(1), X2: math.Inf(-1), Y2: math.Inf(-1)}
+}
+
+// Union возвращает наименьший bbox, содержащий оба
+func (b Bbox) Union(other Bbox) Bbox {
+	return Bbox{
+		X1: min(b.X1, other.X1),
+		Y1: min(b.Y1, other.Y1),
+		X2: max(b.X2, other.X2),
+		Y2: max(b.Y2, other.Y2),
+	}
+}
+
+// Area многоугольника считается по формуле шнурования (Гаусса)
+func (p *Polygon) Area() float64 {
+	return math.Abs(p.SignedArea())
+}
+
+// SignedArea возвращает площадь со знаком: положительную при обходе
+// вершин против часовой стрелки (ось Y направлена вверх), отрицательную
+// по часовой стрелке
+func (p *Polygon) SignedArea() float64 {
+	n := len(p.Points)
+	if n < 3 {
+		return 0
+	}
+	var sum float64
+	for i := 0; i < n; i++ {
+		a, b := p.Points[i], p.Points[(i+1)%n]
+		sum += a.X*b.Y - b.X*a.Y
+	}
+	return sum / 2
+}
+
+// Perimeter возвращает длину границы с учетом ребра между последней
+// и первой точками
+func (p *Polygon) Perimeter() float64 {
+	if len(p.Points) < 2 {
+		return 0
+	}
+	return PathLength(p.Points) + SegmentLength(p.Points[len(p.Points)-1], p.Points[0])
+}
+
+func (p *Polygon) Vertices() []WeightedPoint {
+	return p.Points
+}
+
+// PathLength возвращает длину ломаной через точки в заданном порядке
+func PathLength(points []WeightedPoint) float64 {
+	var length float64
+	for i := 1; i < len(points); i++ {
+		length += SegmentLength(points[i-1], points[i])
+	}
+	return length
+}
+
+func SegmentLength(a, b WeightedPoint) float64 {
+	return math.Hypot(b.X-a.X, b.Y-a.Y)
+}
@@ -0,0 +1,48 @@
+package polygon
+
+import "context"
+
+// Stats - характеристики одного многоугольника
+type Stats struct {
+	Bbox Bbox
+	// Суммарный вес точек. Сумма до 1М весов в float32 заметно теряла
+	// точность, поэтому веса хранятся и складываются в float64
+	Weight float64
+	Area   float64
+	Points int
+}
+
+// ProcessPolygon считает bbox, суммарный вес и площадь многоугольника.
+// У пустого многоугольника все характеристики нулевые. Для больших
+// многоугольников (до 1М точек) контекст проверяется по ходу обхода,
+// и при его отмене возвращается ошибка контекста
+func ProcessPolygon(ctx context.Context, p *Polygon) (Stats, error) {
+	if len(p.Points) == 0 {
+		return Stats{}, nil
+	}
+
+	bbox := Bbox{
+		X1: p.Points[0].X,
+		Y1: p.Points[0].Y,
+		X2: p.Points[0].X,
+		Y2: p.Points[0].Y,
+	}
+	var weight float64
+	for i, pt := range p.Points {
+		if i%1000 == 0 && ctx.Err() != nil {
+			return Stats{}, ctx.Err()
+		}
+		weight += pt.Weight
+		bbox.X1 = min(bbox.X1, pt.X)
+		bbox.Y1 = min(bbox.Y1, pt.Y)
+		bbox.X2 = max(bbox.X2, pt.X)
+		bbox.Y2 = max(bbox.Y2, pt.Y)
+	}
+
+	return Stats{
+		Bbox:   bbox,
+		Weight: weight,
+		Area:   p.Area(),
+		Points: len(p.Points),
+	}, nil
+}
@@ -0,0 +1,33 @@
+package polygon
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// Сумма миллиона весов 0.1 в float32 отличалась бы от 100000 почти на 1%,
+// а вес 2^24+1 в float32 не представим вовсе
+func TestProcessPolygonWeightPrecision(t *testing.T) {
+	const n = 1_000_000
+	p := &Polygon{Points: make([]WeightedPoint, n)}
+	for i := range p.Points {
+		p.Points[i] = WeightedPoint{Point: Point{X: float64(i % 1000), Y: float64(i / 1000)}, Weight: 0.1}
+	}
+	stats, err := ProcessPolygon(context.Background(), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(stats.Weight-100000) > 1e-3 {
+		t.Errorf("вес %v, ожидалось 100000", stats.Weight)
+	}
+
+	p = &Polygon{Points: []WeightedPoint{
+		{Point: Point{X: 0, Y: 0}, Weight: 1 << 24},
+		{Point: Point{X: 1, Y: 0}, Weight: 1},
+		{Point: Point{X: 0, Y: 1}, Weight: 0.5},
+	}}
+	if stats, err = ProcessPolygon(context.Background(), p); err != nil || stats.Weight != 1<<24+1.5 {
+		t.Errorf("вес %v (%v), ожидалось %v", stats.Weight, err, 1<<24+1.5)
+	}
+}
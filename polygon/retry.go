@@ -0,0 +1,120 @@
+package polygon
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+// RetryKind - вид сбоя попытки, определяющий, можно ли ее повторить
+type RetryKind int
+
+const (
+	// Повтор бессмыслен: ответ 4xx, ошибка разбора, разомкнутый предохранитель
+	RetryNever RetryKind = iota
+	// Соединение не установлено, запрос не дошел до сервера - повтор безопасен
+	RetryConnect
+	// Запрос мог быть частично выполнен сервером: ответ 5xx, обрыв при чтении
+	RetryRequest
+)
+
+func (k RetryKind) String() string {
+	switch k {
+	case RetryConnect:
+		return "connect"
+	case RetryRequest:
+		return "request"
+	}
+	return "never"
+}
+
+// HTTPErrorKind различает сбой установки соединения и сбой уже отправленного
+// запроса. Сбоем соединения считаются только ошибки этапа dial (отказ в
+// соединении, ошибка DNS): при них запрос гарантированно не был отправлен
+func HTTPErrorKind(err error) RetryKind {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return RetryConnect
+	}
+	return RetryRequest
+}
+
+// StatusKind возвращает вид сбоя для неуспешного статуса ответа:
+// ответы 5xx повторяются, остальные нет
+func StatusKind(code int) RetryKind {
+	if code >= 500 {
+		return RetryRequest
+	}
+	return RetryNever
+}
+
+// IdempotentMethod сообщает, что запрос с этим методом можно повторить,
+// даже если сервер мог его частично выполнить
+func IdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// Retry вызывает attempt, пока тот сообщает о сбое, который можно повторить,
+// и повторы этого вида не исчерпаны. Сбои соединения и сбои уже отправленного
+// запроса считаются отдельно. Между попытками выдерживается задержка
+// retryBackoff, отмена контекста прекращает повторы. attempt получает номер
+// попытки, начиная с 1
+func Retry(ctx context.Context, connectRetries, requestRetries int, attempt func(n int) RetryKind) {
+	var connectFailures, requestFailures int
+	for n := 1; ; n++ {
+		switch attempt(n) {
+		case RetryConnect:
+			connectFailures++
+			if connectFailures > connectRetries {
+				return
+			}
+		case RetryRequest:
+			requestFailures++
+			if requestFailures > requestRetries {
+				return
+			}
+		default:
+			return
+		}
+		// Ожидание прерывается отменой контекста, чтобы не задерживать завершение
+		if ctx.Err() != nil || sleepContext(ctx, retryBackoff(n)) != nil {
+			return
+		}
+	}
+}
+
+// Границы задержки между повторами запросов
+const (
+	retryBaseDelay = 100 * time.Millisecond
+	retryMaxDelay  = 5 * time.Second
+)
+
+// retryBackoff возвращает задержку перед повтором после attempt-й неудачной
+// попытки: экспоненциальный рост от retryBaseDelay до retryMaxDelay со случайным
+// разбросом в пределах половины, чтобы воркеры не повторяли запросы синхронно
+func retryBackoff(attempt int) time.Duration {
+	d := retryMaxDelay
+	if attempt < 16 {
+		d = min(retryBaseDelay<<(attempt-1), retryMaxDelay)
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// sleepContext ждет d или отмены контекста
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,112 @@
+// Package polygontest содержит HTTP-сервер многоугольников для проверки
+// загрузки без настоящего сервиса, по образцу net/http/httptest.
+// Ответы детерминированы и зависят только от индекса в пути /polygon/{index},
+// поэтому утилиту можно направить на сервер через -url .../polygon/{index}
+package polygontest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"github.com/kscvrmn/tev_test/polygon"
+)
+
+// Case - вид ответа сервера для индекса
+type Case int
+
+const (
+	// Многоугольник Polygon(index)
+	Normal Case = iota
+	// Многоугольник без точек
+	Empty
+	// Ответ задерживается на Server.Delay или до отмены запроса клиентом
+	Slow
+	// Статус 500 без тела
+	ServerError
+	// Тело с обрезанным JSON
+	Malformed
+)
+
+// Задержка медленного ответа по умолчанию: заведомо больше таймаутов
+// в проверках, чтобы ответ выглядел зависшим
+const DefaultDelay = time.Minute
+
+// Server отдает многоугольники по пути /polygon/{index} и их число по /count
+type Server struct {
+	*httptest.Server
+	// Вид ответа по индексу, индексы без записи - Normal
+	Cases map[int]Case
+	// Задержка ответа для Slow
+	Delay time.Duration
+	// Число многоугольников, которое возвращает /count
+	Count int
+}
+
+// NewServer запускает сервер. Cases и Delay нельзя менять после запуска,
+// так как обработчики читают их из нескольких горутин
+func NewServer(count int, cases map[int]Case) *Server {
+	s := &Server{Cases: cases, Delay: DefaultDelay, Count: count}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /polygon/{index}", s.servePolygon)
+	mux.HandleFunc("GET /count", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strconv.Itoa(s.Count)))
+	})
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// PolygonURL возвращает шаблон адреса многоугольников для -url
+func (s *Server) PolygonURL() string {
+	return s.URL + "/polygon/{index}"
+}
+
+func (s *Server) servePolygon(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || index < 0 {
+		http.Error(w, "некорректный индекс", http.StatusBadRequest)
+		return
+	}
+
+	switch s.Cases[index] {
+	case Empty:
+		writeJSON(w, polygon.Polygon{Points: []polygon.WeightedPoint{}})
+	case Slow:
+		select {
+		case <-time.After(s.Delay):
+			writeJSON(w, Polygon(index))
+		case <-r.Context().Done():
+		}
+	case ServerError:
+		w.WriteHeader(http.StatusInternalServerError)
+	case Malformed:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"points": [{"x": 0, "y": 0, "weight":`))
+	default:
+		writeJSON(w, Polygon(index))
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// Polygon возвращает многоугольник, который сервер отдает для индекса:
+// квадрат 10x10, сдвинутый на 10*index по X. Суммарный вес четных
+// индексов 109 (тяжелый при пороге по умолчанию), нечетных - 9
+func Polygon(index int) polygon.Polygon {
+	x := float64(10 * index)
+	first := 4.0
+	if index%2 == 0 {
+		first = 104
+	}
+	return polygon.Polygon{Points: []polygon.WeightedPoint{
+		{Point: polygon.Point{X: x, Y: 0}, Weight: first},
+		{Point: polygon.Point{X: x + 10, Y: 0}, Weight: 2},
+		{Point: polygon.Point{X: x + 10, Y: 10}, Weight: 2},
+		{Point: polygon.Point{X: x, Y: 10}, Weight: 1},
+	}}
+}
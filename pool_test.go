@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/kscvrmn/tev_test/polygontest"
+)
+
+// По таймауту пул, подача индексов и сборщик должны завершиться, даже если
+// main уже выбрал ветку ctx.Done() и не читает результат из resCh
+func TestPoolNoLeakOnTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		readResult bool
+	}{
+		{"результат прочитан", true},
+		{"результат не читается", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+			const total = 20
+			// Зависшие ответы не дают пулу закончить работу до таймаута
+			server := polygontest.NewServer(total, map[int]polygontest.Case{
+				3: polygontest.Slow,
+				4: polygontest.Slow,
+				5: polygontest.Slow,
+			})
+			defer server.Close()
+			f := newTestFetcher(t, server.PolygonURL(), server.Client())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			// Буфер как в main: отправка не должна зависеть от читателя
+			resCh := make(chan Result, 1)
+			runPool(ctx, total, total, nil, resCh, f, collectOptions{}, newFetchLimiter(0, 0, 0), nil, newPauseGate())
+
+			if !tt.readResult {
+				<-ctx.Done()
+				return
+			}
+			select {
+			case result := <-resCh:
+				if !result.Partial || result.Processed >= total {
+					t.Errorf("ожидался частичный результат, получено partial=%v processed=%d", result.Partial, result.Processed)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("сборщик не прислал результат после таймаута")
+			}
+		})
+	}
+}
+
+// Хук PostProcess видит каждый результат до агрегации, и его изменения
+// попадают в итог как в пуле, так и в последовательном режиме
+func TestPostProcessHook(t *testing.T) {
+	const total = 8
+	server := polygontest.NewServer(total, nil)
+	defer server.Close()
+	f := newTestFetcher(t, server.PolygonURL(), server.Client())
+
+	var calls atomic.Int32
+	PostProcess = func(r *PolygonResult) {
+		calls.Add(1)
+		// Снимаем отметку с каждого четвертого тяжелого многоугольника
+		if r.index%4 == 0 {
+			r.isHeavy, r.heavy = false, nil
+		}
+	}
+	t.Cleanup(func() { PostProcess = nil })
+
+	run := map[string]func() Result{
+		"пул": func() Result {
+			resCh := make(chan Result, 1)
+			runPool(context.Background(), total, total, nil, resCh, f, collectOptions{}, newFetchLimiter(0, 0, 0), nil, newPauseGate())
+			return <-resCh
+		},
+		"последовательно": func() Result {
+			return runSequential(context.Background(), total, total, nil, f, collectOptions{}, newFetchLimiter(0, 0, 0))
+		},
+	}
+	for name, fn := range run {
+		t.Run(name, func(t *testing.T) {
+			calls.Store(0)
+			result := fn()
+			if n := calls.Load(); n != total {
+				t.Errorf("хук вызван %d раз, ожидалось %d", n, total)
+			}
+			var indices []int
+			for _, heavy := range result.HeavyPolygons {
+				indices = append(indices, heavy.Index)
+			}
+			slices.Sort(indices)
+			if !slices.Equal(indices, []int{2, 6}) {
+				t.Errorf("тяжелые %v, ожидались [2 6]", indices)
+			}
+		})
+	}
+}
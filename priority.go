@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Во сколько раз больше повторов получают приоритетные индексы
+const priorityRetryFactor = 3
+
+// Множество приоритетных индексов из -priority_indices
+type priorityIndices map[int]bool
+
+// parsePriorityIndices разбирает список индексов через запятую
+func parsePriorityIndices(s string) (priorityIndices, error) {
+	if s == "" {
+		return nil, nil
+	}
+	set := make(priorityIndices)
+	for _, part := range strings.Split(s, ",") {
+		idx, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || idx < 0 {
+			return nil, fmt.Errorf("некорректный индекс %q", part)
+		}
+		set[idx] = true
+	}
+	return set, nil
+}
+
+// retries возвращает число повторов для индекса. Приоритетный индекс
+// получает повторы и при base = 0, например с -max_retries 0
+func (p priorityIndices) retries(idx, base int) int {
+	if p[idx] {
+		return max(base, 1) * priorityRetryFactor
+	}
+	return base
+}
+
+// order возвращает индексы от 0 до total в порядке подачи воркерам:
+// сначала приоритетные, затем остальные, каждые по возрастанию
+func (p priorityIndices) order(total int) []int {
+	order := make([]int, 0, total)
+	for i := 0; i < total; i++ {
+		if p[i] {
+			order = append(order, i)
+		}
+	}
+	for i := 0; i < total; i++ {
+		if !p[i] {
+			order = append(order, i)
+		}
+	}
+	return order
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kscvrmn/tev_test/polygon"
+)
+
+func TestPriorityRetriesCount(t *testing.T) {
+	p := priorityIndices{1: true}
+	tests := []struct {
+		idx, base, want int
+	}{
+		{0, 0, 0},
+		{0, 2, 2},
+		// Без повторов по умолчанию приоритетный индекс все равно их получает
+		{1, 0, priorityRetryFactor},
+		{1, 2, 2 * priorityRetryFactor},
+	}
+	for _, tt := range tests {
+		if got := p.retries(tt.idx, tt.base); got != tt.want {
+			t.Errorf("retries(%d, %d) = %d, ожидалось %d", tt.idx, tt.base, got, tt.want)
+		}
+	}
+}
+
+// failingServer отвечает 500 на все запросы и считает их по индексу из пути
+func failingServer(t *testing.T) (*httptest.Server, func(idx string) int) {
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[strings.TrimPrefix(r.URL.Path, "/")]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+	return server, func(idx string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests[idx]
+	}
+}
+
+// Приоритетный индекс при тех же сбоях повторяется в priorityRetryFactor раз
+// чаще, а с -max_retries 0 получает priorityRetryFactor повторов
+func TestPriorityRetries(t *testing.T) {
+	for _, retries := range []int{0, 1} {
+		t.Run(fmt.Sprint("retries=", retries), func(t *testing.T) {
+			server, requests := failingServer(t)
+			f := newTestFetcher(t, server.URL+"/{index}", server.Client(), polygon.WithRetries(retries))
+			f.opts.priority = priorityIndices{1: true}
+
+			for idx := range 2 {
+				if r := f.fetchAndProcessPolygon(context.Background(), idx); r.err == nil {
+					t.Fatalf("многоугольник %d загружен без ошибки", idx)
+				}
+			}
+			if got, want := requests("0"), retries+1; got != want {
+				t.Errorf("обычный индекс: %d запросов, ожидалось %d", got, want)
+			}
+			if got, want := requests("1"), max(retries, 1)*priorityRetryFactor+1; got != want {
+				t.Errorf("приоритетный индекс: %d запросов, ожидалось %d", got, want)
+			}
+		})
+	}
+}
+
+// Запрос POST, дошедший до сервера, не повторяется и для приоритетного индекса
+func TestPriorityRetriesPost(t *testing.T) {
+	server, requests := failingServer(t)
+	f := newTestFetcher(t, server.URL+"/{index}", server.Client(),
+		polygon.WithRetries(2), polygon.WithMethod(http.MethodPost))
+	f.opts.priority = priorityIndices{0: true}
+
+	if r := f.fetchAndProcessPolygon(context.Background(), 0); r.err == nil {
+		t.Fatal("многоугольник загружен без ошибки")
+	}
+	if got := requests("0"); got != 1 {
+		t.Errorf("%d запросов, ожидался 1", got)
+	}
+}
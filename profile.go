@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+)
+
+// Состояние профилирования всего запуска. Профили нужно записать и при
+// завершении через os.Exit, где отложенные вызовы не выполняются,
+// поэтому остановка доступна глобально через stopProfiling
+var profiling struct {
+	once    sync.Once
+	cpuFile *os.File
+	memPath string
+}
+
+// startProfiling включает профилирование CPU и запоминает, куда записать
+// профиль памяти. Пустые пути отключают соответствующий профиль
+func startProfiling(cpuPath, memPath string) error {
+	profiling.memPath = memPath
+	if cpuPath == "" {
+		return nil
+	}
+	f, err := os.Create(cpuPath)
+	if err != nil {
+		return fmt.Errorf("ошибка создания %s: %v", cpuPath, err)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		return fmt.Errorf("ошибка запуска профилирования CPU: %v", err)
+	}
+	profiling.cpuFile = f
+	return nil
+}
+
+// stopProfiling дописывает профили. Повторные вызовы ничего не делают
+func stopProfiling() {
+	profiling.once.Do(func() {
+		if profiling.cpuFile != nil {
+			pprof.StopCPUProfile()
+			profiling.cpuFile.Close()
+		}
+		if profiling.memPath != "" {
+			if err := writeHeapProfile(profiling.memPath); err != nil {
+				slog.Error("Ошибка записи профиля памяти", "error", err)
+			}
+		}
+	})
+}
+
+func writeHeapProfile(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// Сборка мусора перед снимком дает актуальную статистику живых объектов
+	runtime.GC()
+	return pprof.WriteHeapProfile(f)
+}
+
+// exit завершает программу, предварительно записав профили
+func exit(code int) {
+	stopProfiling()
+	os.Exit(code)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// Профили записываются и при завершении через exit, где отложенные
+// вызовы не выполняются. Завершение проверяется в отдельном процессе
+func TestProfilesWrittenOnExit(t *testing.T) {
+	if dir := os.Getenv("TEV_TEST_PROFILE_DIR"); dir != "" {
+		if err := startProfiling(filepath.Join(dir, "cpu.pprof"), filepath.Join(dir, "mem.pprof")); err != nil {
+			t.Fatal(err)
+		}
+		for i := range 1000 {
+			ConvexHull(ringPoints(regularPolygon(100+i%10, 10).Points))
+		}
+		exit(3)
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestProfilesWrittenOnExit$")
+	cmd.Env = append(os.Environ(), "TEV_TEST_PROFILE_DIR="+dir)
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("ожидался код выхода 3, получено %v\n%s", err, out)
+	}
+	// Профиль pprof - сжатый gzip protobuf
+	for _, name := range []string{"cpu.pprof", "mem.pprof"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("профиль не записан: %v", err)
+			continue
+		}
+		if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+			t.Errorf("%s не похож на профиль pprof: %d байт", name, len(data))
+		}
+	}
+}
+
+func TestStartProfilingError(t *testing.T) {
+	if err := startProfiling(filepath.Join(t.TempDir(), "missing", "cpu.pprof"), ""); err == nil {
+		t.Error("нет ошибки для недоступного пути профиля CPU")
+	}
+}
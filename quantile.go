@@ -0,0 +1,138 @@
+package main
+
+import (
+	"math"
+	"slices"
+)
+
+// p2Quantile - потоковая оценка квантиля алгоритмом P² (Jain, Chlamtac, 1985).
+// Хранит всего пять маркеров независимо от числа наблюдений, поэтому подходит
+// для запусков с миллионами многоугольников, где держать все веса в памяти нельзя
+type p2Quantile struct {
+	p     float64
+	count int
+	q     [5]float64 // высоты маркеров
+	n     [5]float64 // фактические позиции маркеров
+	np    [5]float64 // желаемые позиции маркеров
+	dn    [5]float64 // приращения желаемых позиций
+}
+
+func newP2Quantile(p float64) *p2Quantile {
+	return &p2Quantile{
+		p:  p,
+		dn: [5]float64{0, p / 2, p, (1 + p) / 2, 1},
+	}
+}
+
+// Add учитывает очередное наблюдение
+func (e *p2Quantile) Add(x float64) {
+	// Первые пять наблюдений просто запоминаем, они станут начальными маркерами
+	if e.count < 5 {
+		e.q[e.count] = x
+		e.count++
+		if e.count == 5 {
+			slices.Sort(e.q[:])
+			for i := range e.n {
+				e.n[i] = float64(i)
+			}
+			e.np = [5]float64{0, 2 * e.p, 4 * e.p, 2 + 2*e.p, 4}
+		}
+		return
+	}
+	e.count++
+
+	// Находим ячейку k, в которую попадает наблюдение, расширяя крайние маркеры
+	var k int
+	switch {
+	case x < e.q[0]:
+		e.q[0] = x
+		k = 0
+	case x >= e.q[4]:
+		e.q[4] = x
+		k = 3
+	default:
+		for k = 0; k < 3 && x >= e.q[k+1]; k++ {
+		}
+	}
+
+	for i := k + 1; i < 5; i++ {
+		e.n[i]++
+	}
+	for i := range e.np {
+		e.np[i] += e.dn[i]
+	}
+
+	// Корректируем внутренние маркеры, если они отстали от желаемых позиций
+	for i := 1; i <= 3; i++ {
+		d := e.np[i] - e.n[i]
+		if (d >= 1 && e.n[i+1]-e.n[i] > 1) || (d <= -1 && e.n[i-1]-e.n[i] < -1) {
+			d = math.Copysign(1, d)
+			qp := e.parabolic(i, d)
+			if e.q[i-1] < qp && qp < e.q[i+1] {
+				e.q[i] = qp
+			} else {
+				e.q[i] = e.linear(i, d)
+			}
+			e.n[i] += d
+		}
+	}
+}
+
+func (e *p2Quantile) parabolic(i int, d float64) float64 {
+	return e.q[i] + d/(e.n[i+1]-e.n[i-1])*
+		((e.n[i]-e.n[i-1]+d)*(e.q[i+1]-e.q[i])/(e.n[i+1]-e.n[i])+
+			(e.n[i+1]-e.n[i]-d)*(e.q[i]-e.q[i-1])/(e.n[i]-e.n[i-1]))
+}
+
+func (e *p2Quantile) linear(i int, d float64) float64 {
+	j := i + int(d)
+	return e.q[i] + d*(e.q[j]-e.q[i])/(e.n[j]-e.n[i])
+}
+
+// Value возвращает текущую оценку квантиля. Пока наблюдений меньше пяти,
+// квантиль считается точно по отсортированным значениям
+func (e *p2Quantile) Value() float64 {
+	if e.count == 0 {
+		return 0
+	}
+	if e.count < 5 {
+		sorted := slices.Clone(e.q[:e.count])
+		slices.Sort(sorted)
+		return sorted[int(math.Round(e.p*float64(e.count-1)))]
+	}
+	return e.q[2]
+}
+
+// Перцентили весов многоугольников в выходном JSON
+type WeightPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// weightPercentiles собирает потоковые оценки нужных перцентилей
+type weightPercentiles struct {
+	p50, p90, p99 *p2Quantile
+}
+
+func newWeightPercentiles() *weightPercentiles {
+	return &weightPercentiles{
+		p50: newP2Quantile(0.5),
+		p90: newP2Quantile(0.9),
+		p99: newP2Quantile(0.99),
+	}
+}
+
+func (w *weightPercentiles) Add(weight float64) {
+	w.p50.Add(weight)
+	w.p90.Add(weight)
+	w.p99.Add(weight)
+}
+
+func (w *weightPercentiles) Result() *WeightPercentiles {
+	return &WeightPercentiles{
+		P50: w.p50.Value(),
+		P90: w.p90.Value(),
+		P99: w.p99.Value(),
+	}
+}
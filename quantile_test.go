@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// exactPercentile - точный перцентиль по отсортированным значениям
+func exactPercentile(sorted []float64, p float64) float64 {
+	return sorted[int(math.Round(p*float64(len(sorted)-1)))]
+}
+
+// Оценки P² на известных распределениях близки к точным перцентилям
+func TestWeightPercentiles(t *testing.T) {
+	rng := rand.New(rand.NewPCG(3, 4))
+	tests := []struct {
+		name string
+		next func() float64
+	}{
+		{"равномерное", func() float64 { return rng.Float64() * 1000 }},
+		{"нормальное", func() float64 { return 500 + 100*rng.NormFloat64() }},
+		{"экспоненциальное", func() float64 { return 100 * rng.ExpFloat64() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimator := newWeightPercentiles()
+			values := make([]float64, 20000)
+			for i := range values {
+				values[i] = tt.next()
+				estimator.Add(values[i])
+			}
+			slices.Sort(values)
+			// Допуск - доля разброса значений между p1 и p99
+			tolerance := 0.02 * (exactPercentile(values, 0.99) - exactPercentile(values, 0.01))
+
+			got := estimator.Result()
+			for _, q := range []struct {
+				p   float64
+				got float64
+			}{{0.5, got.P50}, {0.9, got.P90}, {0.99, got.P99}} {
+				if want := exactPercentile(values, q.p); math.Abs(q.got-want) > tolerance {
+					t.Errorf("p%g: оценка %g, точное значение %g, допуск %g", q.p*100, q.got, want, tolerance)
+				}
+			}
+		})
+	}
+}
+
+// Пока наблюдений меньше пяти, квантиль считается точно
+func TestP2QuantileFewValues(t *testing.T) {
+	e := newP2Quantile(0.5)
+	if e.Value() != 0 {
+		t.Errorf("без наблюдений %g, ожидалось 0", e.Value())
+	}
+	for _, v := range []float64{30, 10, 20} {
+		e.Add(v)
+	}
+	if e.Value() != 20 {
+		t.Errorf("медиана %g, ожидалось 20", e.Value())
+	}
+}
+
+// Перцентили выводятся только с -weight_percentiles
+func TestCollectWeightPercentiles(t *testing.T) {
+	var results []PolygonResult
+	for i := range 101 {
+		results = append(results, processed(t, i, square(0, 0, 1, float64(i)), processOptions{}))
+	}
+	result := collect(t, collectOptions{weightPercentiles: true}, results...)
+	if p := result.WeightPercentiles; p == nil || math.Abs(p.P50-50) > 2 || math.Abs(p.P90-90) > 2 || math.Abs(p.P99-99) > 2 {
+		t.Errorf("перцентили %+v, ожидалось около 50, 90, 99", p)
+	}
+	if result := collect(t, collectOptions{}, results[:3]...); result.WeightPercentiles != nil {
+		t.Errorf("перцентили посчитаны без -weight_percentiles: %+v", result.WeightPercentiles)
+	}
+}
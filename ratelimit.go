@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Доля общего лимита запросов, зарезервированная за приоритетными индексами
+const priorityRateShare = 0.25
+
+// Иерархическое ограничение частоты запросов: общий для всех воркеров бюджет
+// плюс необязательный собственный лимит каждого воркера.
+// Часть общего бюджета может быть зарезервирована за приоритетными индексами:
+// обычные запросы ее не расходуют, а приоритетные берут токен из резерва
+// или из общей части, смотря что освободится раньше. Суммарная частота
+// не превышает общего лимита.
+// Нулевое значение лимита означает отсутствие ограничения
+type fetchLimiter struct {
+	global       *rate.Limiter
+	reserved     *rate.Limiter
+	perWorkerRPS float64
+}
+
+// newFetchLimiter создает ограничитель. priorityShare - доля globalRPS,
+// зарезервированная за приоритетными запросами, 0 - без резерва
+func newFetchLimiter(globalRPS, perWorkerRPS, priorityShare float64) *fetchLimiter {
+	if globalRPS <= 0 || priorityShare <= 0 {
+		return &fetchLimiter{global: newLimiter(globalRPS), perWorkerRPS: perWorkerRPS}
+	}
+	return &fetchLimiter{
+		global:       newLimiter(globalRPS * (1 - priorityShare)),
+		reserved:     newLimiter(globalRPS * priorityShare),
+		perWorkerRPS: perWorkerRPS,
+	}
+}
+
+// forWorker создает ограничитель для одного воркера, разделяющий общий бюджет
+func (l *fetchLimiter) forWorker() *workerLimiter {
+	return &workerLimiter{
+		global:   l.global,
+		reserved: l.reserved,
+		own:      newLimiter(l.perWorkerRPS),
+	}
+}
+
+type workerLimiter struct {
+	global   *rate.Limiter
+	reserved *rate.Limiter
+	own      *rate.Limiter
+}
+
+// Wait ждет разрешения обоих уровней, поэтому фактически действует более
+// строгий из них. Сначала ждем собственный лимит, чтобы не занимать общий
+// токен, пока воркер еще не может им воспользоваться.
+// Приоритетный запрос ограничивается только общим бюджетом с учетом резерва
+func (w *workerLimiter) Wait(ctx context.Context, priority bool) error {
+	if w.own != nil && !priority {
+		if err := w.own.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if priority && w.reserved != nil {
+		return waitEarliest(ctx, w.reserved, w.global)
+	}
+	if w.global != nil {
+		if err := w.global.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitEarliest ждет токен того из лимитеров, который освободится раньше,
+// и возвращает в другой занятый у него токен. Как и rate.Limiter.Wait,
+// сразу возвращает ошибку, если токена не дождаться до отмены контекста
+func waitEarliest(ctx context.Context, a, b *rate.Limiter) error {
+	// Отмена уже наступившего резервирования токен не возвращает,
+	// поэтому свободный токен берется без резервирования
+	if a.Allow() || b.Allow() {
+		return nil
+	}
+	r, other := a.Reserve(), b.Reserve()
+	if other.Delay() < r.Delay() {
+		r, other = other, r
+	}
+	other.Cancel()
+
+	delay := r.Delay()
+	if delay == 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		r.Cancel()
+		return fmt.Errorf("ожидание лимита запросов превысило бы срок контекста")
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
+
+func newLimiter(rps float64) *rate.Limiter {
+	if rps <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(rps), 1)
+}
+
+// Наибольшая порция, читаемая за одно ожидание лимита пропускной способности.
+// Небольшие порции делают поток равномерным вместо рывков раз в секунду
+const maxBandwidthBurst = 64 << 10
+
+// newBandwidthLimiter создает общий для всех воркеров ограничитель
+// пропускной способности в байтах в секунду, nil - без ограничения
+func newBandwidthLimiter(bytesPerSec int) *rate.Limiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSec), min(bytesPerSec, maxBandwidthBurst))
+}
+
+// throttledReader ограничивает скорость чтения общим лимитером.
+// Ожидание прерывается при отмене контекста
+type throttledReader struct {
+	ctx     context.Context
+	r       io.Reader
+	limiter *rate.Limiter
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	// WaitN не допускает запросов больше burst, поэтому читаем порциями
+	if burst := t.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if werr := t.limiter.WaitN(t.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
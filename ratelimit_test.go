@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runLimited запускает воркеров с ограничителями limits на время window
+// и возвращает число разрешенных запросов каждого
+func runLimited(limits *fetchLimiter, workers int, window time.Duration) []int {
+	ctx, cancel := context.WithTimeout(context.Background(), window)
+	defer cancel()
+	counts := make([]int, workers)
+	var wg sync.WaitGroup
+	for i := range workers {
+		wg.Add(1)
+		limiter := limits.forWorker()
+		go func() {
+			defer wg.Done()
+			for limiter.Wait(ctx, false) == nil {
+				counts[i]++
+			}
+		}()
+	}
+	wg.Wait()
+	return counts
+}
+
+// Два воркера вместе не превышают общего лимита -rate_limit
+func TestFetchLimiterSharedBudget(t *testing.T) {
+	const rps, window = 20, 500 * time.Millisecond
+	counts := runLimited(newFetchLimiter(rps, 0, 0), 2, window)
+	total := counts[0] + counts[1]
+	// Один токен доступен сразу, остальные копятся со скоростью rps
+	if limit := 1 + int(rps*window.Seconds()); total > limit {
+		t.Errorf("%d запросов за %v, лимит %d", total, window, limit)
+	}
+	if total < 5 {
+		t.Errorf("всего %d запросов за %v: лимит слишком строгий", total, window)
+	}
+}
+
+// Собственный лимит -per_worker_rate действует на каждого воркера отдельно
+// и строже свободного общего
+func TestFetchLimiterPerWorker(t *testing.T) {
+	const rps, window = 10, 500 * time.Millisecond
+	for i, count := range runLimited(newFetchLimiter(1000, rps, 0), 2, window) {
+		if limit := 1 + int(rps*window.Seconds()); count > limit || count < 3 {
+			t.Errorf("воркер %d: %d запросов за %v, ожидалось от 3 до %d", i, count, window, limit)
+		}
+	}
+}
+
+// Обычные запросы выбирают весь доступный им общий бюджет. Без резерва
+// приоритетный запрос ждет наравне с ними, с резервом проходит сразу
+func TestPriorityRateShare(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		share         float64
+		priorityFirst bool
+	}{
+		{"без резерва", 0, false},
+		{"с резервом", 0.5, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// 2 запроса в секунду: следующий токен общей части не раньше чем через 500 мс
+			limiter := newFetchLimiter(2, 0, tt.share).forWorker()
+			if err := limiter.Wait(context.Background(), false); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			if err := limiter.Wait(ctx, false); err == nil {
+				t.Error("обычный запрос прошел сверх общего лимита")
+			}
+			err := limiter.Wait(ctx, true)
+			if tt.priorityFirst && err != nil {
+				t.Errorf("приоритетный запрос не получил зарезервированный токен: %v", err)
+			}
+			if !tt.priorityFirst && err == nil {
+				t.Error("приоритетный запрос прошел сверх общего лимита")
+			}
+			// Резерв тоже ограничен: второй приоритетный запрос подряд ждет
+			if err := limiter.Wait(ctx, true); err == nil {
+				t.Error("второй приоритетный запрос прошел сверх общего лимита")
+			}
+		})
+	}
+}
+
+// Приоритетный запрос берет токен общей части, если резерв исчерпан,
+// а общая часть свободна
+func TestPriorityUsesSharedBudget(t *testing.T) {
+	limiter := newFetchLimiter(2, 0, 0.5).forWorker()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	for i := range 2 {
+		if err := limiter.Wait(ctx, true); err != nil {
+			t.Fatalf("приоритетный запрос %d: %v", i+1, err)
+		}
+	}
+	if err := limiter.Wait(ctx, true); err == nil {
+		t.Error("третий приоритетный запрос прошел сверх общего лимита")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Заголовок, в котором идентификатор запуска передается серверу
+const runIDHeader = "X-Run-ID"
+
+// Заголовок с оставшимся до дедлайна временем в миллисекундах
+const deadlineHeader = "X-Deadline-Ms"
+
+// Ключ контекста для идентификатора запуска. Отдельный тип исключает
+// пересечение с ключами других пакетов
+type runIDKey struct{}
+
+// newRunID генерирует случайный идентификатор запуска для корреляции логов
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+func withRunID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, id)
+}
+
+// runIDFrom возвращает идентификатор запуска из контекста или пустую строку
+func runIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
+
+// setDeadlineHeader передает серверу оставшееся время из дедлайна контекста запроса.
+// Без дедлайна заголовок не добавляется. Уже истекший дедлайн передается как 0
+func setDeadlineHeader(req *http.Request) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return
+	}
+	remaining := max(time.Until(deadline).Milliseconds(), 0)
+	req.Header.Set(deadlineHeader, strconv.FormatInt(remaining, 10))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kscvrmn/tev_test/polygon"
+)
+
+// captureLog направляет общий логгер в буфер в формате JSON на время теста
+func captureLog(t *testing.T, level slog.Level) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	old := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level})))
+	t.Cleanup(func() { slog.SetDefault(old) })
+	return &buf
+}
+
+// Один и тот же идентификатор запуска попадает в заголовок запроса к серверу
+// и в строки лога, записанные при загрузке
+func TestRunIDInHeaderAndLog(t *testing.T) {
+	headers := make(chan string, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Get(runIDHeader)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	logs := captureLog(t, slog.LevelDebug)
+
+	id := newRunID()
+	ctx := withRunID(context.Background(), id)
+	// Повтор после ответа 500 пишет в лог строку уровня debug
+	f := newTestFetcher(t, server.URL, server.Client(), polygon.WithRetries(1))
+	if r := f.fetchAndProcessPolygon(ctx, 0); r.err == nil {
+		t.Fatal("ответ 500 принят")
+	}
+
+	if got := <-headers; got != id {
+		t.Errorf("заголовок %s = %q, ожидался %q", runIDHeader, got, id)
+	}
+	var logged bool
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry struct {
+			Msg   string `json:"msg"`
+			RunID string `json:"run_id"`
+		}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("строка лога %q: %v", line, err)
+		}
+		if entry.RunID != id {
+			t.Errorf("строка лога %q без run_id %q", line, id)
+		}
+		logged = true
+	}
+	if !logged {
+		t.Error("загрузка не записала в лог ни одной строки")
+	}
+}
+
+func TestNewRunIDUnique(t *testing.T) {
+	a, b := newRunID(), newRunID()
+	if a == b || len(a) != 16 {
+		t.Errorf("идентификаторы %q и %q", a, b)
+	}
+}
+
+// Сервер получает оставшееся до таймаута запроса время в X-Deadline-Ms
+func TestDeadlineHeader(t *testing.T) {
+	headers := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Get(deadlineHeader)
+		w.Write([]byte(`{"points":[]}`))
+	}))
+	defer server.Close()
+
+	f := newTestFetcher(t, server.URL, server.Client(), polygon.WithTimeout(2*time.Second))
+	if r := f.fetchAndProcessPolygon(context.Background(), 0); r.err != nil {
+		t.Fatal(r.err)
+	}
+	ms, err := strconv.Atoi(<-headers)
+	if err != nil || ms <= 1000 || ms > 2000 {
+		t.Errorf("%s = %d (%v), ожидалось чуть меньше 2000", deadlineHeader, ms, err)
+	}
+}
+
+func TestSetDeadlineHeader(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		want    string
+		present bool
+	}{
+		{"без дедлайна", context.Background(), "", false},
+		// Истекший дедлайн не превращается в отрицательное значение
+		{"истекший", expired, "0", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(tt.ctx, http.MethodGet, "http://example.com", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			setDeadlineHeader(req)
+			got, present := req.Header[deadlineHeader]
+			if present != tt.present || (present && got[0] != tt.want) {
+				t.Errorf("заголовок %v, ожидался %q", got, tt.want)
+			}
+		})
+	}
+}
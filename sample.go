@@ -0,0 +1,47 @@
+package main
+
+import (
+	"cmp"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// sampleWeighted выбирает k тяжелых многоугольников взвешенной выборкой
+// без возвращения (резервуарный алгоритм A-Res Эфраимидиса-Спиракиса):
+// каждому многоугольнику назначается ключ u^(1/w), остаются k наибольших.
+// Порядок поступления в параллельном режиме случаен, поэтому перед выборкой
+// многоугольники упорядочиваются по индексу - при одинаковом зерне выбор
+// воспроизводим. Результат возвращается в порядке индексов
+func sampleWeighted(heavies []*HeavyPolygon, k int, seed uint64) []*HeavyPolygon {
+	if k >= len(heavies) {
+		return heavies
+	}
+	sorted := slices.Clone(heavies)
+	slices.SortFunc(sorted, func(a, b *HeavyPolygon) int { return cmp.Compare(a.Index, b.Index) })
+
+	type keyed struct {
+		heavy *HeavyPolygon
+		key   float64
+	}
+	rnd := rand.New(rand.NewPCG(seed, 0))
+	keys := make([]keyed, len(sorted))
+	for i, heavy := range sorted {
+		// Ключ сравнивается в логарифмической форме log(u)/w, чтобы не терять
+		// точность при больших весах. Многоугольники с неположительным весом
+		// выбираются в последнюю очередь
+		key := math.Inf(-1)
+		if heavy.weight > 0 {
+			key = math.Log(1-rnd.Float64()) / heavy.weight
+		}
+		keys[i] = keyed{heavy, key}
+	}
+	slices.SortStableFunc(keys, func(a, b keyed) int { return cmp.Compare(b.key, a.key) })
+
+	sample := make([]*HeavyPolygon, k)
+	for i := range sample {
+		sample[i] = keys[i].heavy
+	}
+	slices.SortFunc(sample, func(a, b *HeavyPolygon) int { return cmp.Compare(a.Index, b.Index) })
+	return sample
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func weightedHeavies(weights ...float64) []*HeavyPolygon {
+	heavies := make([]*HeavyPolygon, len(weights))
+	for i, w := range weights {
+		heavies[i] = &HeavyPolygon{Index: i, weight: w}
+	}
+	return heavies
+}
+
+func sampleIndices(sample []*HeavyPolygon) []int {
+	indices := make([]int, len(sample))
+	for i, heavy := range sample {
+		indices[i] = heavy.Index
+	}
+	return indices
+}
+
+// Выборка воспроизводима при том же зерне независимо от порядка
+// поступления и возвращается в порядке индексов без повторов
+func TestSampleWeightedDeterministic(t *testing.T) {
+	heavies := weightedHeavies(5, 1, 8, 3, 2, 7, 4, 6)
+	want := sampleIndices(sampleWeighted(heavies, 3, 42))
+	if len(want) != 3 || !slices.IsSorted(want) || len(slices.Compact(slices.Clone(want))) != 3 {
+		t.Fatalf("выборка %v, ожидались 3 разных индекса по возрастанию", want)
+	}
+
+	shuffled := slices.Clone(heavies)
+	rand.New(rand.NewPCG(1, 2)).Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+	if got := sampleIndices(sampleWeighted(shuffled, 3, 42)); !slices.Equal(got, want) {
+		t.Errorf("после перемешивания выбраны %v, ожидались %v", got, want)
+	}
+
+	if got := sampleWeighted(heavies, len(heavies), 42); len(got) != len(heavies) {
+		t.Errorf("при k равном числу многоугольников оставлено %d", len(got))
+	}
+}
+
+// Вероятность выбора пропорциональна весу, а многоугольники
+// с нулевым весом выбираются последними
+func TestSampleWeightedDistribution(t *testing.T) {
+	heavies := weightedHeavies(1000, 1, 1, 1, 1, 1, 1, 1, 1, 1, 0)
+	const runs = 2000
+	picked := 0
+	for seed := range uint64(runs) {
+		sample := sampleWeighted(heavies, 1, seed)
+		if sample[0].Index == 0 {
+			picked++
+		}
+		if slices.Contains(sampleIndices(sampleWeighted(heavies, 10, seed)), 10) {
+			t.Fatalf("зерно %d: выбран многоугольник с нулевым весом", seed)
+		}
+	}
+	// Ожидаемая доля 1000/1009, около 99%
+	if share := float64(picked) / runs; share < 0.97 {
+		t.Errorf("самый тяжелый выбран в %.1f%% запусков, ожидалось около 99%%", share*100)
+	}
+}
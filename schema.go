@@ -0,0 +1,129 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+)
+
+// Схема JSON-вывода Result. Новое поле вывода без описания в схеме
+// не пройдет проверку -validate_output, поэтому схему нужно обновлять вместе с Result
+//
+//go:embed result.schema.json
+var resultSchemaJSON []byte
+
+// jsonSchema - подмножество JSON Schema, достаточное для описания Result:
+// type, required, properties, additionalProperties, items, enum и minimum
+type jsonSchema struct {
+	Type                 string                 `json:"type"`
+	Required             []string               `json:"required"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []any                  `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+}
+
+// validateResult сериализует результат так же, как при выводе,
+// и проверяет его по встроенной схеме
+func validateResult(result Result) error {
+	var schema jsonSchema
+	if err := json.Unmarshal(resultSchemaJSON, &schema); err != nil {
+		return fmt.Errorf("некорректная встроенная схема: %v", err)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации JSON: %v", err)
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("ошибка разбора JSON: %v", err)
+	}
+	return schema.validate("$", doc)
+}
+
+// validate проверяет значение v, разобранное encoding/json, по схеме.
+// path - путь к значению для сообщения об ошибке, например $.heavy_polygons[0].area
+func (s *jsonSchema) validate(path string, v any) error {
+	if s.Type != "" && !matchesType(s.Type, v) {
+		return fmt.Errorf("%s: ожидается %s, получено %s", path, s.Type, jsonTypeName(v))
+	}
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, v) {
+		return fmt.Errorf("%s: значение %v не входит в %v", path, v, s.Enum)
+	}
+	if n, ok := v.(float64); ok && s.Minimum != nil && n < *s.Minimum {
+		return fmt.Errorf("%s: значение %g меньше минимума %g", path, n, *s.Minimum)
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s: нет обязательного поля %q", path, name)
+			}
+		}
+		// Поля обходятся по имени, чтобы при нескольких нарушениях
+		// сообщение об ошибке не менялось от запуска к запуску
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s: поле %q не описано в схеме", path, name)
+				}
+				continue
+			}
+			if err := prop.validate(path+"."+name, v[name]); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(typ string, v any) bool {
+	switch typ {
+	case "integer":
+		n, ok := v.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	default:
+		return jsonTypeName(v) == typ
+	}
+}
+
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Результат с необязательными блоками многоугольников и агрегата проходит
+// проверку: поле, добавленное в них без описания в схеме, сломает этот тест
+func TestValidateResultAllFields(t *testing.T) {
+	popts := processOptions{
+		diameter: true, minWidth: true, complexity: true, circleBoxRatio: true,
+		turningSignature: true, weightGradient: true, longestChord: true,
+		skeleton: true, localHeatmap: 2,
+	}
+	copts := collectOptions{
+		perPolygon: true, heavyThreshold: 100, weightPercentiles: true, coverageGrid: 4,
+		heatmapGrid: 2, adjacency: true, overlaps: true, mergeRadius: 100,
+		containsPoint: &Point{X: 1, Y: 1}, prevBboxes: map[int]Bbox{0: {X1: 0, Y1: 0, X2: 10, Y2: 10}},
+		sizeClasses: sizeClassThresholds{10, 1000},
+	}
+	result := collect(t, copts,
+		processed(t, 0, square(0, 0, 10, 150), popts),
+		processed(t, 1, square(10, 0, 10, 200), popts),
+		processed(t, 2, square(30, 0, 10, 8), popts),
+		processed(t, 4, square(5, 5, 10, 150), popts),
+		PolygonResult{index: 3, err: errBreakerOpen, statusCode: 503},
+	)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	result.Meta = newMeta(withRunID(context.Background(), "run-1"), start, start.Add(time.Second))
+
+	if err := validateResult(result); err != nil {
+		t.Error(err)
+	}
+	if err := validateResult(collect(t, collectOptions{})); err != nil {
+		t.Errorf("пустой результат: %v", err)
+	}
+}
+
+func TestJSONSchemaValidate(t *testing.T) {
+	const schemaSrc = `{
+		"type": "object",
+		"required": ["name"],
+		"additionalProperties": false,
+		"properties": {
+			"name": {"type": "string"},
+			"kind": {"enum": ["a", "b"]},
+			"items": {"type": "array", "items": {"type": "object", "properties": {"n": {"type": "integer", "minimum": 0}}}}
+		}
+	}`
+	var schema jsonSchema
+	if err := json.Unmarshal([]byte(schemaSrc), &schema); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		doc string
+		err string
+	}{
+		{`{"name":"x","kind":"a","items":[{"n":1},{"n":0}]}`, ""},
+		{`[]`, "$: ожидается object, получено array"},
+		{`{"kind":"a"}`, `$: нет обязательного поля "name"`},
+		{`{"name":1}`, "$.name: ожидается string, получено number"},
+		{`{"name":"x","kind":"c"}`, "$.kind: значение c не входит в [a b]"},
+		{`{"name":"x","extra":true}`, `$: поле "extra" не описано в схеме`},
+		{`{"name":"x","items":[{"n":1},{"n":1.5}]}`, "$.items[1].n: ожидается integer, получено number"},
+		{`{"name":"x","items":[{"n":-1}]}`, "$.items[0].n: значение -1 меньше минимума 0"},
+		// При нескольких нарушениях сообщение о первом по имени поле
+		{`{"name":1,"kind":"c"}`, "$.kind:"},
+	}
+	for _, tt := range tests {
+		var doc any
+		if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+			t.Fatal(err)
+		}
+		err := schema.validate("$", doc)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.err)) {
+			t.Errorf("%s: ошибка %v, ожидалась %q", tt.doc, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/kscvrmn/tev_test/polygontest"
+)
+
+// Последовательный режим служит эталоном для пула: на одном наборе
+// многоугольников оба должны давать одинаковый Result
+func TestSequentialMatchesPool(t *testing.T) {
+	const total = 24
+	server := polygontest.NewServer(total, map[int]polygontest.Case{
+		3:  polygontest.Empty,
+		8:  polygontest.ServerError,
+		15: polygontest.Malformed,
+	})
+	defer server.Close()
+	f := newTestFetcher(t, server.PolygonURL(), server.Client())
+	opts := collectOptions{perPolygon: true, heavyThreshold: 100}
+	ctx := context.Background()
+
+	sequential := runSequential(ctx, total, total, nil, f, opts, newFetchLimiter(0, 0, 0))
+
+	resCh := make(chan Result, 1)
+	runPool(ctx, total, total, nil, resCh, f, opts, newFetchLimiter(0, 0, 0), nil, newPauseGate())
+	concurrent := <-resCh
+	// Пул собирает тяжелые многоугольники в порядке готовности
+	slices.SortFunc(concurrent.HeavyPolygons, func(x, y *HeavyPolygon) int { return cmp.Compare(x.Index, y.Index) })
+
+	if sequential.Processed != total-2 || sequential.ErrorCount != 2 {
+		t.Errorf("последовательный режим: обработано %d, ошибок %d", sequential.Processed, sequential.ErrorCount)
+	}
+	want, err := json.Marshal(sequential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(concurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("результаты расходятся:\nпул:                 %s\nпоследовательно: %s", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/kscvrmn/tev_test/polygon"
+)
+
+// Значения поля "type" во входных данных
+const (
+	shapeTypePolygon  = "polygon"
+	shapeTypePolyline = "polyline"
+)
+
+// Shape - общая часть замкнутых и незамкнутых фигур из входного потока
+type Shape interface {
+	// Area возвращает площадь фигуры, для незамкнутых фигур 0
+	Area() float64
+	// Perimeter возвращает длину границы; у замкнутых фигур учитывается
+	// ребро между последней и первой точками
+	Perimeter() float64
+	// Vertices возвращает точки фигуры в исходном порядке
+	Vertices() []WeightedPoint
+}
+
+// Polyline - незамкнутая ломаная
+type Polyline struct {
+	Points []WeightedPoint `json:"points"`
+}
+
+// У ломаной нет внутренней области
+func (l *Polyline) Area() float64 {
+	return 0
+}
+
+func (l *Polyline) Perimeter() float64 {
+	return polygon.PathLength(l.Points)
+}
+
+func (l *Polyline) Vertices() []WeightedPoint {
+	return l.Points
+}
+
+// newShape создает фигуру по значению поля "type".
+// Отсутствующий тип означает многоугольник для совместимости со старыми серверами
+func newShape(shapeType string, points []WeightedPoint) (Shape, error) {
+	switch shapeType {
+	case "", shapeTypePolygon:
+		return &Polygon{Points: points}, nil
+	case shapeTypePolyline:
+		return &Polyline{Points: points}, nil
+	}
+	return nil, fmt.Errorf("неизвестный тип фигуры %q", shapeType)
+}
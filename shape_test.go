@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Смешанный поток: вид фигуры определяется полем "type", у ломаной
+// нет площади, а ее длина считается без замыкающего ребра
+func TestDecodeMixedShapes(t *testing.T) {
+	const square = `[{"x":0,"y":0,"weight":1},{"x":3,"y":0,"weight":1},{"x":3,"y":3,"weight":1},{"x":0,"y":3,"weight":1}]`
+	tests := []struct {
+		name      string
+		body      string
+		polyline  bool
+		area      float64
+		perimeter float64
+	}{
+		{"многоугольник", `{"type":"polygon","points":` + square + `}`, false, 9, 12},
+		{"без типа", `{"points":` + square + `}`, false, 9, 12},
+		{"ломаная", `{"type":"polyline","points":` + square + `}`, true, 0, 9},
+		{"ломаная из двух точек", `{"type":"polyline","points":[{"x":0,"y":0,"weight":1},{"x":3,"y":4,"weight":1}]}`, true, 0, 5},
+		{"пустая ломаная", `{"type":"polyline","points":[]}`, true, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shape, err := decodeShape(contentTypeJSON, []byte(tt.body), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := shape.(*Polyline); ok != tt.polyline {
+				t.Errorf("тип %T", shape)
+			}
+			if shape.Area() != tt.area || math.Abs(shape.Perimeter()-tt.perimeter) > 1e-9 {
+				t.Errorf("площадь %g, периметр %g, ожидалось %g и %g", shape.Area(), shape.Perimeter(), tt.area, tt.perimeter)
+			}
+		})
+	}
+
+	if _, err := decodeShape(contentTypeJSON, []byte(`{"type":"circle","points":[]}`), false); err == nil {
+		t.Error("неизвестный тип фигуры принят")
+	}
+}
+
+// MessagePack передает тип фигуры так же, как JSON
+func TestDecodeMsgpackPolyline(t *testing.T) {
+	body, err := msgpack.Marshal(map[string]any{
+		"type":   shapeTypePolyline,
+		"points": []map[string]any{{"x": 0, "y": 0, "weight": 1}, {"x": 6, "y": 8, "weight": 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shape, err := decodeShape(contentTypeMsgpack, body, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := shape.(*Polyline); !ok || shape.Perimeter() != 10 {
+		t.Errorf("фигура %T с длиной %g, ожидалась ломаная длиной 10", shape, shape.Perimeter())
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+// SimplifyRDP упрощает ломаную алгоритмом Рамера-Дугласа-Пекера: точки,
+// отстоящие от упрощенного контура не больше чем на epsilon, отбрасываются.
+// Первая и последняя точки сохраняются всегда, оставшиеся точки сохраняют
+// свои веса. Отрезки обрабатываются через явный стек, чтобы многоугольник
+// из миллиона точек не приводил к глубокой рекурсии. Возвращается новый срез
+func SimplifyRDP(points []WeightedPoint, epsilon float64) []WeightedPoint {
+	n := len(points)
+	if n < 3 || epsilon <= 0 {
+		return points
+	}
+
+	keep := make([]bool, n)
+	keep[0], keep[n-1] = true, true
+	kept := 2
+	stack := [][2]int{{0, n - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		// Расстояние до отрезка, а не до прямой: у замкнутого кольца
+		// первая и последняя точки совпадают и прямая через них не определена
+		a, b := points[first].Point, points[last].Point
+		farthest, maxDist := -1, epsilon
+		for i := first + 1; i < last; i++ {
+			if d := pointSegmentDistance(points[i].X, points[i].Y, a, b); d > maxDist {
+				farthest, maxDist = i, d
+			}
+		}
+		if farthest >= 0 {
+			keep[farthest] = true
+			kept++
+			stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+		}
+	}
+
+	simplified := make([]WeightedPoint, 0, kept)
+	for i, p := range points {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
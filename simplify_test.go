@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSimplifyRDP(t *testing.T) {
+	line := []WeightedPoint{wp(0, 0, 1), wp(1, 0.1, 2), wp(2, -0.1, 3), wp(3, 5, 4), wp(4, 6, 5), wp(5, 7, 6), wp(10, 0, 7)}
+	tests := []struct {
+		name    string
+		points  []WeightedPoint
+		epsilon float64
+		want    []WeightedPoint
+	}{
+		{"нулевой допуск", line, 0, line},
+		{"отрицательный допуск", line, -1, line},
+		{"две точки", line[:2], 10, line[:2]},
+		{"шум меньше допуска", line, 0.5, []WeightedPoint{wp(0, 0, 1), wp(2, -0.1, 3), wp(3, 5, 4), wp(5, 7, 6), wp(10, 0, 7)}},
+		{"все точки в допуске", line, 100, []WeightedPoint{wp(0, 0, 1), wp(10, 0, 7)}},
+		{"коллинеарная точка отбрасывается", line, 0.01, append(append([]WeightedPoint{}, line[:4]...), line[5:]...)},
+		{"точка ровно на допуске", []WeightedPoint{wp(0, 0, 1), wp(1, 1, 2), wp(2, 0, 3)}, 1, []WeightedPoint{wp(0, 0, 1), wp(2, 0, 3)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SimplifyRDP(tt.points, tt.epsilon); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SimplifyRDP(eps=%g) = %v, ожидалось %v", tt.epsilon, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimplifyRDPClosedRing(t *testing.T) {
+	// У замкнутого кольца концы совпадают: расстояние считается до точки,
+	// поэтому дальние вершины квадрата сохраняются
+	ring := []WeightedPoint{wp(0, 0, 1), wp(4, 0.01, 1), wp(8, 0, 1), wp(8, 8, 1), wp(0, 8, 1), wp(0, 0, 1)}
+	got := SimplifyRDP(ring, 0.1)
+	want := []WeightedPoint{wp(0, 0, 1), wp(8, 0, 1), wp(8, 8, 1), wp(0, 8, 1), wp(0, 0, 1)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SimplifyRDP(кольцо) = %v, ожидалось %v", got, want)
+	}
+}
+
+func TestSimplifyRDPLarge(t *testing.T) {
+	// Миллион точек на окружности не должен упираться в глубину рекурсии
+	const n = 1_000_000
+	points := make([]WeightedPoint, n)
+	for i := range points {
+		angle := 2 * math.Pi * float64(i) / n
+		points[i] = wp(1000*math.Cos(angle), 1000*math.Sin(angle), 1)
+	}
+	got := SimplifyRDP(points, 1)
+	if len(got) < 3 || len(got) >= n/100 {
+		t.Fatalf("после упрощения %d точек из %d", len(got), n)
+	}
+	if got[0] != points[0] || got[len(got)-1] != points[n-1] {
+		t.Errorf("концы не сохранены: %v, %v", got[0], got[len(got)-1])
+	}
+}
+
+func TestProcessPolygonSimplify(t *testing.T) {
+	points := []WeightedPoint{wp(0, 0, 30), wp(5, 0.01, 30), wp(10, 0, 30), wp(10, 10, 30), wp(0, 10, 30)}
+	r := processed(t, 0, &Polygon{Points: points}, processOptions{simplifyEpsilon: 0.1})
+	if r.heavy == nil {
+		t.Fatal("многоугольник весом 150 не тяжелый")
+	}
+	if got := len(r.heavy.Points); got != 4 {
+		t.Errorf("после упрощения %d точек, ожидалось 4", got)
+	}
+	// Вес и площадь считаются по исходным точкам
+	if r.heavy.weight != 150 {
+		t.Errorf("вес %g, ожидалось 150", r.heavy.weight)
+	}
+	if math.Abs(r.heavy.Area-99.95) > 1e-9 {
+		t.Errorf("площадь %g, ожидалось 99.95", r.heavy.Area)
+	}
+	if len(points) != 5 {
+		t.Errorf("исходный многоугольник изменен: %d точек", len(points))
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Классы размера тяжелых многоугольников
+const (
+	sizeClassSmall  = "small"
+	sizeClassMedium = "medium"
+	sizeClassLarge  = "large"
+)
+
+// Границы классов размера по площади по умолчанию
+const defaultSizeClassThresholds = "1000,100000"
+
+// sizeClassThresholds - границы площади между классами: меньше первой -
+// small, от первой (включительно) до второй - medium, от второй - large
+type sizeClassThresholds [2]float64
+
+func parseSizeClassThresholds(s string) (sizeClassThresholds, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return sizeClassThresholds{}, fmt.Errorf("ожидается две границы через запятую, получено %q", s)
+	}
+
+	var t sizeClassThresholds
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return sizeClassThresholds{}, fmt.Errorf("некорректная граница %q: %v", part, err)
+		}
+		t[i] = v
+	}
+	if t[0] < 0 || t[0] > t[1] {
+		return sizeClassThresholds{}, fmt.Errorf("границы должны быть неотрицательными и неубывающими: %q", s)
+	}
+	return t, nil
+}
+
+func (t sizeClassThresholds) classify(area float64) string {
+	switch {
+	case area < t[0]:
+		return sizeClassSmall
+	case area < t[1]:
+		return sizeClassMedium
+	}
+	return sizeClassLarge
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestParseSizeClassThresholds(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    sizeClassThresholds
+		wantErr bool
+	}{
+		{defaultSizeClassThresholds, sizeClassThresholds{1000, 100000}, false},
+		{" 10 , 20 ", sizeClassThresholds{10, 20}, false},
+		// Совпадающие границы допустимы: класса medium тогда нет
+		{"5,5", sizeClassThresholds{5, 5}, false},
+		{"10", sizeClassThresholds{}, true},
+		{"1,2,3", sizeClassThresholds{}, true},
+		{"a,2", sizeClassThresholds{}, true},
+		{"20,10", sizeClassThresholds{}, true},
+		{"-1,10", sizeClassThresholds{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSizeClassThresholds(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseSizeClassThresholds(%q) = %v, %v, ожидалось %v, ошибка %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+// Нижняя граница класса включается в него
+func TestSizeClassClassify(t *testing.T) {
+	bounds := sizeClassThresholds{100, 1000}
+	tests := []struct {
+		area float64
+		want string
+	}{
+		{0, sizeClassSmall},
+		{99.9, sizeClassSmall},
+		{100, sizeClassMedium},
+		{999, sizeClassMedium},
+		{1000, sizeClassLarge},
+		{1e9, sizeClassLarge},
+	}
+	for _, tt := range tests {
+		if got := bounds.classify(tt.area); got != tt.want {
+			t.Errorf("classify(%g) = %s, ожидалось %s", tt.area, got, tt.want)
+		}
+	}
+
+	// Тяжелый многоугольник получает класс по своей площади при агрегации
+	result := collect(t, collectOptions{sizeClasses: bounds}, processed(t, 0, square(0, 0, 10, 150), processOptions{}))
+	if got := result.HeavyPolygons[0].SizeClass; got != sizeClassMedium {
+		t.Errorf("класс квадрата площадью 100: %q, ожидался %q", got, sizeClassMedium)
+	}
+}
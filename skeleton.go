@@ -0,0 +1,146 @@
+package main
+
+import "math"
+
+// Ограничения сложности: расстояние до границы считается для каждой ячейки
+// по всем ребрам, поэтому многоугольник сначала упрощается, а сетка ограничена
+const (
+	maxSkeletonVertices = 200
+	skeletonGridSize    = 64
+)
+
+// Точка скелета с дробными координатами (центр ячейки сетки)
+type SkeletonPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Ребро скелета в выходном JSON
+type SkeletonEdge struct {
+	A SkeletonPoint `json:"a"`
+	B SkeletonPoint `json:"b"`
+}
+
+// Skeleton приближает срединную ось многоугольника по сетке расстояний:
+// внутренние ячейки, расстояние от центра которых до границы является
+// локальным максимумом хотя бы в одном из четырех направлений, считаются
+// гребнем, а соседние ячейки гребня соединяются ребрами.
+// Для прямоугольника получается центральная линия с "усами" к углам,
+// как у прямолинейного скелета. Многоугольники с большим числом вершин
+// предварительно прореживаются до maxSkeletonVertices
+func Skeleton(p *Polygon) []SkeletonEdge {
+	pts := simplifyRing(ringPoints(p.Points), maxSkeletonVertices)
+	if len(pts) < 3 {
+		return nil
+	}
+
+	minX, minY, maxX, maxY := pts[0].X, pts[0].Y, pts[0].X, pts[0].Y
+	for _, pt := range pts[1:] {
+		minX, minY = min(minX, pt.X), min(minY, pt.Y)
+		maxX, maxY = max(maxX, pt.X), max(maxY, pt.Y)
+	}
+	side := float64(max(maxX-minX, maxY-minY))
+	if side == 0 {
+		return nil
+	}
+
+	// Квадратные ячейки, не больше skeletonGridSize по длинной стороне
+	cell := side / skeletonGridSize
+	cols := int(math.Ceil(float64(maxX-minX)/cell)) + 1
+	rows := int(math.Ceil(float64(maxY-minY)/cell)) + 1
+	center := func(c, r int) (float64, float64) {
+		return float64(minX) + (float64(c)+0.5)*cell, float64(minY) + (float64(r)+0.5)*cell
+	}
+
+	// Расстояние до границы для внутренних ячеек, -1 - ячейка снаружи
+	dist := make([]float64, cols*rows)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			x, y := center(c, r)
+			if !pointInRing(x, y, pts) {
+				dist[r*cols+c] = -1
+				continue
+			}
+			dist[r*cols+c] = distanceToRing(x, y, pts)
+		}
+	}
+	at := func(c, r int) float64 {
+		if c < 0 || r < 0 || c >= cols || r >= rows {
+			return -1
+		}
+		return dist[r*cols+c]
+	}
+
+	// Гребень: не меньше обоих соседей по направлению и строго больше хотя бы одного.
+	// Строгость отсекает плато вдоль самой оси
+	directions := [][2]int{{1, 0}, {0, 1}, {1, 1}, {1, -1}}
+	ridge := make([]bool, cols*rows)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			d := at(c, r)
+			if d <= 0 {
+				continue
+			}
+			for _, dir := range directions {
+				a, b := at(c-dir[0], r-dir[1]), at(c+dir[0], r+dir[1])
+				if d >= a && d >= b && (d > a || d > b) {
+					ridge[r*cols+c] = true
+					break
+				}
+			}
+		}
+	}
+
+	// Каждая пара соседей учитывается один раз: вправо, вниз и по двум диагоналям
+	var edges []SkeletonEdge
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			if !ridge[r*cols+c] {
+				continue
+			}
+			ax, ay := center(c, r)
+			for _, dir := range directions {
+				nc, nr := c+dir[0], r+dir[1]
+				if nc < 0 || nr < 0 || nc >= cols || nr >= rows || !ridge[nr*cols+nc] {
+					continue
+				}
+				bx, by := center(nc, nr)
+				edges = append(edges, SkeletonEdge{A: SkeletonPoint{ax, ay}, B: SkeletonPoint{bx, by}})
+			}
+		}
+	}
+	return edges
+}
+
+// simplifyRing прореживает кольцо до limit равномерно выбранных вершин
+func simplifyRing(pts []Point, limit int) []Point {
+	if len(pts) <= limit {
+		return pts
+	}
+	out := make([]Point, limit)
+	for i := range out {
+		out[i] = pts[i*len(pts)/limit]
+	}
+	return out
+}
+
+// distanceToRing возвращает расстояние от точки до ближайшего ребра кольца
+func distanceToRing(x, y float64, pts []Point) float64 {
+	best := math.Inf(1)
+	n := len(pts)
+	for i := 0; i < n; i++ {
+		best = math.Min(best, pointSegmentDistance(x, y, pts[i], pts[(i+1)%n]))
+	}
+	return best
+}
+
+// pointSegmentDistance возвращает расстояние от точки до отрезка ab
+func pointSegmentDistance(x, y float64, a, b Point) float64 {
+	ax, ay := a.X, a.Y
+	dx, dy := b.X-ax, b.Y-ay
+	t := 0.0
+	if l2 := dx*dx + dy*dy; l2 > 0 {
+		t = math.Max(0, math.Min(1, ((x-ax)*dx+(y-ay)*dy)/l2))
+	}
+	return math.Hypot(x-(ax+t*dx), y-(ay+t*dy))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// Скелет вытянутого прямоугольника проходит по его средней линии:
+// все точки внутри, ребра соединяют соседние ячейки сетки
+func TestSkeletonRectangle(t *testing.T) {
+	rect := polygonOf([2]float64{0, 0}, [2]float64{64, 0}, [2]float64{64, 8}, [2]float64{0, 8})
+	edges := Skeleton(rect)
+	if len(edges) == 0 {
+		t.Fatal("скелет прямоугольника пуст")
+	}
+
+	const cell = 1.0 // 64 / skeletonGridSize
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	for _, e := range edges {
+		for _, p := range []SkeletonPoint{e.A, e.B} {
+			if p.X <= 0 || p.X >= 64 || p.Y <= 0 || p.Y >= 8 {
+				t.Fatalf("точка скелета %+v вне прямоугольника", p)
+			}
+			if math.Abs(p.Y-4) <= cell {
+				minX, maxX = min(minX, p.X), max(maxX, p.X)
+			}
+		}
+		if d := math.Hypot(e.A.X-e.B.X, e.A.Y-e.B.Y); d > cell*math.Sqrt2+1e-9 {
+			t.Errorf("ребро %+v длиной %g соединяет не соседние ячейки", e, d)
+		}
+	}
+	// Средняя линия тянется от усов у левой стороны до усов у правой
+	if minX > 8 || maxX < 56 {
+		t.Errorf("средняя линия покрывает x от %g до %g, ожидалось не меньше чем от 8 до 56", minX, maxX)
+	}
+}
+
+func TestSkeletonDegenerate(t *testing.T) {
+	for name, p := range map[string]*Polygon{
+		"две точки":  polygonOf([2]float64{0, 0}, [2]float64{1, 1}),
+		"одна точка": polygonOf([2]float64{1, 1}, [2]float64{1, 1}, [2]float64{1, 1}),
+		"пустой":     {},
+	} {
+		if edges := Skeleton(p); edges != nil {
+			t.Errorf("%s: скелет %v, ожидался пустой", name, edges)
+		}
+	}
+}
+
+func TestPointSegmentDistance(t *testing.T) {
+	a, b := Point{X: 0, Y: 0}, Point{X: 4, Y: 0}
+	tests := []struct {
+		x, y, want float64
+	}{
+		{2, 3, 3},
+		// За концами отрезка расстояние считается до ближайшего конца
+		{-3, 4, 5},
+		{7, -4, 5},
+		{1, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := pointSegmentDistance(tt.x, tt.y, a, b); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("расстояние от (%g, %g) = %g, ожидалось %g", tt.x, tt.y, got, tt.want)
+		}
+	}
+	if got := pointSegmentDistance(3, 4, a, a); got != 5 {
+		t.Errorf("расстояние до вырожденного отрезка %g, ожидалось 5", got)
+	}
+}
+
+// Прореживание оставляет равномерно выбранные вершины в исходном порядке
+func TestSimplifyRing(t *testing.T) {
+	ring := ringPoints(regularPolygon(10, 1).Points)
+	if got := simplifyRing(ring, 20); len(got) != 10 {
+		t.Errorf("кольцо в пределах лимита изменено: %d вершин", len(got))
+	}
+	got := simplifyRing(ring, 5)
+	if len(got) != 5 {
+		t.Fatalf("%d вершин, ожидалось 5", len(got))
+	}
+	for i, p := range got {
+		if p != ring[2*i] {
+			t.Errorf("вершина %d = %+v, ожидалась %+v", i, p, ring[2*i])
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Результат одного многоугольника: строка потокового вывода в формате JSON Lines
+// и элемент списка polygons с -per_polygon.
+// Каждая строка самодостаточна и может обрабатываться независимо от остальных
+type PolygonSummary struct {
+	Index  int     `json:"index"`
+	Weight float64 `json:"weight"`
+	Heavy  bool    `json:"heavy"`
+	Bbox   Bbox    `json:"bbox"`
+	// Центр масс многоугольника, например для размещения подписи
+	Centroid Point `json:"centroid"`
+}
+
+func summarize(pr PolygonResult) PolygonSummary {
+	return PolygonSummary{
+		Index:    pr.index,
+		Weight:   pr.weight,
+		Heavy:    pr.isHeavy,
+		Bbox:     pr.localBbox,
+		Centroid: pr.centroid,
+	}
+}
+
+// streamWriter пишет результаты по мере поступления, сбрасывая буфер
+// после каждой строки, чтобы прерванный запуск терял как можно меньше
+type streamWriter struct {
+	w *bufio.Writer
+}
+
+func newStreamWriter(w io.Writer) *streamWriter {
+	return &streamWriter{w: bufio.NewWriter(w)}
+}
+
+func (s *streamWriter) Write(pr PolygonResult) error {
+	line, err := json.Marshal(summarize(pr))
+	if err != nil {
+		return err
+	}
+	s.w.Write(line)
+	s.w.WriteByte('\n')
+	return s.w.Flush()
+}
+
+// openStreamOutput открывает файл потокового вывода. В режиме дозаписи
+// существующие строки читаются, чтобы не обрабатывать их индексы повторно,
+// а новые строки добавляются в конец файла (O_APPEND).
+// Возвращает множество уже записанных индексов
+func openStreamOutput(name string, appendMode bool) (*os.File, map[int]bool, error) {
+	if !appendMode {
+		f, err := os.Create(name)
+		return f, nil, err
+	}
+
+	done, endsWithNewline, err := readStreamIndices(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Если прошлый запуск прервался посреди строки, начинаем с новой,
+	// чтобы не склеить обрывок со следующей записью
+	if !endsWithNewline {
+		if _, err := f.WriteString("\n"); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+	}
+	return f, done, nil
+}
+
+// readStreamIndices собирает индексы из существующего файла потокового вывода.
+// Нечитаемые строки (например, оборванная последняя) пропускаются,
+// их многоугольники будут обработаны заново
+func readStreamIndices(name string) (map[int]bool, bool, error) {
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return map[int]bool{}, true, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("ошибка чтения %s: %v", name, err)
+	}
+
+	done := make(map[int]bool)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var rec struct {
+			Index *int `json:"index"`
+		}
+		if json.Unmarshal(line, &rec) == nil && rec.Index != nil {
+			done[*rec.Index] = true
+		}
+	}
+	return done, len(data) == 0 || data[len(data)-1] == '\n', nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kscvrmn/tev_test/polygontest"
+)
+
+// Продолжение прерванного запуска с -append_output: записанные индексы
+// не загружаются повторно, оборванная строка не склеивается с новой,
+// и в итоге каждый многоугольник записан в файл ровно один раз
+func TestStreamOutputAppendResume(t *testing.T) {
+	const total = 6
+	server := polygontest.NewServer(total, nil)
+	defer server.Close()
+	name := filepath.Join(t.TempDir(), "stream.jsonl")
+
+	// Прошлый запуск успел записать два многоугольника и прервался посреди третьего
+	f, _, err := openStreamOutput(name, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := newStreamWriter(f)
+	for _, idx := range []int{0, 3} {
+		if err := stream.Write(processed(t, idx, square(0, 0, 1, 1), processOptions{})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.WriteString(`{"index":4,"wei`)
+	f.Close()
+
+	f, done, err := openStreamOutput(name, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 2 || !done[0] || !done[3] {
+		t.Fatalf("записанные индексы %v, ожидались 0 и 3", done)
+	}
+	transport := &countingTransport{RoundTripper: server.Client().Transport}
+	fetcher := newTestFetcher(t, server.PolygonURL(), &http.Client{Transport: transport})
+	result := runSequential(context.Background(), total, total-len(done), done, fetcher,
+		collectOptions{stream: newStreamWriter(f)}, newFetchLimiter(0, 0, 0))
+	f.Close()
+
+	if n := transport.requests.Load(); n != total-2 || result.Processed != total-2 {
+		t.Errorf("запросов %d, обработано %d, ожидалось %d", n, result.Processed, total-2)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indices []int
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var summary PolygonSummary
+		if json.Unmarshal(line, &summary) != nil {
+			// Допустим только обрывок прошлого запуска
+			if string(line) != `{"index":4,"wei` {
+				t.Errorf("нечитаемая строка %q", line)
+			}
+			continue
+		}
+		indices = append(indices, summary.Index)
+	}
+	slices.Sort(indices)
+	if !slices.Equal(indices, []int{0, 1, 2, 3, 4, 5}) {
+		t.Errorf("в файле индексы %v, ожидался каждый из 0..5 по одному разу", indices)
+	}
+}
+
+// Без -append_output файл перезаписывается, а не дописывается
+func TestStreamOutputTruncates(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "stream.jsonl")
+	if err := os.WriteFile(name, []byte(`{"index":7}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, done, err := openStreamOutput(name, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if done != nil {
+		t.Errorf("без дозаписи индексы не читаются, получено %v", done)
+	}
+	if data, _ := os.ReadFile(name); len(data) != 0 {
+		t.Errorf("файл не очищен: %q", data)
+	}
+}
+
+// С -stdio_stream каждая строка stdout - JSON результата многоугольника,
+// последняя - итоговая сводка, а в stderr идут строки JSON с ошибками.
+// main запускается в отдельном процессе, так как разбирает флаги и пишет в stdout
+func TestStdioStream(t *testing.T) {
+	if url := os.Getenv("TEV_TEST_STDIO_URL"); url != "" {
+		os.Args = []string{"tev_test", "-url", url, "-polygons_num", "4", "-workers", "2", "-max_retries", "0", "-stdio_stream"}
+		main()
+		exit(0)
+	}
+
+	server := polygontest.NewServer(4, map[int]polygontest.Case{2: polygontest.ServerError})
+	defer server.Close()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestStdioStream$")
+	cmd.Env = append(os.Environ(), "TEV_TEST_STDIO_URL="+server.PolygonURL())
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("%v\n%s", err, stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("в stdout %d строк, ожидалось 3 результата и сводка:\n%s", len(lines), stdout.String())
+	}
+	var indices []int
+	for _, line := range lines[:3] {
+		var summary PolygonSummary
+		if err := json.Unmarshal([]byte(line), &summary); err != nil {
+			t.Fatalf("строка результата %q: %v", line, err)
+		}
+		indices = append(indices, summary.Index)
+	}
+	slices.Sort(indices)
+	if !slices.Equal(indices, []int{0, 1, 3}) {
+		t.Errorf("результаты для %v, ожидались 0, 1 и 3", indices)
+	}
+	var result Result
+	if err := json.Unmarshal([]byte(lines[3]), &result); err != nil || result.Processed != 3 || result.ErrorCount != 1 {
+		t.Errorf("сводка %q: %v", lines[3], err)
+	}
+
+	// Лог тоже пишется в stderr, но строками JSON, и от записей об ошибках
+	// отличается полем msg
+	var failed []int
+	for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+		var record struct {
+			errorRecord
+			Msg *string `json:"msg"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("строка stderr %q не JSON: %v", line, err)
+		}
+		if record.Msg == nil {
+			failed = append(failed, record.Index)
+		}
+	}
+	if !slices.Equal(failed, []int{2}) {
+		t.Errorf("в stderr ошибки для %v, ожидалась только для 2:\n%s", failed, stderr.String())
+	}
+}
+
+// -stdio_stream занимает stdout и stderr, поэтому файлы потокового вывода
+// и ошибок с ним не сочетаются
+func TestStdioStreamConflicts(t *testing.T) {
+	if os.Getenv("TEV_TEST_STDIO_CONFLICT") == "1" {
+		os.Args = []string{"tev_test", "-stdio_stream", "-errors_file", filepath.Join(os.TempDir(), "errors.jsonl")}
+		main()
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestStdioStreamConflicts$")
+	cmd.Env = append(os.Environ(), "TEV_TEST_STDIO_CONFLICT=1")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || !bytes.Contains(out, []byte("-stdio_stream несовместим")) {
+		t.Errorf("ожидалось завершение с ошибкой, получено %v\n%s", err, out)
+	}
+}
+
+// -stream выводит в stdout те же строки результатов и сводку последней строкой,
+// а ошибки остаются в обычном логе stderr
+func TestStream(t *testing.T) {
+	if url := os.Getenv("TEV_TEST_STREAM_URL"); url != "" {
+		os.Args = []string{"tev_test", "-url", url, "-polygons_num", "3", "-workers", "2", "-max_retries", "0", "-stream"}
+		main()
+		exit(0)
+	}
+
+	server := polygontest.NewServer(3, map[int]polygontest.Case{1: polygontest.ServerError})
+	defer server.Close()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestStream$")
+	cmd.Env = append(os.Environ(), "TEV_TEST_STREAM_URL="+server.PolygonURL())
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("%v\n%s", err, stderr.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("в stdout %d строк, ожидалось 2 результата и сводка:\n%s", len(lines), stdout.String())
+	}
+	var indices []int
+	for _, line := range lines[:2] {
+		var summary PolygonSummary
+		if err := json.Unmarshal([]byte(line), &summary); err != nil {
+			t.Fatalf("строка результата %q: %v", line, err)
+		}
+		if summary.Weight != 109 || !summary.Heavy || summary.Bbox == (Bbox{}) {
+			t.Errorf("строка результата без полей многоугольника: %q", line)
+		}
+		indices = append(indices, summary.Index)
+	}
+	slices.Sort(indices)
+	if !slices.Equal(indices, []int{0, 2}) {
+		t.Errorf("результаты для %v, ожидались 0 и 2", indices)
+	}
+	var result Result
+	if err := json.Unmarshal([]byte(lines[2]), &result); err != nil || result.Processed != 2 || result.ErrorCount != 1 {
+		t.Errorf("сводка %q: %v", lines[2], err)
+	}
+	if stderr.Len() == 0 {
+		t.Error("ошибка многоугольника 1 не попала в лог")
+	}
+}
+
+// -stream и -stream_output пишут строки результатов одним писателем
+func TestStreamConflicts(t *testing.T) {
+	if os.Getenv("TEV_TEST_STREAM_CONFLICT") == "1" {
+		os.Args = []string{"tev_test", "-stream", "-stream_output", filepath.Join(os.TempDir(), "stream.jsonl")}
+		main()
+		return
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestStreamConflicts$")
+	cmd.Env = append(os.Environ(), "TEV_TEST_STREAM_CONFLICT=1")
+	out, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || !bytes.Contains(out, []byte("-stream несовместим")) {
+		t.Errorf("ожидалось завершение с ошибкой, получено %v\n%s", err, out)
+	}
+}
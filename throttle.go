@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Число последних ответов, по которым оценивается доля 5xx
+const throttleWindow = 20
+
+// Настройки -adaptive_workers.
+// Доля 5xx в окне, при которой пул сокращается, если -throttle_5xx не задан.
+// Небольшая доля ошибок бывает и у здорового сервера, поэтому порог не нулевой
+const adaptiveErrorRate = 0.1
+
+// Во сколько раз средняя задержка в окне должна превысить наименьшую
+// из наблюдавшихся, чтобы считать сервер перегруженным и сократить пул.
+// Меньшее значение делает регулятор осторожнее, большее - агрессивнее
+const adaptiveLatencyFactor = 2.0
+
+// workerThrottle динамически уменьшает число активных воркеров, когда сервер
+// начинает отвечать 5xx, и постепенно возвращает их по мере восстановления
+// (additive-increase / multiplicative-decrease, как в управлении перегрузкой TCP).
+// Воркеры с номером не меньше текущего лимита ждут, пока лимит не вырастет.
+// В адаптивном режиме лимит уменьшается и при росте задержки ответов
+type workerThrottle struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	active    int     // сколько воркеров сейчас может выполнять запросы
+	min       int     // ниже этого лимит не опускается
+	max       int     // общее число воркеров
+	threshold float64 // доля 5xx в окне, при которой лимит уменьшается вдвое
+
+	// Допустимый рост задержки относительно baseline, 0 - задержка не учитывается
+	latencyFactor float64
+	// Наименьшая средняя задержка по окну за запуск: задержка ненагруженного сервера
+	baseline time.Duration
+
+	window    [throttleWindow]bool
+	latencies [throttleWindow]time.Duration
+	filled    int
+	pos       int
+
+	stopped bool // индексы закончились, ограничение больше не действует
+}
+
+func newWorkerThrottle(workers int, threshold float64) *workerThrottle {
+	t := &workerThrottle{active: workers, min: 1, max: workers, threshold: threshold}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+// newAdaptiveThrottle создает регулятор для -adaptive_workers: пул начинается
+// с minWorkers активных воркеров и растет до maxWorkers, пока задержка и доля
+// 5xx остаются в норме. Нулевой threshold заменяется на adaptiveErrorRate.
+//
+// Горутины воркеров при этом не создаются и не завершаются по ходу работы:
+// все maxWorkers запускаются сразу, а регулятор лишь решает, сколько из них
+// может брать индексы. Неактивный воркер ждет в Wait до чтения индекса,
+// поэтому протокол каналов indices и results не меняется, а простаивающая
+// горутина стоит только своего стека. Запуск и остановка горутин дали бы
+// то же число одновременных запросов ценой отдельного учета их завершения
+func newAdaptiveThrottle(minWorkers, maxWorkers int, threshold float64) *workerThrottle {
+	if threshold <= 0 {
+		threshold = adaptiveErrorRate
+	}
+	t := &workerThrottle{
+		active:        minWorkers,
+		min:           minWorkers,
+		max:           maxWorkers,
+		threshold:     threshold,
+		latencyFactor: adaptiveLatencyFactor,
+	}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+// Wait блокирует воркера с номером id, пока он не входит в число активных.
+// Как и у паузы, ожидание прерывается отменой контекста
+func (t *workerThrottle) Wait(ctx context.Context, id int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id < t.active || t.stopped {
+		return ctx.Err()
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.cond.Broadcast()
+	})
+	defer stop()
+
+	for id >= t.active && !t.stopped && ctx.Err() == nil {
+		t.cond.Wait()
+	}
+	return ctx.Err()
+}
+
+// Report учитывает исход и длительность очередного запроса. Решение об
+// изменении лимита принимается по заполненному окну, после чего окно
+// начинается заново, чтобы следующее решение опиралось уже на ответы при новом лимите
+func (t *workerThrottle) Report(serverError bool, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.window[t.pos] = serverError
+	t.latencies[t.pos] = latency
+	t.pos = (t.pos + 1) % throttleWindow
+	t.filled = min(t.filled+1, throttleWindow)
+	if t.filled < throttleWindow {
+		return
+	}
+
+	errorsCount := 0
+	for _, e := range t.window {
+		if e {
+			errorsCount++
+		}
+	}
+
+	if float64(errorsCount)/throttleWindow >= t.threshold || t.overloaded() {
+		t.active = max(t.min, t.active/2)
+	} else if t.active < t.max {
+		t.active++
+		t.cond.Broadcast()
+	} else {
+		return
+	}
+	t.filled = 0
+}
+
+// overloaded сравнивает среднюю задержку в окне с baseline.
+// Вызывается с захваченным mu
+func (t *workerThrottle) overloaded() bool {
+	if t.latencyFactor == 0 {
+		return false
+	}
+	var sum time.Duration
+	for _, l := range t.latencies {
+		sum += l
+	}
+	mean := sum / throttleWindow
+	if t.baseline == 0 || mean < t.baseline {
+		t.baseline = mean
+	}
+	return float64(mean) > t.latencyFactor*float64(t.baseline)
+}
+
+// Stop снимает ограничение для всех воркеров, когда работа закончилась
+func (t *workerThrottle) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	t.cond.Broadcast()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// Адаптивный регулятор растет по одному воркеру на окно здоровых ответов,
+// вдвое сокращает пул при росте задержки или ошибках и не выходит за [min, max]
+func TestAdaptiveThrottleBounds(t *testing.T) {
+	throttle := newAdaptiveThrottle(2, 4, 0)
+	report := func(serverError bool, latency time.Duration) {
+		for range throttleWindow {
+			throttle.Report(serverError, latency)
+		}
+	}
+	for _, want := range []int{3, 4, 4} {
+		report(false, 10*time.Millisecond)
+		checkActive(t, throttle, want)
+	}
+	// Задержка выросла больше чем в adaptiveLatencyFactor раз
+	report(false, 50*time.Millisecond)
+	checkActive(t, throttle, 2)
+	// Доля 5xx выше adaptiveErrorRate, но ниже min пул не сокращается
+	report(true, 10*time.Millisecond)
+	checkActive(t, throttle, 2)
+}
+
+// Решение принимается ровно на throttleWindow-м ответе окна
+func TestAdaptiveThrottleWindow(t *testing.T) {
+	throttle := newAdaptiveThrottle(1, 8, 0)
+	for _, want := range []int{2, 3} {
+		for range throttleWindow - 1 {
+			throttle.Report(false, time.Millisecond)
+		}
+		checkActive(t, throttle, want-1)
+		throttle.Report(false, time.Millisecond)
+		checkActive(t, throttle, want)
+	}
+}
+
+func checkActive(t *testing.T, throttle *workerThrottle, want int) {
+	t.Helper()
+	throttle.mu.Lock()
+	active := throttle.active
+	throttle.mu.Unlock()
+	if active != want {
+		t.Fatalf("активных воркеров %d, ожидалось %d", active, want)
+	}
+}
+
+// Неактивный воркер ждет до чтения индекса и продолжает, когда пул растет
+// или работа заканчивается
+func TestThrottleWaitParksInactiveWorker(t *testing.T) {
+	throttle := newAdaptiveThrottle(1, 2, 0)
+	ctx := context.Background()
+	if err := throttle.Wait(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	released := make(chan error, 1)
+	go func() { released <- throttle.Wait(ctx, 1) }()
+	select {
+	case <-released:
+		t.Fatal("воркер сверх лимита не был приостановлен")
+	case <-time.After(50 * time.Millisecond):
+	}
+	throttle.Stop()
+	select {
+	case err := <-released:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Stop не отпустил приостановленного воркера")
+	}
+}
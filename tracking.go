@@ -0,0 +1,53 @@
+package main
+
+import "github.com/kscvrmn/tev_test/polygon"
+
+// loadPrevBboxes читает результат предыдущего запуска и возвращает bbox
+// его тяжелых многоугольников по индексам. Bbox в выводе не сохраняется,
+// поэтому восстанавливается по точкам
+func loadPrevBboxes(name string) (map[int]Bbox, error) {
+	prev, err := loadResult(name)
+	if err != nil {
+		return nil, err
+	}
+	bboxes := make(map[int]Bbox, len(prev.HeavyPolygons))
+	for _, heavy := range prev.HeavyPolygons {
+		if heavy.Polygon == nil || len(heavy.Points) == 0 {
+			continue
+		}
+		bboxes[heavy.Index] = pointsBbox(heavy.Points)
+	}
+	return bboxes, nil
+}
+
+// pointsBbox возвращает bbox набора точек, который не должен быть пустым
+func pointsBbox(points []WeightedPoint) Bbox {
+	bbox := polygon.EmptyBbox()
+	for _, p := range points {
+		bbox.X1 = MinFloat(bbox.X1, p.X)
+		bbox.Y1 = MinFloat(bbox.Y1, p.Y)
+		bbox.X2 = MaxFloat(bbox.X2, p.X)
+		bbox.Y2 = MaxFloat(bbox.Y2, p.Y)
+	}
+	return bbox
+}
+
+// BboxIoU возвращает отношение площади пересечения bbox к площади их объединения.
+// Вырожденные bbox нулевой площади совпадают только сами с собой
+func BboxIoU(a, b Bbox) float64 {
+	if a == b {
+		return 1
+	}
+	ix := min(a.X2, b.X2) - max(a.X1, b.X1)
+	iy := min(a.Y2, b.Y2) - max(a.Y1, b.Y1)
+	if ix <= 0 || iy <= 0 {
+		return 0
+	}
+	inter := ix * iy
+	union := bboxArea(a) + bboxArea(b) - inter
+	return inter / union
+}
+
+func bboxArea(b Bbox) float64 {
+	return (b.X2 - b.X1) * (b.Y2 - b.Y1)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBboxIoU(t *testing.T) {
+	unit := Bbox{X1: 0, Y1: 0, X2: 2, Y2: 2}
+	tests := []struct {
+		name string
+		b    Bbox
+		want float64
+	}{
+		{"совпадают", unit, 1},
+		// Пересечение 2, объединение 4 + 4 - 2
+		{"сдвиг на половину", Bbox{X1: 1, Y1: 0, X2: 3, Y2: 2}, 1.0 / 3},
+		{"вложенный", Bbox{X1: 0, Y1: 0, X2: 1, Y2: 1}, 0.25},
+		{"касаются стороной", Bbox{X1: 2, Y1: 0, X2: 4, Y2: 2}, 0},
+		{"не пересекаются", Bbox{X1: 5, Y1: 5, X2: 6, Y2: 6}, 0},
+		{"вырожденный внутри", Bbox{X1: 1, Y1: 1, X2: 1, Y2: 1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BboxIoU(unit, tt.b); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("BboxIoU = %g, ожидалось %g", got, tt.want)
+			}
+			if got := BboxIoU(tt.b, unit); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("BboxIoU несимметричен: %g", got)
+			}
+		})
+	}
+	point := Bbox{X1: 1, Y1: 1, X2: 1, Y2: 1}
+	if got := BboxIoU(point, point); got != 1 {
+		t.Errorf("вырожденный bbox с самим собой: %g, ожидалось 1", got)
+	}
+}
+
+// IoU считается по индексам многоугольников из сохраненного результата,
+// новые многоугольники получают 0
+func TestPrevResultIoU(t *testing.T) {
+	prev := collect(t, collectOptions{},
+		processed(t, 0, square(0, 0, 2, 150), processOptions{}),
+		processed(t, 1, square(10, 0, 2, 150), processOptions{}),
+	)
+	var out bytes.Buffer
+	if err := encodeResult(&out, prev); err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(t.TempDir(), "prev.json")
+	if err := os.WriteFile(name, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bboxes, err := loadPrevBboxes(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bboxes) != 2 || bboxes[1] != (Bbox{X1: 10, Y1: 0, X2: 12, Y2: 2}) {
+		t.Fatalf("bbox предыдущего запуска %v", bboxes)
+	}
+
+	result := collect(t, collectOptions{prevBboxes: bboxes},
+		processed(t, 0, square(0, 0, 2, 150), processOptions{}),
+		processed(t, 1, square(11, 0, 2, 150), processOptions{}),
+		processed(t, 2, square(0, 0, 2, 150), processOptions{}),
+	)
+	want := map[int]float64{0: 1, 1: 1.0 / 3, 2: 0}
+	for _, heavy := range result.HeavyPolygons {
+		if heavy.BboxIoU == nil || math.Abs(*heavy.BboxIoU-want[heavy.Index]) > 1e-12 {
+			t.Errorf("многоугольник %d: IoU %v, ожидалось %g", heavy.Index, heavy.BboxIoU, want[heavy.Index])
+		}
+	}
+
+	if _, err := loadPrevBboxes(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("нет ошибки для отсутствующего файла")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Transformer пересчитывает координаты точки из одной системы координат в другую.
+// Реализации должны быть безопасны для вызова из нескольких воркеров одновременно
+type Transformer interface {
+	Transform(x, y float64) (float64, float64)
+}
+
+// IdentityTransformer оставляет координаты без изменений
+type IdentityTransformer struct{}
+
+func (IdentityTransformer) Transform(x, y float64) (float64, float64) {
+	return x, y
+}
+
+// AffineTransformer выполняет аффинное преобразование
+//
+//	x' = A*x + B*y + C
+//	y' = D*x + E*y + F
+type AffineTransformer struct {
+	A, B, C float64
+	D, E, F float64
+}
+
+func (t AffineTransformer) Transform(x, y float64) (float64, float64) {
+	return t.A*x + t.B*y + t.C, t.D*x + t.E*y + t.F
+}
+
+// ParseAffineTransformer разбирает коэффициенты в виде "a,b,c,d,e,f"
+func ParseAffineTransformer(s string) (AffineTransformer, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 6 {
+		return AffineTransformer{}, fmt.Errorf("ожидается 6 коэффициентов a,b,c,d,e,f, получено %d", len(parts))
+	}
+
+	var k [6]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return AffineTransformer{}, fmt.Errorf("некорректный коэффициент %q: %v", part, err)
+		}
+		k[i] = v
+	}
+	return AffineTransformer{A: k[0], B: k[1], C: k[2], D: k[3], E: k[4], F: k[5]}, nil
+}
+
+// Реестр преобразований, доступных через -reproject.
+// Заполняется до запуска воркеров, после этого только читается
+var transformers = map[string]Transformer{
+	"identity": IdentityTransformer{},
+}
+
+// RegisterTransformer добавляет преобразование в реестр под заданным именем
+func RegisterTransformer(name string, t Transformer) {
+	transformers[name] = t
+}
+
+func lookupTransformer(name string) (Transformer, error) {
+	t, ok := transformers[name]
+	if !ok {
+		names := make([]string, 0, len(transformers))
+		for n := range transformers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("неизвестное преобразование %q, доступны: %s", name, strings.Join(names, ", "))
+	}
+	return t, nil
+}
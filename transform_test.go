@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+// Аффинное преобразование применяется ко всем точкам до расчета bbox
+func TestProcessPolygonReproject(t *testing.T) {
+	// Масштаб 2 по X, 0.5 по Y и сдвиг на (10, -5)
+	affine, err := ParseAffineTransformer("2, 0, 10, 0, 0.5, -5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterTransformer("test_affine", affine)
+	t.Cleanup(func() { delete(transformers, "test_affine") })
+	transformer, err := lookupTransformer("test_affine")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := processed(t, 0, square(0, 0, 4, 200), processOptions{transformer: transformer})
+	if want := (Bbox{X1: 10, Y1: -5, X2: 18, Y2: -3}); r.localBbox != want {
+		t.Errorf("bbox %+v, ожидался %+v", r.localBbox, want)
+	}
+	// Площадь меняется на определитель преобразования, вес - нет
+	if r.area != 16 || r.weight != 200 {
+		t.Errorf("площадь %g, вес %g, ожидалось 16 и 200", r.area, r.weight)
+	}
+
+	identity := processed(t, 0, square(0, 0, 4, 200), processOptions{transformer: IdentityTransformer{}})
+	if want := (Bbox{X1: 0, Y1: 0, X2: 4, Y2: 4}); identity.localBbox != want {
+		t.Errorf("identity: bbox %+v, ожидался %+v", identity.localBbox, want)
+	}
+}
+
+func TestParseAffineTransformer(t *testing.T) {
+	for _, s := range []string{"", "1,0,0,0,1", "1,0,0,0,1,x", "1,0,0,0,1,0,0"} {
+		if _, err := ParseAffineTransformer(s); err == nil {
+			t.Errorf("%q разобрано без ошибки", s)
+		}
+	}
+	if _, err := lookupTransformer("mercator"); err == nil {
+		t.Error("неизвестное преобразование найдено")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// Краткая форма подстановки индекса в адрес, равносильна {{.Index}}
+const urlIndexPlaceholder = "{index}"
+
+// Данные, доступные в шаблоне адреса
+type urlTemplateData struct {
+	Index int
+}
+
+// parseURLTemplates разбирает адреса с подстановкой индекса, например
+// http://host/polygon/{index} или http://host/polygon?id={{.Index}}.
+// Адреса без подстановки в результат не попадают и используются как есть.
+// Шаблон сразу пробуется на индексе 0, чтобы ошибка обнаружилась до запуска
+func parseURLTemplates(urls ...string) (map[string]*template.Template, error) {
+	templates := make(map[string]*template.Template)
+	for _, raw := range urls {
+		if !strings.Contains(raw, urlIndexPlaceholder) && !strings.Contains(raw, "{{") {
+			continue
+		}
+		text := strings.ReplaceAll(raw, urlIndexPlaceholder, "{{.Index}}")
+		tmpl, err := template.New("url").Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", raw, err)
+		}
+		expanded, err := expandURL(tmpl, 0)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", raw, err)
+		}
+		if _, err := url.Parse(expanded); err != nil {
+			return nil, fmt.Errorf("%s: %v", raw, err)
+		}
+		templates[raw] = tmpl
+	}
+	return templates, nil
+}
+
+// expandURL подставляет индекс многоугольника в шаблон адреса
+func expandURL(tmpl *template.Template, idx int) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, urlTemplateData{Index: idx}); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// runVerify сравнивает два сохраненных результата и печатает различия.
+// Возвращает код выхода: 0 - результаты совпадают, 1 - есть различия,
+// 2 - файлы не удалось прочитать
+func runVerify(args []string, epsilon float64, out io.Writer) int {
+	if len(args) != 2 {
+		fmt.Fprintln(out, "для -verify нужно указать два файла: -verify a.json b.json")
+		return 2
+	}
+
+	a, err := loadResult(args[0])
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 2
+	}
+	b, err := loadResult(args[1])
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return 2
+	}
+
+	diffs := diffResults(a, b, epsilon)
+	for _, d := range diffs {
+		fmt.Fprintln(out, d)
+	}
+	if len(diffs) > 0 {
+		return 1
+	}
+	fmt.Fprintln(out, "результаты совпадают")
+	return 0
+}
+
+func loadResult(name string) (Result, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return Result{}, fmt.Errorf("ошибка чтения %s: %v", name, err)
+	}
+	var result Result
+	if err := json.Unmarshal(data, &result); err != nil {
+		return Result{}, fmt.Errorf("ошибка разбора %s: %v", name, err)
+	}
+	return result, nil
+}
+
+// diffResults возвращает описания различающихся полей. Вещественные значения
+// считаются равными, если отличаются не больше чем на epsilon
+func diffResults(a, b Result, epsilon float64) []string {
+	var diffs []string
+	if !bboxWithin(a.Bbox, b.Bbox, epsilon) {
+		diffs = append(diffs, fmt.Sprintf("bbox: %+v != %+v", a.Bbox, b.Bbox))
+	}
+	if math.Abs(a.MaxWeight-b.MaxWeight) > epsilon {
+		diffs = append(diffs, fmt.Sprintf("max_weight: %g != %g", a.MaxWeight, b.MaxWeight))
+	}
+	if len(a.HeavyPolygons) != len(b.HeavyPolygons) {
+		diffs = append(diffs, fmt.Sprintf("heavy_polygons: %d != %d", len(a.HeavyPolygons), len(b.HeavyPolygons)))
+	}
+	return diffs
+}
+
+// bboxWithin сообщает, отличаются ли все границы bbox не больше чем на epsilon
+func bboxWithin(a, b Bbox, epsilon float64) bool {
+	return math.Abs(a.X1-b.X1) <= epsilon && math.Abs(a.Y1-b.Y1) <= epsilon &&
+		math.Abs(a.X2-b.X2) <= epsilon && math.Abs(a.Y2-b.Y2) <= epsilon
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiffResults(t *testing.T) {
+	base := Result{
+		Bbox:          Bbox{X1: 0, Y1: 0, X2: 1, Y2: 1},
+		MaxWeight:     100,
+		HeavyPolygons: []*HeavyPolygon{{Index: 1}},
+	}
+	tests := []struct {
+		name   string
+		change func(r *Result)
+		fields []string
+	}{
+		{"совпадают", func(r *Result) {}, nil},
+		// Расхождения в пределах epsilon не считаются различиями,
+		// в том числе у каждой границы bbox
+		{"bbox в пределах epsilon", func(r *Result) { r.Bbox.X2 = 1.0000000001; r.Bbox.Y1 = -1e-4 }, nil},
+		{"вес в пределах epsilon", func(r *Result) { r.MaxWeight = 100.0005 }, nil},
+		{"bbox", func(r *Result) { r.Bbox.X1 = -0.01 }, []string{"bbox"}},
+		{"вес", func(r *Result) { r.MaxWeight = 101 }, []string{"max_weight"}},
+		{"тяжелые", func(r *Result) { r.HeavyPolygons = nil }, []string{"heavy_polygons"}},
+		{"все поля", func(r *Result) { r.Bbox.Y2 = 2; r.MaxWeight = 0; r.HeavyPolygons = nil }, []string{"bbox", "max_weight", "heavy_polygons"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := base
+			tt.change(&other)
+			var fields []string
+			for _, d := range diffResults(base, other, 1e-3) {
+				field, _, _ := strings.Cut(d, ":")
+				fields = append(fields, field)
+			}
+			if !slices.Equal(fields, tt.fields) {
+				t.Errorf("различия в %v, ожидались %v", fields, tt.fields)
+			}
+		})
+	}
+}
+
+// Код выхода -verify: 0 при совпадении, 1 при различиях, 2 если файл
+// не прочитан или не разобран
+func TestRunVerify(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	a := write("a.json", `{"bbox":{"x1":0,"y1":0,"x2":1,"y2":1},"max_weight":10}`)
+	same := write("same.json", `{"bbox":{"x1":0,"y1":0,"x2":1.0000001,"y2":1},"max_weight":10}`)
+	other := write("other.json", `{"bbox":{"x1":0,"y1":0,"x2":1,"y2":1},"max_weight":11}`)
+	broken := write("broken.json", `{"bbox":`)
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		output string
+	}{
+		{"совпадают", []string{a, same}, 0, "результаты совпадают"},
+		{"различаются", []string{a, other}, 1, "max_weight: 10 != 11"},
+		{"один файл", []string{a}, 2, "нужно указать два файла"},
+		{"нет файла", []string{a, filepath.Join(dir, "missing.json")}, 2, "ошибка чтения"},
+		{"битый файл", []string{broken, a}, 2, "ошибка разбора"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if code := runVerify(tt.args, 1e-6, &out); code != tt.code {
+				t.Errorf("код %d, ожидался %d", code, tt.code)
+			}
+			if !strings.Contains(out.String(), tt.output) {
+				t.Errorf("вывод %q не содержит %q", out.String(), tt.output)
+			}
+		})
+	}
+}
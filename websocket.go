@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// isWebsocketURL сообщает, что многоугольники нужно читать из websocket,
+// а не загружать по индексам
+func isWebsocketURL(url string) bool {
+	return strings.HasPrefix(url, "ws://") || strings.HasPrefix(url, "wss://")
+}
+
+// Сообщение из websocket с порядковым номером, который служит индексом
+type wsMessage struct {
+	index int
+	data  []byte
+}
+
+// runWebsocket подключается к серверу, который сам присылает многоугольники,
+// по одному JSON в сообщении. Сообщения разбираются воркерами, а результат
+// агрегируется, когда сервер закроет соединение. Число многоугольников
+// заранее неизвестно, поэтому -polygons_num и -count_url не используются.
+// Подключение отправляет те же заголовки, что и HTTP-запросы fetcher:
+// авторизацию, -header и идентификатор запуска
+func runWebsocket(ctx context.Context, url string, workers int, resCh chan Result,
+	fetcher *cliFetcher, collectOpts collectOptions) {
+	processOpts := fetcher.opts
+	header := fetcher.Header()
+	if id := runIDFrom(ctx); id != "" {
+		header.Set(runIDHeader, id)
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, header)
+	if err != nil {
+		fatalf(ctx, "Ошибка подключения к websocket: %v", err)
+	}
+
+	// ReadMessage не принимает контекст, поэтому при отмене закрываем соединение
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	messages := make(chan wsMessage, 100)
+	results := make(chan PolygonResult, 100)
+
+	go func() {
+		defer close(messages)
+		defer stop()
+		defer conn.Close()
+		for i := 0; ; i++ {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				// Нормальное закрытие соединения сервером - конец потока
+				var closeErr *websocket.CloseError
+				if ctx.Err() == nil && !(errors.As(err, &closeErr) && closeErr.Code == websocket.CloseNormalClosure) {
+					warnf(ctx, "Ошибка чтения из websocket: %v", err)
+				}
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case messages <- wsMessage{index: i, data: data}:
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range messages {
+				var polygonResult PolygonResult
+				if shape, err := decodeShape(contentTypeJSON, msg.data, processOpts.strictJSON); err != nil {
+					polygonResult = PolygonResult{err: fmt.Errorf("многоугольник %d: %w", msg.index, err)}
+					if processOpts.keepErrorBodies {
+						polygonResult.rawBody = msg.data
+					}
+				} else {
+					polygonResult = processShape(shape, ctx, processOpts)
+				}
+				polygonResult.index = msg.index
+				if PostProcess != nil {
+					PostProcess(&polygonResult)
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case results <- polygonResult:
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	go collectResults(ctx, results, totalUnknown, resCh, collectOpts)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/kscvrmn/tev_test/polygon"
+	"github.com/kscvrmn/tev_test/polygontest"
+)
+
+// Сервер присылает несколько многоугольников и закрывает соединение:
+// все они должны попасть в результат, а подключение - нести заголовки fetcher
+func TestRunWebsocket(t *testing.T) {
+	const total = 6
+	headers := make(chan http.Header, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers <- r.Header.Clone()
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for i := range total {
+			if err := conn.WriteJSON(polygontest.Polygon(i)); err != nil {
+				return
+			}
+		}
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		// Ждем ответного закрытия, чтобы клиент успел прочитать все сообщения
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	f := newFetcher(processOptions{},
+		polygon.WithHeader("Authorization", "Bearer secret"),
+		polygon.WithHeader("X-Team", "maps"),
+	)
+	ctx, cancel := context.WithTimeout(withRunID(context.Background(), "run-1"), 5*time.Second)
+	defer cancel()
+	resCh := make(chan Result, 1)
+	runWebsocket(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), 2, resCh, f, collectOptions{heavyThreshold: 100})
+
+	select {
+	case result := <-resCh:
+		if result.Partial || result.Processed != total || result.Total != total {
+			t.Errorf("partial %v, обработано %d из %d, ожидалось %d", result.Partial, result.Processed, result.Total, total)
+		}
+		// Четные индексы тяжелые
+		if len(result.HeavyPolygons) != total/2 {
+			t.Errorf("тяжелых %d, ожидалось %d", len(result.HeavyPolygons), total/2)
+		}
+		if want := (Bbox{X1: 0, Y1: 0, X2: 10 * total, Y2: 10}); result.Bbox != want {
+			t.Errorf("bbox %+v, ожидался %+v", result.Bbox, want)
+		}
+	case <-ctx.Done():
+		t.Fatal("результат не получен")
+	}
+
+	header := <-headers
+	for key, want := range map[string]string{"Authorization": "Bearer secret", "X-Team": "maps", runIDHeader: "run-1"} {
+		if got := header.Get(key); got != want {
+			t.Errorf("заголовок %s = %q, ожидался %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package main
+
+import "runtime"
+
+// defaultWorkers выбирает число воркеров по умолчанию. runtime.NumCPU()
+// возвращает все ядра машины и на общих CI-раннерах приводит к избыточному
+// параллелизму, поэтому учитываем GOMAXPROCS и квоту CPU контейнера (cgroup)
+func defaultWorkers() int {
+	n := runtime.GOMAXPROCS(0)
+	if quota, ok := cgroupCPUQuota(); ok && quota < n {
+		n = quota
+	}
+	return max(n, 1)
+}
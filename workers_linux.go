@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// cgroupCPUQuota возвращает квоту CPU контейнера, округленную вверх до целого
+// числа ядер. Поддерживаются cgroup v2 (cpu.max) и v1 (cpu.cfs_quota_us).
+// ok == false, если квота не задана или ее не удалось прочитать
+func cgroupCPUQuota() (int, bool) {
+	// cgroup v2: "<quota> <period>" или "max <period>"
+	if data, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		fields := strings.Fields(string(data))
+		if len(fields) == 2 && fields[0] != "max" {
+			return quotaCores(fields[0], fields[1])
+		}
+		return 0, false
+	}
+
+	// cgroup v1: квота -1 означает отсутствие ограничения
+	quota, err := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	if err != nil {
+		return 0, false
+	}
+	period, err := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0, false
+	}
+	return quotaCores(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+func quotaCores(quotaStr, periodStr string) (int, bool) {
+	quota, err := strconv.ParseFloat(quotaStr, 64)
+	if err != nil || quota <= 0 {
+		return 0, false
+	}
+	period, err := strconv.ParseFloat(periodStr, 64)
+	if err != nil || period <= 0 {
+		return 0, false
+	}
+	return int(math.Ceil(quota / period)), true
+}
@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestQuotaCores(t *testing.T) {
+	tests := []struct {
+		quota, period string
+		want          int
+		ok            bool
+	}{
+		{"200000", "100000", 2, true},
+		// Дробная квота округляется вверх: полтора ядра - это два воркера
+		{"150000", "100000", 2, true},
+		{"50000", "100000", 1, true},
+		// Квота -1 в cgroup v1 означает отсутствие ограничения
+		{"-1", "100000", 0, false},
+		{"100000", "0", 0, false},
+		{"max", "100000", 0, false},
+		{"", "", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := quotaCores(tt.quota, tt.period)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("quotaCores(%q, %q) = %d, %v, ожидалось %d, %v", tt.quota, tt.period, got, ok, tt.want, tt.ok)
+		}
+	}
+}
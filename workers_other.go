@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// Квоты cgroup есть только в Linux
+func cgroupCPUQuota() (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+// Число воркеров ограничено GOMAXPROCS, даже если ядер на машине больше,
+// и квотой CPU контейнера, если она меньше
+func TestDefaultWorkers(t *testing.T) {
+	for _, procs := range []int{1, 3} {
+		old := runtime.GOMAXPROCS(procs)
+		got := defaultWorkers()
+		runtime.GOMAXPROCS(old)
+
+		want := procs
+		if quota, ok := cgroupCPUQuota(); ok {
+			want = min(want, quota)
+		}
+		if got != want {
+			t.Errorf("при GOMAXPROCS=%d воркеров %d, ожидалось %d", procs, got, want)
+		}
+	}
+}